@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +11,9 @@ import (
 	"tbot/pkg/bot"
 	botDB "tbot/pkg/db"
 	"time"
+
+	// time zones don't depend on the system database
+	_ "time/tzdata"
 )
 
 const (
@@ -21,13 +25,35 @@ var (
 	appURL        string
 	port          string
 	dbParams      string
-	chats         string
 	botToken      string
 	dbUpdateToken string
 	uptimeToken   string
 	notifChat     string
 )
 
+// optional environment variable
+var (
+	retentionToken    string
+	calendarToken     string
+	apiToken          string
+	retentionDays     = botDB.DefaultRetentionDays
+	retentionInterval time.Duration
+	archiveDir        string
+	schedule          = bot.DefaultSchedule
+	catchUp           = bot.CatchUpLate
+	digestAt          string
+	reports           []bot.ReportPeriod
+	reportAt          string
+	paging            bool
+	etpLinks          botDB.ETPLinks
+	calendar          botDB.Calendar
+	location          *time.Location
+	validChats        map[int64]bool
+	admins            map[int64]bool
+	announcedChanges  = []botDB.ChangeKind{botDB.StatusChange,
+		botDB.BiddingChange, botDB.CollectingChange, botDB.WinnerChange}
+)
+
 // getEnvs gets all required environment vars
 func getEnvs() error {
 	appURL = os.Getenv("APP_URL")
@@ -42,10 +68,6 @@ func getEnvs() error {
 	if dbParams == "" {
 		return fmt.Errorf("$DATABASE_URL must be set")
 	}
-	chats = os.Getenv("CHATS")
-	if chats == "" {
-		return fmt.Errorf("$CHATS must be set")
-	}
 	botToken = os.Getenv("BOT_TOKEN")
 	if botToken == "" {
 		return fmt.Errorf("$BOT_TOKEN must be set")
@@ -66,6 +88,255 @@ func getEnvs() error {
 	return nil
 }
 
+// getOptionalEnvs gets all optional environment vars
+func getOptionalEnvs() error {
+	var err error
+	retentionToken = os.Getenv("RETENTION_TOKEN")
+	calendarToken = os.Getenv("CALENDAR_TOKEN")
+	apiToken = os.Getenv("API_TOKEN")
+	archiveDir = os.Getenv("ARCHIVE_DIR")
+	if v := os.Getenv("RETENTION_DAYS"); v != "" {
+		retentionDays, err = strconv.Atoi(v)
+		if err != nil || retentionDays <= 0 {
+			return fmt.Errorf("$RETENTION_DAYS must be a positive integer")
+		}
+	}
+	if v := os.Getenv("RETENTION_INTERVAL"); v != "" {
+		retentionInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("$RETENTION_INTERVAL must be a duration: %v", err)
+		}
+	}
+	if v := os.Getenv("REMINDER_SCHEDULE"); v != "" {
+		schedule, err = loadSchedule(v)
+		if err != nil {
+			return fmt.Errorf("$REMINDER_SCHEDULE: %v", err)
+		}
+	}
+	// environment takes precedence over the schedule file
+	if v := os.Getenv("AUCTION_LEADS"); v != "" {
+		schedule.Auction, err = parseDurations(v)
+		if err != nil {
+			return fmt.Errorf("$AUCTION_LEADS: %v", err)
+		}
+	}
+	if v := os.Getenv("DEADLINE_LEADS"); v != "" {
+		schedule.Deadline, err = parseDurations(v)
+		if err != nil {
+			return fmt.Errorf("$DEADLINE_LEADS: %v", err)
+		}
+	}
+	// allowed chats are seeded to the empty database members
+	// and admins on every start, then access is managed with bot commands
+	if v := os.Getenv("CHATS"); v != "" {
+		validChats, err = parseValidChats(v)
+		if err != nil {
+			return fmt.Errorf("$CHATS: %v", err)
+		}
+	}
+	if v := os.Getenv("ADMINS"); v != "" {
+		admins, err = parseValidChats(v)
+		if err != nil {
+			return fmt.Errorf("$ADMINS: %v", err)
+		}
+	}
+	digestAt = os.Getenv("DIGEST_AT")
+	if v := os.Getenv("REPORTS"); v != "" {
+		reports, err = parseReportPeriods(v)
+		if err != nil {
+			return fmt.Errorf("$REPORTS: %v", err)
+		}
+	}
+	reportAt = os.Getenv("REPORT_AT")
+	if v := os.Getenv("PAGING"); v != "" {
+		paging, err = strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("$PAGING must be a boolean")
+		}
+	}
+	if v := os.Getenv("HOLIDAYS"); v != "" {
+		days, err := parseHolidays(v)
+		if err != nil {
+			return fmt.Errorf("$HOLIDAYS: %v", err)
+		}
+		calendar = botDB.NewCalendar(days)
+	}
+	location, err = botDB.LoadLocation(os.Getenv("TIMEZONE"))
+	if err != nil {
+		return fmt.Errorf("$TIMEZONE: %v", err)
+	}
+	if v := os.Getenv("ETP_LINKS"); v != "" {
+		etpLinks, err = loadETPLinks(v)
+		if err != nil {
+			return fmt.Errorf("$ETP_LINKS: %v", err)
+		}
+	}
+	switch v := os.Getenv("REMINDER_CATCH_UP"); v {
+	case "", "late":
+		catchUp = bot.CatchUpLate
+	case "skip":
+		catchUp = bot.CatchUpSkip
+	default:
+		return fmt.Errorf("$REMINDER_CATCH_UP must be 'late' or 'skip'")
+	}
+	if v, ok := os.LookupEnv("ANNOUNCE_CHANGES"); ok {
+		announcedChanges, err = parseChangeKinds(v)
+		if err != nil {
+			return fmt.Errorf("$ANNOUNCE_CHANGES: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// parseDurations returns durations parsed from
+// environment variable. This function expects that provided
+// variable is a string with space separated durations i.e. '24h 3h'
+func parseDurations(durations string) ([]time.Duration, error) {
+	s := strings.Fields(durations)
+	res := make([]time.Duration, 0, len(s))
+
+	for i := range s {
+		d, err := time.ParseDuration(s[i])
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("duration must be positive, got %s", s[i])
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// loadSchedule reads reminder schedule from the json file
+// of the form {"auction": ["24h", "2h", "10m"], "deadline": ["24h", "3h"]}.
+// Missing event types get default stages
+func loadSchedule(path string) (bot.Schedule, error) {
+	var raw struct {
+		Auction  []string `json:"auction"`
+		Deadline []string `json:"deadline"`
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return bot.Schedule{}, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&raw); err != nil {
+		return bot.Schedule{}, err
+	}
+
+	sch := bot.DefaultSchedule
+	if len(raw.Auction) > 0 {
+		if sch.Auction, err = parseDurations(strings.Join(raw.Auction, " ")); err != nil {
+			return bot.Schedule{}, err
+		}
+	}
+	if len(raw.Deadline) > 0 {
+		if sch.Deadline, err = parseDurations(strings.Join(raw.Deadline, " ")); err != nil {
+			return bot.Schedule{}, err
+		}
+	}
+
+	return sch, nil
+}
+
+// loadETPLinks reads ETP procedure URL templates from the json file
+// of the form {"РТС-тендер": "https://www.rts-tender.ru/poisk/search?keywords={number}"}
+func loadETPLinks(path string) (botDB.ETPLinks, error) {
+	var links map[string]string
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&links); err != nil {
+		return nil, err
+	}
+
+	for name, t := range links {
+		if !strings.Contains(t, botDB.ETPNumberPlaceholder) {
+			return nil, fmt.Errorf("template of '%s' has no %s placeholder", name, botDB.ETPNumberPlaceholder)
+		}
+	}
+
+	return botDB.NewETPLinks(links), nil
+}
+
+// parseHolidays returns days parsed from environment
+// variable. This function expects that provided variable
+// is a string with space separated dates i.e. '2022-01-01 2022-01-02'
+func parseHolidays(days string) ([]time.Time, error) {
+	s := strings.Fields(days)
+	res := make([]time.Time, 0, len(s))
+
+	for i := range s {
+		d, err := time.Parse("2006-01-02", s[i])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// parseChangeKinds returns change kinds parsed from
+// environment variable. This function expects that provided
+// variable is a string with space separated kinds.
+// Empty string means that nothing is announced
+func parseChangeKinds(kinds string) ([]botDB.ChangeKind, error) {
+	s := strings.Fields(kinds)
+	res := make([]botDB.ChangeKind, 0, len(s))
+
+	for i := range s {
+		k, ok := knownChangeKind(s[i])
+		if !ok {
+			return nil, fmt.Errorf("unknown change kind '%s'", s[i])
+		}
+		res = append(res, k)
+	}
+	return res, nil
+}
+
+func knownChangeKind(kind string) (botDB.ChangeKind, bool) {
+	for _, k := range botDB.ChangeKinds {
+		if string(k) == kind {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// parseReportPeriods returns report periods parsed from
+// environment variable. This function expects that provided
+// variable is a string with space separated periods i.e. 'weekly monthly'
+func parseReportPeriods(periods string) ([]bot.ReportPeriod, error) {
+	s := strings.Fields(periods)
+	res := make([]bot.ReportPeriod, 0, len(s))
+
+	for i := range s {
+		p, ok := knownReportPeriod(s[i])
+		if !ok {
+			return nil, fmt.Errorf("unknown report period '%s'", s[i])
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func knownReportPeriod(period string) (bot.ReportPeriod, bool) {
+	for _, p := range bot.ReportPeriods {
+		if string(p) == period {
+			return p, true
+		}
+	}
+	return "", false
+}
+
 // parseValidChats returns chats map parsed from
 // environment variable. This function expects that provided
 // variable is a string with space separated chat id's.
@@ -91,16 +362,113 @@ func parseChat(chat string) (int64, error) {
 	return n, nil
 }
 
+// migrate command
+const (
+	migrateCmd    = "migrate"
+	migrateUp     = "up"
+	migrateDown   = "down"
+	migrateStatus = "status"
+	migrateUsage  = "usage: tbot migrate [up | down [N] | status]"
+)
+
+// parseMigrateArgs returns the migrate command
+// action and the number of steps to roll back.
+// Action is 'up' if empty
+func parseMigrateArgs(args []string) (string, int, error) {
+	if len(args) == 0 {
+		return migrateUp, 0, nil
+	}
+
+	switch args[0] {
+	case migrateUp, migrateStatus:
+		if len(args) == 1 {
+			return args[0], 0, nil
+		}
+	case migrateDown:
+		if len(args) == 1 {
+			return migrateDown, 1, nil
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err == nil && n > 0 {
+				return migrateDown, n, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf(migrateUsage)
+}
+
+// migrate applies or rolls back the schema migrations
+// of the database set by the environment
+func migrate(args []string) error {
+	action, steps, err := parseMigrateArgs(args)
+	if err != nil {
+		return err
+	}
+
+	dbParams = os.Getenv("DATABASE_URL")
+	if dbParams == "" {
+		return fmt.Errorf("$DATABASE_URL must be set")
+	}
+
+	db, err := botDB.Connect(dbParams)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	mg, err := botDB.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case migrateStatus:
+		st, err := mg.Status()
+		if err != nil {
+			return err
+		}
+		for _, m := range st {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, state)
+		}
+		return nil
+	case migrateDown:
+		done, err := mg.Down(steps)
+		for _, m := range done {
+			log.Printf("rolled back %04d_%s", m.Version, m.Name)
+		}
+		return err
+	default:
+		done, err := mg.Up()
+		for _, m := range done {
+			log.Printf("applied %04d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			log.Printf("schema is up to date")
+		}
+		return err
+	}
+}
+
 func main() {
 
+	// schema migrations are run without the bot
+	if len(os.Args) > 1 && os.Args[1] == migrateCmd {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// get all required environment vars
 	if err := getEnvs(); err != nil {
 		log.Fatal(err)
 	}
-
-	// parse allowed chats from environment
-	validChats, err := parseValidChats(chats)
-	if err != nil {
+	if err := getOptionalEnvs(); err != nil {
 		log.Fatal(err)
 	}
 
@@ -110,7 +478,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// establish database connection
+	// establish database connection, pending
+	// schema migrations are applied on start
 	db, err := botDB.OpenDB(dbParams)
 	if err != nil {
 		log.Fatal(err)
@@ -126,7 +495,26 @@ func main() {
 		UptimeToken:      uptimeToken,
 		DB:               db,
 		AllowedChats:     validChats,
+		Admins:           admins,
 		NotificationChat: nChat,
+		RetentionToken:   retentionToken,
+		Retention: botDB.RetentionPolicy{
+			Days:       retentionDays,
+			ArchiveDir: archiveDir,
+		},
+		RetentionInterval: retentionInterval,
+		CalendarToken:     calendarToken,
+		APIToken:          apiToken,
+		Schedule:          schedule,
+		CatchUp:           catchUp,
+		DigestAt:          digestAt,
+		Reports:           reports,
+		ReportAt:          reportAt,
+		Paging:            paging,
+		ETPLinks:          etpLinks,
+		Calendar:          calendar,
+		AnnouncedChanges:  announcedChanges,
+		Location:          location,
 	}
 
 	botApi, err := bot.New(&c)
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"tbot/pkg/bot"
 	"testing"
+	"time"
 )
 
 func Test_validChats(t *testing.T) {
@@ -45,3 +49,77 @@ func Test_validChats(t *testing.T) {
 	})
 
 }
+
+func Test_parseChangeKinds(t *testing.T) {
+	t.Run("good_input", func(t *testing.T) {
+		kinds, err := parseChangeKinds("status  bidding winner")
+		if err != nil {
+			t.Fatalf("parsing: %v\n", err)
+		}
+		if len(kinds) != 3 {
+			t.Fatalf("expected 3 parsed kinds, got %d\n", len(kinds))
+		}
+	})
+
+	t.Run("empty_input", func(t *testing.T) {
+		kinds, err := parseChangeKinds("")
+		if err != nil || len(kinds) != 0 {
+			t.Fatalf("expected no kinds and no error, got %v, %v\n", kinds, err)
+		}
+	})
+
+	t.Run("bad_input", func(t *testing.T) {
+		_, err := parseChangeKinds("status unknown")
+		if err == nil {
+			t.Fatalf("expected error while parsing unknown kind, got nil instead\n")
+		}
+	})
+}
+
+func Test_loadSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	err := os.WriteFile(path, []byte(`{"auction": ["24h", "2h", "10m"]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sch, err := loadSchedule(path)
+	if err != nil {
+		t.Fatalf("loading schedule: %v\n", err)
+	}
+
+	if len(sch.Auction) != 3 || sch.Auction[1] != 2*time.Hour {
+		t.Fatalf("expected 3 auction stages with 2h second, got %v\n", sch.Auction)
+	}
+	// missing event type gets default stages
+	if len(sch.Deadline) != len(bot.DefaultSchedule.Deadline) {
+		t.Fatalf("expected default deadline stages, got %v\n", sch.Deadline)
+	}
+}
+
+func Test_parseMigrateArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		action  string
+		steps   int
+		wantErr bool
+	}{
+		{nil, migrateUp, 0, false},
+		{[]string{"up"}, migrateUp, 0, false},
+		{[]string{"status"}, migrateStatus, 0, false},
+		{[]string{"down"}, migrateDown, 1, false},
+		{[]string{"down", "3"}, migrateDown, 3, false},
+		{[]string{"down", "0"}, "", 0, true},
+		{[]string{"up", "3"}, "", 0, true},
+		{[]string{"sideways"}, "", 0, true},
+	}
+	for _, tt := range tests {
+		action, steps, err := parseMigrateArgs(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseMigrateArgs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if action != tt.action || steps != tt.steps {
+			t.Errorf("parseMigrateArgs(%v) = %s %d, want %s %d", tt.args, action, steps, tt.action, tt.steps)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// access command
+const (
+	grantCmd   = "grant"
+	revokeCmd  = "revoke"
+	membersCmd = "members"
+)
+
+// callback query action
+const (
+	requestAction      = "q"  // unknown chat asks admins for access
+	grantViewerAction  = "av" // admin grants viewer role to the chat
+	grantManagerAction = "am" // admin grants manager role to the chat
+	denyAccessAction   = "ad" // admin denies the access request
+)
+
+// access message
+const (
+	forbiddenMsg       = "Извини, для этой команды недостаточно прав 🔒"
+	noMembersMsg       = "Доступ никому не выдан 🤷"
+	accessGrantedMsg   = "Доступ открыт ✅\n➡️ */help* для справки"
+	accessDeniedMsg    = "Извини, в доступе отказано 🚫"
+	selfRevokeMsg      = "Свой доступ отозвать нельзя 🤷"
+	configuredAdminMsg = "Доступ администратора из настроек в боте не меняется 🔒"
+	grantHelpMsg       = `*/` + grantCmd + `    _ID_ _РОЛЬ_* выдает чату роль ` + "\n" +
+		`*/` + revokeCmd + `    _ID_* отзывает доступ чата` + "\n" +
+		`*/` + membersCmd + `* показывает, у кого есть доступ` + "\n" +
+		`Роли: *viewer* \- просмотр, *manager* \- также правка закупок, *admin* \- также управление доступом`
+)
+
+// callback query answer
+const (
+	requestSentAnswer = "Запрос отправлен администраторам ✉️"
+	pendingAnswer     = "Запрос уже отправлен, ждем решения ⏳"
+	noAdminsAnswer    = "Некому отправить запрос 🤷"
+	grantedAnswer     = "Доступ выдан ✅"
+	deniedAnswer      = "Запрос отклонен 🚫"
+)
+
+// cmdRoles are the roles required for the commands.
+// Commands missing here are available to any member
+var cmdRoles = map[string]botDB.Role{
+	grantCmd:   botDB.Admin,
+	revokeCmd:  botDB.Admin,
+	membersCmd: botDB.Admin,
+	setCmd:     botDB.Manager,
+	unsetCmd:   botDB.Manager,
+}
+
+// rawArgsCmds are the commands which
+// arguments are not parsed as flags
+var rawArgsCmds = map[string]bool{
+	grantCmd:  true,
+	revokeCmd: true,
+	setCmd:    true,
+	unsetCmd:  true,
+}
+
+// requiredRole returns the role required for the command
+func requiredRole(cmd string) botDB.Role {
+	if r, ok := cmdRoles[cmd]; ok {
+		return r
+	}
+	return botDB.Viewer
+}
+
+// members keeps the chats allowed
+// to use the bot and their roles
+type members interface {
+	Role(chat int64) (botDB.Role, error)
+	Grant(chat int64, r botDB.Role, by int64) error
+	Revoke(chat int64) (bool, error)
+	Members(r botDB.Role) ([]botDB.Member, error)
+}
+
+// role returns the role of the chat, configured admins
+// are always admins. Any error is logged and treated
+// as restricted access
+func (t *tgUpdHandler) role(chat int64) botDB.Role {
+	if t.admins[chat] {
+		return botDB.Admin
+	}
+	r, err := t.a.Role(chat)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching role of chatID=%d %v]", chat, err)
+		return botDB.NoRole
+	}
+	return r
+}
+
+// userRole returns the role of the user. User's id is
+// the id of the private chat with the user, so the role is
+// the one granted to that chat. Unknown sender has no role
+func (t *tgUpdHandler) userRole(u *tgbotapi.User) botDB.Role {
+	if u == nil {
+		return botDB.NoRole
+	}
+	return t.role(int64(u.ID))
+}
+
+// grantCmdResponse is the '/grant' command handler
+func (t *tgUpdHandler) grantCmdResponse(by int64, f *flags) []message {
+	if len(f.args) != 2 {
+		return plain(invalidArgsMsg, grantHelpMsg)
+	}
+	chat, err := strconv.ParseInt(f.args[0], 10, 64)
+	if err != nil || chat == 0 {
+		return plain(invalidArgsMsg, grantHelpMsg)
+	}
+	r, ok := botDB.ParseRole(f.args[1])
+	if !ok {
+		return plain(invalidArgsMsg, grantHelpMsg)
+	}
+	if chat == by && r != botDB.Admin {
+		return plain(selfRevokeMsg)
+	}
+	if t.admins[chat] && r != botDB.Admin {
+		return plain(configuredAdminMsg)
+	}
+
+	if err = t.a.Grant(chat, r, by); err != nil {
+		t.logger.Printf("[Telegram] -> [due granting access %v]", err)
+		return plain(errorMsg)
+	}
+	t.settle(chat)
+	t.notifyMember(chat, accessGrantedMsg)
+
+	return plain(fmt.Sprintf("Чату *%s* выдана роль *%s* ✅", chatString(chat), r))
+}
+
+// revokeCmdResponse is the '/revoke' command handler
+func (t *tgUpdHandler) revokeCmdResponse(by int64, f *flags) []message {
+	if len(f.args) != 1 {
+		return plain(invalidArgsMsg, grantHelpMsg)
+	}
+	chat, err := strconv.ParseInt(f.args[0], 10, 64)
+	if err != nil || chat == 0 {
+		return plain(invalidArgsMsg, grantHelpMsg)
+	}
+	// admin can't lock oneself out
+	if chat == by {
+		return plain(selfRevokeMsg)
+	}
+	if t.admins[chat] {
+		return plain(configuredAdminMsg)
+	}
+
+	ok, err := t.a.Revoke(chat)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due revoking access %v]", err)
+		return plain(errorMsg)
+	}
+	if !ok {
+		return plain(fmt.Sprintf("У чата *%s* нет доступа 🤷", chatString(chat)))
+	}
+	if t.w != nil {
+		t.w.forget(chat)
+	}
+
+	return plain(fmt.Sprintf("Доступ чата *%s* отозван 🔒", chatString(chat)))
+}
+
+// membersCmdResponse is the '/members' command handler
+func (t *tgUpdHandler) membersCmdResponse(f *flags) []message {
+	// check for the garbage in arguments
+	if len(f.args) > 0 {
+		return unknownArgsErr(f)
+	}
+
+	ms, err := t.a.Members(botDB.NoRole)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching members %v]", err)
+		return plain(errorMsg)
+	}
+	if len(ms) == 0 {
+		return plain(noMembersMsg)
+	}
+
+	var b strings.Builder
+	b.WriteString("*Доступ к боту:*\n")
+	for i := range ms {
+		fmt.Fprintf(&b, "\n*%s* \\- %s", chatString(ms[i].Chat), ms[i].Role)
+		if ms[i].GrantedBy != 0 {
+			fmt.Fprintf(&b, " \\(выдал %s %s\\)", chatString(ms[i].GrantedBy),
+				mdReplacer.Replace(ms[i].GrantedAt.Format("02.01.2006")))
+		}
+	}
+	return plain(b.String())
+}
+
+// notAllowed is response for unauthorized request.
+// It offers to ask the admins for the access
+func (t *tgUpdHandler) notAllowed(m *tgbotapi.Message) message {
+	text := notAllowedMsg
+	if m.From != nil && m.From.FirstName != "" {
+		text = fmt.Sprintf("Привет, %s 👋\n%s", mdReplacer.Replace(m.From.FirstName), notAllowedMsg)
+	} else if m.From != nil && m.From.UserName != "" {
+		text = fmt.Sprintf("Привет, %s 👋\n%s", mdReplacer.Replace(m.From.UserName), notAllowedMsg)
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🙋 Запросить доступ", callbackData(requestAction, m.Chat.ID))))
+
+	return message{text: text, keyboard: &kb}
+}
+
+// requestAccess sends the access request of the chat to the admins
+func (t *tgUpdHandler) requestAccess(cq *tgbotapi.CallbackQuery) {
+	_, chat, err := parseCallbackData(cq.Data)
+	// the request is only for the chat the button is in
+	if err != nil || chat != cq.Message.Chat.ID {
+		t.answer(cq, badQueryAnswer)
+		return
+	}
+
+	// admins get the single request of the chat until they decide
+	if !t.pend(chat) {
+		t.answer(cq, pendingAnswer)
+		return
+	}
+
+	admins, err := t.a.Members(botDB.Admin)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching admins %v]", err)
+		t.settle(chat)
+		t.answer(cq, errorMsg)
+		return
+	}
+	if len(admins) == 0 {
+		t.settle(chat)
+		t.answer(cq, noAdminsAnswer)
+		return
+	}
+
+	m := accessRequest(cq, chat)
+	for i := range admins {
+		if err = sendMessages(t.api, admins[i].Chat, m); err != nil {
+			t.logger.Println(err)
+		}
+	}
+	t.answer(cq, requestSentAnswer)
+}
+
+// pend marks the access request of the chat as pending.
+// It reports false if the chat already has the pending one
+func (t *tgUpdHandler) pend(chat int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending[chat] {
+		return false
+	}
+	t.pending[chat] = true
+	return true
+}
+
+// settle forgets the pending access request of the chat
+func (t *tgUpdHandler) settle(chat int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, chat)
+}
+
+// accessRequest builds the message to the admins
+// with the buttons to grant or deny the access
+func accessRequest(cq *tgbotapi.CallbackQuery, chat int64) message {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🙋 *Запрос доступа*\nЧат: *%s*", chatString(chat))
+	if title := cq.Message.Chat.Title; title != "" {
+		fmt.Fprintf(&b, " \\(%s\\)", mdReplacer.Replace(title))
+	}
+	if cq.From != nil {
+		fmt.Fprintf(&b, "\nОт: %s", mdReplacer.Replace(cq.From.String()))
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("👀 Просмотр", callbackData(grantViewerAction, chat)),
+		tgbotapi.NewInlineKeyboardButtonData("🛠 Менеджер", callbackData(grantManagerAction, chat)),
+		tgbotapi.NewInlineKeyboardButtonData("🚫 Отказать", callbackData(denyAccessAction, chat)),
+	))
+
+	return message{text: b.String(), keyboard: &kb}
+}
+
+// decideAccess grants or denies the access request
+// and replaces the buttons of the request with the decision
+func (t *tgUpdHandler) decideAccess(cq *tgbotapi.CallbackQuery) {
+	action, chat, err := parseCallbackData(cq.Data)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due parsing callback %v]", err)
+		t.answer(cq, badQueryAnswer)
+		return
+	}
+
+	var r botDB.Role
+	switch action {
+	case grantViewerAction:
+		r = botDB.Viewer
+	case grantManagerAction:
+		r = botDB.Manager
+	case denyAccessAction:
+	default:
+		t.answer(cq, badQueryAnswer)
+		return
+	}
+
+	t.settle(chat)
+
+	decision, answer, notice := "🚫 Отказано", deniedAnswer, accessDeniedMsg
+	if r != botDB.NoRole {
+		if err = t.a.Grant(chat, r, int64(cq.From.ID)); err != nil {
+			t.logger.Printf("[Telegram] -> [due granting access %v]", err)
+			t.answer(cq, errorMsg)
+			return
+		}
+		decision, answer, notice = fmt.Sprintf("✅ Выдана роль *%s*", r), grantedAnswer, accessGrantedMsg
+	}
+	t.answer(cq, answer)
+	t.notifyMember(chat, notice)
+
+	if t.api == nil {
+		return
+	}
+	// the request text came from us, so it only needs the original escaping
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID,
+		mdReplacer.Replace(cq.Message.Text)+"\n\n"+decision)
+	edit.ParseMode = parseMode
+	if _, err = t.api.Send(edit); err != nil {
+		t.logger.Printf("[Telegram] -> [due editing message: chat=%d; err=%v]", cq.Message.Chat.ID, err)
+	}
+}
+
+// notifyMember tells the chat about the access decision
+func (t *tgUpdHandler) notifyMember(chat int64, text string) {
+	if t.api == nil {
+		return
+	}
+	if err := send(t.api, chat, text); err != nil {
+		t.logger.Println(err)
+	}
+}
+
+// isAccessDecision reports if the data is of the admin's decision button
+func isAccessDecision(data string) bool {
+	action, _, _ := strings.Cut(data, ":")
+	return action == grantViewerAction || action == grantManagerAction || action == denyAccessAction
+}
+
+// chatString returns the chat id escaped for
+// markdown, group chats have negative ids
+func chatString(chat int64) string {
+	return mdReplacer.Replace(strconv.FormatInt(chat, 10))
+}
@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"io"
+	"log"
+	"strings"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func Test_requiredRole(t *testing.T) {
+	assert("requiredRole()", requiredRole(grantCmd), botDB.Admin, t)
+	assert("requiredRole()", requiredRole(todayCmd), botDB.Viewer, t)
+}
+
+func Test_tgUpdHandler_grantCmdResponse(t *testing.T) {
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, memdb.New(false), memdb.New(false), nil, false, nil, nil)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"granted", []string{"-100123", "manager"}, "Чату *\\-100123* выдана роль *manager* ✅"},
+		{"unknown_role", []string{"2", "owner"}, invalidArgsMsg},
+		{"no_role", []string{"2"}, invalidArgsMsg},
+		{"self_downgrade", []string{"1", "viewer"}, selfRevokeMsg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.grantCmdResponse(1, &flags{args: tt.args})
+			assert("tgUpdHandler.grantCmdResponse()", got[0].text, tt.want, t)
+		})
+	}
+}
+
+func Test_tgUpdHandler_revokeCmdResponse(t *testing.T) {
+	w := memWatcher{10: 2, 11: 3}
+	a := memdb.New(false)
+	if err := a.Grant(2, botDB.Viewer, 1); err != nil {
+		t.Fatalf("MemDB.Grant() error=%v", err)
+	}
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), w, a, memdb.New(false), nil, false, nil, nil)
+
+	got := h.revokeCmdResponse(1, &flags{args: []string{"1"}})
+	assert("tgUpdHandler.revokeCmdResponse()", got[0].text, selfRevokeMsg, t)
+
+	got = h.revokeCmdResponse(1, &flags{args: []string{"2"}})
+	assert("tgUpdHandler.revokeCmdResponse()", got[0].text, "Доступ чата *2* отозван 🔒", t)
+	// revoked chat isn't reminded anymore
+	assert("tgUpdHandler.revokeCmdResponse()", len(w), 1, t)
+	assert("tgUpdHandler.revokeCmdResponse()", w[11], int64(3), t)
+}
+
+// memRoster keeps the roles by chat
+type memRoster map[int64]botDB.Role
+
+func (r memRoster) Members(_ botDB.Role) ([]botDB.Member, error) {
+	var ms []botDB.Member
+	for chat, role := range r {
+		ms = append(ms, botDB.Member{Chat: chat, Role: role})
+	}
+	return ms, nil
+}
+
+func (r memRoster) Grant(chat int64, role botDB.Role, _ int64) error {
+	r[chat] = role
+	return nil
+}
+
+func (r memRoster) Role(chat int64) (botDB.Role, error) {
+	return r[chat], nil
+}
+
+func (r memRoster) Revoke(chat int64) (bool, error) {
+	_, ok := r[chat]
+	delete(r, chat)
+	return ok, nil
+}
+
+func (r memRoster) Enroll(chat int64, role botDB.Role) error {
+	if _, ok := r[chat]; !ok {
+		r[chat] = role
+	}
+	return nil
+}
+
+func Test_seedMembers(t *testing.T) {
+	c := &Config{Admins: map[int64]bool{1: true}, AllowedChats: map[int64]bool{2: true, 3: true}}
+	r := memRoster{}
+
+	if err := seedMembers(r, c); err != nil {
+		t.Fatalf("seedMembers() error=%v", err)
+	}
+	assert("seedMembers()", len(r), 3, t)
+	assert("seedMembers()", r[2], botDB.Viewer, t)
+
+	// revoked chat stays revoked after the restart
+	delete(r, 2)
+	if err := seedMembers(r, c); err != nil {
+		t.Fatalf("seedMembers() error=%v", err)
+	}
+	assert("seedMembers()", len(r), 2, t)
+	assert("seedMembers()", r[1], botDB.Admin, t)
+}
+
+func Test_tgUpdHandler_responses_role(t *testing.T) {
+	// the group is the viewer, its users have their own roles
+	r := memRoster{-100: botDB.Viewer, 1: botDB.Admin}
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, r, memdb.New(false), nil, false, nil, nil)
+
+	command := func(from int, text string) *tgbotapi.Update {
+		return &tgbotapi.Update{Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: from},
+			Chat:     &tgbotapi.Chat{ID: -100, Type: "group"},
+			Text:     text,
+			Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(text)[0])}},
+		}}
+	}
+
+	tests := []struct {
+		name string
+		from int
+		text string
+		want string
+	}{
+		{"viewer_command", 2, "/" + statusCmd, statusMsg},
+		{"not_admin", 2, "/" + grantCmd + " 2 admin", forbiddenMsg},
+		{"not_manager", 2, "/" + setCmd + " 1 status -", forbiddenMsg},
+		{"admin", 1, "/" + grantCmd + " 2 manager", "Чату *2* выдана роль *manager* ✅"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := command(tt.from, tt.text)
+			got := h.responses(u, &flags{args: strings.Fields(u.Message.CommandArguments())}, botDB.Viewer)
+			assert("tgUpdHandler.responses()", got[0].text, tt.want, t)
+		})
+	}
+	// admin in the group grants the role
+	assert("tgUpdHandler.responses()", r[2], botDB.Manager, t)
+}
+
+func Test_tgUpdHandler_pend(t *testing.T) {
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, memdb.New(false), memdb.New(false), nil, false, nil, nil)
+
+	assert("tgUpdHandler.pend()", h.pend(5), true, t)
+	assert("tgUpdHandler.pend()", h.pend(5), false, t)
+	assert("tgUpdHandler.pend()", h.pend(6), true, t)
+
+	// the decision lets the chat ask again
+	h.decideAccess(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 1}, Data: callbackData(denyAccessAction, 5),
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}})
+	assert("tgUpdHandler.pend()", h.pend(5), true, t)
+}
+
+func Test_tgUpdHandler_configuredAdmin(t *testing.T) {
+	// configured admin 5 has lost the role in the database
+	r := memRoster{1: botDB.Admin, 5: botDB.Viewer}
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, r, memdb.New(false), nil, false,
+		map[int64]bool{5: true}, nil)
+
+	assert("tgUpdHandler.role()", h.role(5), botDB.Admin, t)
+
+	got := h.revokeCmdResponse(1, &flags{args: []string{"5"}})
+	assert("tgUpdHandler.revokeCmdResponse()", got[0].text, configuredAdminMsg, t)
+	got = h.grantCmdResponse(1, &flags{args: []string{"5", "viewer"}})
+	assert("tgUpdHandler.grantCmdResponse()", got[0].text, configuredAdminMsg, t)
+
+	// nothing is changed in the database
+	assert("tgUpdHandler.role()", h.role(5), botDB.Admin, t)
+	assert("memRoster", len(r), 2, t)
+}
+
+func Test_isAccessDecision(t *testing.T) {
+	assert("isAccessDecision()", isAccessDecision(callbackData(grantViewerAction, 1)), true, t)
+	assert("isAccessDecision()", isAccessDecision(callbackData(denyAccessAction, 1)), true, t)
+	assert("isAccessDecision()", isAccessDecision(callbackData(requestAction, 1)), false, t)
+	assert("isAccessDecision()", isAccessDecision(callbackData(infoAction, 1)), false, t)
+}
@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// path prefix of the REST API endpoints
+const apiPrefix = "/api/v1"
+
+// REST API handler message
+const (
+	apiUnauthorized  = "missing or invalid bearer token"
+	apiNotFound      = "purchase not found"
+	apiFailure       = "unable to fetch purchases"
+	apiInvalidLimit  = "'limit' must be an integer from 1 to 500"
+	apiInvalidOffset = "'offset' must be a non-negative integer"
+	apiInvalidDate   = "'from' and 'to' must be dates in YYYY-MM-DD format"
+	apiInvalidPeriod = "'from' must not be after 'to'"
+)
+
+// period parameters of the API
+const (
+	apiDateLayout     = "2006-01-02" // date layout of the query parameters
+	apiAggregatesDays = 30           // default period of the aggregates
+)
+
+// period parameters errors
+var (
+	errInvalidDate   = errors.New(apiInvalidDate)
+	errInvalidPeriod = errors.New(apiInvalidPeriod)
+)
+
+// purchasesPage is the response of the purchase listing
+type purchasesPage struct {
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+	Items  []botDB.PurchaseView `json:"items"`
+}
+
+// apiEndpoints sets the read-only REST API
+// endpoints authorized by the bearer token
+func (bot *Bot) apiEndpoints(token string) {
+	api := bot.r.PathPrefix(apiPrefix).Subrouter()
+	api.Use(bot.apiAuthMiddleware(token))
+	api.Handle("/purchases", bot.purchasesHandler()).Methods(http.MethodGet, http.MethodOptions)
+	api.Handle("/purchases/{id:[0-9]+}", bot.purchaseHandler()).Methods(http.MethodGet, http.MethodOptions)
+	api.Handle("/purchases/number/{number:[0-9]+}", bot.purchaseNumberHandler()).
+		Methods(http.MethodGet, http.MethodOptions)
+	api.Handle("/aggregates", bot.aggregatesHandler()).Methods(http.MethodGet, http.MethodOptions)
+}
+
+// apiAuthMiddleware passes only the requests
+// authorized by the bearer token
+func (bot *Bot) apiAuthMiddleware(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := r.Header.Get("Authorization")
+			got := strings.TrimPrefix(h, "Bearer ")
+			if got == h || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeResponse(w, apiUnauthorized, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// purchasesHandler lists the purchases page by page. The listing is
+// filtered by 'text', 'region', 'etp', 'customer', 'participant',
+// 'status' and the applications deadline period 'from' - 'to'
+// query parameters, the page is set by 'limit' and 'offset'
+func (bot *Bot) purchasesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := botDB.ListFilter{
+			SearchFilter: botDB.SearchFilter{
+				Text:         q.Get("text"),
+				Region:       q.Get("region"),
+				ETP:          q.Get("etp"),
+				CustomerType: q.Get("customer"),
+				Participant:  q.Get("participant"),
+			},
+			Status: q.Get("status"),
+			Limit:  botDB.DefaultPageSize,
+		}
+
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > botDB.MaxPageSize {
+				writeResponse(w, apiInvalidLimit, http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+		if v := q.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeResponse(w, apiInvalidOffset, http.StatusBadRequest)
+				return
+			}
+			f.Offset = n
+		}
+
+		var err error
+		if f.From, f.To, err = apiPeriod(q.Get("from"), q.Get("to"), bot.clock.Now().Location()); err != nil {
+			writeResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		recs, total, err := bot.db.List(f)
+		if err != nil {
+			bot.logger.Printf("[API Handler] -> [due listing records: err=%v]", err)
+			writeResponse(w, apiFailure, http.StatusInternalServerError)
+			return
+		}
+
+		page := purchasesPage{Total: total, Limit: f.Limit, Offset: f.Offset,
+			Items: make([]botDB.PurchaseView, 0, len(recs))}
+		for i := range recs {
+			page.Items = append(page.Items, recs[i].View())
+		}
+
+		writeJSON(w, page, http.StatusOK)
+	})
+}
+
+// purchaseHandler returns the purchase by its id
+func (bot *Bot) purchaseHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil || id <= 0 {
+			writeResponse(w, apiNotFound, http.StatusNotFound)
+			return
+		}
+
+		p, err := bot.db.QueryRow(id)
+		if err != nil {
+			if err == botDB.ErrNoRows {
+				writeResponse(w, apiNotFound, http.StatusNotFound)
+				return
+			}
+			bot.logger.Printf("[API Handler] -> [due fetching record: err=%v]", err)
+			writeResponse(w, apiFailure, http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, p.View(), http.StatusOK)
+	})
+}
+
+// purchaseNumberHandler returns the purchase by its
+// registry number. Only exact match is returned
+func (bot *Bot) purchaseNumberHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		num := mux.Vars(r)["number"]
+
+		recs, err := bot.db.QueryNumber(num)
+		if err != nil {
+			bot.logger.Printf("[API Handler] -> [due fetching record: err=%v]", err)
+			writeResponse(w, apiFailure, http.StatusInternalServerError)
+			return
+		}
+
+		// exact match comes first
+		if len(recs) == 0 || recs[0].RegistryNumber != num {
+			writeResponse(w, apiNotFound, http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, recs[0].View(), http.StatusOK)
+	})
+}
+
+// aggregatesHandler returns the performance over the period set by
+// 'from' and 'to' query parameters, last 30 days by default
+func (bot *Bot) aggregatesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := apiPeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"),
+			bot.clock.Now().Location())
+		if err != nil {
+			writeResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.IsZero() {
+			to = startOfDay(bot.clock.Now()).AddDate(0, 0, 1)
+		}
+		if from.IsZero() {
+			from = to.AddDate(0, 0, -apiAggregatesDays)
+		}
+		if !from.Before(to) {
+			writeResponse(w, apiInvalidPeriod, http.StatusBadRequest)
+			return
+		}
+
+		rep, err := bot.db.Report(from, to)
+		if err != nil {
+			bot.logger.Printf("[API Handler] -> [due building report: err=%v]", err)
+			writeResponse(w, apiFailure, http.StatusInternalServerError)
+			return
+		}
+
+		// empty groups are the empty arrays rather than null
+		if rep.ByRegion == nil {
+			rep.ByRegion = []botDB.ReportRow{}
+		}
+		if rep.ByParticipant == nil {
+			rep.ByParticipant = []botDB.ReportRow{}
+		}
+
+		writeJSON(w, rep, http.StatusOK)
+	})
+}
+
+// apiPeriod parses the dates of the period [from, to).
+// The day 'to' is included, so the upper bound is the next
+// day midnight in the time zone. Zero time is returned for the empty dates
+func apiPeriod(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	var f, t time.Time
+	var err error
+
+	if from != "" {
+		if f, err = time.ParseInLocation(apiDateLayout, from, loc); err != nil {
+			return f, t, errInvalidDate
+		}
+	}
+	if to != "" {
+		if t, err = time.ParseInLocation(apiDateLayout, to, loc); err != nil {
+			return f, t, errInvalidDate
+		}
+		t = t.AddDate(0, 0, 1)
+	}
+	if !f.IsZero() && !t.IsZero() && !f.Before(t) {
+		return f, t, errInvalidPeriod
+	}
+
+	return f, t, nil
+}
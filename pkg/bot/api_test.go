@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBot_apiEndpoints(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	tb := Bot{
+		r:      mux.NewRouter(),
+		db:     memdb.New(false),
+		logger: logger,
+		clock:  botDB.SystemClock{},
+	}
+	tb.r.Use(tb.headersMiddleware)
+	tb.apiEndpoints("secret")
+
+	get := func(url, token string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "http://test.com"+apiPrefix+url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		tb.r.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			resp := get("/purchases", token)
+			assert("Bot.apiAuthMiddleware()", resp.StatusCode, http.StatusUnauthorized, t)
+			r := decodeResponse("Bot.apiAuthMiddleware()", resp.Body, t)
+			assert("Bot.apiAuthMiddleware()", r["response"], apiUnauthorized, t)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		resp := get("/purchases?region=test&limit=10", "secret")
+		assert("Bot.purchasesHandler()", resp.StatusCode, http.StatusOK, t)
+
+		var page struct {
+			Total int              `json:"total"`
+			Limit int              `json:"limit"`
+			Items []map[string]any `json:"items"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("Bot.purchasesHandler() error=%v", err)
+		}
+		assert("Bot.purchasesHandler()", page.Total, 1, t)
+		assert("Bot.purchasesHandler()", page.Limit, 10, t)
+		if len(page.Items) != 1 {
+			t.Fatalf("Bot.purchasesHandler() items = %v, want 1 item", page.Items)
+		}
+		// status of the mock is not queried
+		if v, ok := page.Items[0]["status"]; !ok || v != nil {
+			t.Errorf("Bot.purchasesHandler() status = %v, want null", v)
+		}
+	})
+
+	t.Run("list_empty_page", func(t *testing.T) {
+		resp := get("/purchases?offset=50", "secret")
+		assert("Bot.purchasesHandler()", resp.StatusCode, http.StatusOK, t)
+
+		var page map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("Bot.purchasesHandler() error=%v", err)
+		}
+		if items, ok := page["items"].([]any); !ok || len(items) != 0 {
+			t.Errorf("Bot.purchasesHandler() items = %v, want empty array", page["items"])
+		}
+	})
+
+	t.Run("list_bad_request", func(t *testing.T) {
+		tests := []struct{ url, want string }{
+			{"/purchases?limit=0", apiInvalidLimit},
+			{"/purchases?limit=501", apiInvalidLimit},
+			{"/purchases?offset=-1", apiInvalidOffset},
+			{"/purchases?from=05.07.2022", apiInvalidDate},
+			{"/purchases?from=2022-07-05&to=2022-07-01", apiInvalidPeriod},
+		}
+		for _, tt := range tests {
+			resp := get(tt.url, "secret")
+			assert("Bot.purchasesHandler()", resp.StatusCode, http.StatusBadRequest, t)
+			r := decodeResponse("Bot.purchasesHandler()", resp.Body, t)
+			assert("Bot.purchasesHandler()", r["response"], tt.want, t)
+		}
+	})
+
+	t.Run("get_by_id", func(t *testing.T) {
+		resp := get("/purchases/1", "secret")
+		assert("Bot.purchaseHandler()", resp.StatusCode, http.StatusOK, t)
+		r := decodeAny("Bot.purchaseHandler()", resp.Body, t)
+		assert("Bot.purchaseHandler()", r["registry_number"].(string), memdb.MockPurchase.RegistryNumber, t)
+	})
+
+	t.Run("get_by_number", func(t *testing.T) {
+		resp := get("/purchases/number/"+memdb.MockPurchase.RegistryNumber, "secret")
+		assert("Bot.purchaseNumberHandler()", resp.StatusCode, http.StatusOK, t)
+		r := decodeAny("Bot.purchaseNumberHandler()", resp.Body, t)
+		assert("Bot.purchaseNumberHandler()", r["purchase_id"].(float64), 1.0, t)
+
+		// suffix is not enough for the API
+		resp = get("/purchases/number/7104", "secret")
+		assert("Bot.purchaseNumberHandler()", resp.StatusCode, http.StatusNotFound, t)
+	})
+
+	t.Run("aggregates", func(t *testing.T) {
+		resp := get("/aggregates?from=2022-07-01&to=2022-07-31", "secret")
+		assert("Bot.aggregatesHandler()", resp.StatusCode, http.StatusOK, t)
+		r := decodeAny("Bot.aggregatesHandler()", resp.Body, t)
+
+		from, _ := time.Parse(time.RFC3339, r["from"].(string))
+		to, _ := time.Parse(time.RFC3339, r["to"].(string))
+		assert("Bot.aggregatesHandler()", to.Sub(from), 31*24*time.Hour, t)
+		if groups, ok := r["by_region"].([]any); !ok || len(groups) != 1 {
+			t.Errorf("Bot.aggregatesHandler() by_region = %v, want the region of the mock", r["by_region"])
+		}
+
+		// there are no purchases in the period
+		resp = get("/aggregates?from=2021-07-01&to=2021-07-31", "secret")
+		r = decodeAny("Bot.aggregatesHandler()", resp.Body, t)
+		if groups, ok := r["by_region"].([]any); !ok || len(groups) != 0 {
+			t.Errorf("Bot.aggregatesHandler() by_region = %v, want empty array", r["by_region"])
+		}
+	})
+
+	t.Run("db_error", func(t *testing.T) {
+		tb.db = memdb.New(true)
+		defer func() { tb.db = memdb.New(false) }()
+
+		for _, url := range []string{"/purchases", "/aggregates"} {
+			resp := get(url, "secret")
+			assert("Bot.apiEndpoints()", resp.StatusCode, http.StatusInternalServerError, t)
+		}
+	})
+}
+
+func decodeAny(op string, r io.Reader, t *testing.T) map[string]any {
+	var m map[string]any
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		t.Fatalf("%s error=%v", op, err)
+	}
+	return m
+}
@@ -18,7 +18,13 @@ import (
 // Config is the settigns
 // for the bot instance
 type Config struct {
-	AllowedChats     map[int64]bool
+	// AllowedChats are added as viewers on the first start, when
+	// there are no members yet. Access is managed by the admins then
+	AllowedChats map[int64]bool
+	// Admins always have the admin role, it can't be revoked
+	// or downgraded in the bot, so the access can't be lost
+	// by revoking the last admin
+	Admins           map[int64]bool
 	NotificationChat int64
 	DB               *sql.DB
 	BotName          string
@@ -26,6 +32,53 @@ type Config struct {
 	BotToken         string
 	DbUpdateToken    string
 	UptimeToken      string
+	// RetentionToken protects the old records removal
+	// endpoint. Endpoint is disabled if empty
+	RetentionToken string
+	// Retention is the default policy of the old records removal
+	Retention botDB.RetentionPolicy
+	// CalendarToken protects the calendar feed
+	// endpoint. Endpoint is disabled if empty
+	CalendarToken string
+	// APIToken is the bearer token of the read-only
+	// REST API requests. API is disabled if empty
+	APIToken string
+	// RetentionInterval is how often the old records are
+	// removed in background. Zero value disables the job
+	RetentionInterval time.Duration
+	// Schedule is the reminder stages of purchase
+	// events. Defaults are used for empty ones
+	Schedule Schedule
+	// CatchUp defines what to do with reminders missed
+	// while application was down
+	CatchUp CatchUpPolicy
+	// DigestAt is the local time of the daily digest
+	// to the notification chat i.e. '09:00'. Digest is disabled if empty
+	DigestAt string
+	// Reports are the periods of the performance reports
+	// posted to the notification chat. Reports are disabled if empty
+	Reports []ReportPeriod
+	// ReportAt is the local time of the scheduled reports i.e. '09:00'
+	ReportAt string
+	// Paging makes long listings to be shown page by page
+	// with navigation buttons instead of several messages
+	Paging bool
+	// ETPLinks are the procedure page templates by ETP name.
+	// ETPs without the template are not linked
+	ETPLinks botDB.ETPLinks
+	// AnnouncedChanges are the kinds of purchase changes
+	// announced to the notification chat
+	AnnouncedChanges []botDB.ChangeKind
+	// Location is the time zone of the purchases. Days of the
+	// listings, reminders and reports are counted in it.
+	// Default is Europe/Moscow
+	Location *time.Location
+	// Calendar tells the workdays of the listings
+	// and the digest. Only weekends are non-working if empty
+	Calendar botDB.Calendar
+	// Clock tells the current time, its time zone is the one of
+	// the purchases then. System clock in Location is used if nil
+	Clock botDB.Clock
 }
 
 // Bot is API
@@ -34,7 +87,8 @@ type Bot struct {
 	logger *log.Logger
 	tgh    tgUpdateHandler
 	db     db
-	dbUpd  chan struct{}
+	dbUpd  chan []botDB.Change
+	clock  botDB.Clock
 }
 
 func New(c *Config) (*Bot, error) {
@@ -45,21 +99,64 @@ func New(c *Config) (*Bot, error) {
 
 	logger := log.New(os.Stderr, "["+c.BotName+"] | ", log.LstdFlags|log.Lmsgprefix)
 
-	d := botDB.NewBotDB(c.DB)
+	clock := c.Clock
+	if clock == nil {
+		clock = botDB.SystemClock{Loc: c.Location}
+	}
 
-	bot := Bot{
-		r:      mux.NewRouter(),                                   // app mux router
-		db:     d,                                                 // database interface
-		logger: logger,                                            // app logger
-		tgh:    newTgUpdHandler(logger, d, tgapi, c.AllowedChats), // telegram updates handler
-		dbUpd:  make(chan struct{}),                               // database update channel
+	d := botDB.NewBotDB(c.DB, clock, c.Calendar)
+
+	if err = seedMembers(d, c); err != nil {
+		return nil, err
 	}
 
+	// updates are queued only if there is the notifier
+	var dbUpd chan []botDB.Change
+
+	// personal reminders are sent by the notifier
+	var w watcher
+
 	if c.NotificationChat != 0 {
-		var ntf notifier = newTgNotifier(logger, d, tgapi, c.NotificationChat, bot.dbUpd)
+		dbUpd = make(chan []botDB.Change, updateQueueSize)
+		ntf := newTgNotifier(logger, clock, d, d, d, d, tgapi, c.NotificationChat,
+			c.Schedule, c.CatchUp, c.AnnouncedChanges, dbUpd, c.ETPLinks)
+		w = ntf
 		go ntf.notify() // spin off the notifier in it's own routine
 	}
 
+	bot := Bot{
+		r:      mux.NewRouter(),                                                                      // app mux router
+		db:     d,                                                                                    // database interface
+		logger: logger,                                                                               // app logger
+		tgh:    newTgUpdHandler(logger, clock, d, d, w, d, d, tgapi, c.Paging, c.Admins, c.ETPLinks), // telegram updates handler
+		dbUpd:  dbUpd,                                                                                // database update channel
+		clock:  clock,                                                                                // current time
+	}
+
+	if c.NotificationChat != 0 && c.DigestAt != "" {
+		at, err := parseDigestTime(c.DigestAt)
+		if err != nil {
+			return nil, err
+		}
+		var dgs notifier = newTgDigest(logger, clock, c.Calendar, d, tgapi, c.NotificationChat, at, c.ETPLinks)
+		go dgs.notify() // daily digest goes in it's own routine too
+	}
+
+	if c.NotificationChat != 0 && len(c.Reports) > 0 {
+		at := defaultReportAt
+		if c.ReportAt != "" {
+			if at, err = parseDigestTime(c.ReportAt); err != nil {
+				return nil, err
+			}
+		}
+		var rpt notifier = newTgReporter(logger, clock, d, tgapi, c.NotificationChat, at, c.Reports)
+		go rpt.notify() // scheduled reports
+	}
+
+	if c.RetentionInterval > 0 {
+		go bot.retention(c.Retention, c.RetentionInterval) // scheduled removal of the old records
+	}
+
 	bot.endpoints(c) // set bot endpoints and middleware
 
 	return &bot, nil
@@ -68,8 +165,15 @@ func New(c *Config) (*Bot, error) {
 // db is responsible for the execution
 // of CRUD operations over the database
 type db interface {
-	Upsert(io.ReadCloser) error
-	Delete(io.ReadCloser) error
+	Upsert(io.ReadCloser, botDB.UpsertMode) (botDB.UpdateResult, error)
+	Stale(io.ReadCloser) ([]string, error)
+	ApplyDelta(io.ReadCloser, botDB.UpsertMode) (botDB.UpdateResult, error)
+	Delete(botDB.RetentionPolicy) (int64, error)
+	Feed(botDB.FeedFilter) ([]botDB.PurchaseRecord, error)
+	List(botDB.ListFilter) ([]botDB.PurchaseRecord, int, error)
+	QueryRow(int64) (botDB.PurchaseRecord, error)
+	QueryNumber(string) ([]botDB.PurchaseRecord, error)
+	Report(from, to time.Time) (botDB.Report, error)
 }
 
 // notifier is the logic responsible for
@@ -96,8 +200,23 @@ func (bot *Bot) endpoints(c *Config) {
 	// endpoint handlers
 	bot.r.Handle("/"+c.DbUpdateToken, bot.enforceJsonMiddleware(bot.dbUpdateHandler(3*time.Second))).
 		Methods(http.MethodPost, http.MethodOptions)
+	bot.r.Handle("/"+c.DbUpdateToken+"/sync", bot.enforceJsonMiddleware(bot.dbSyncHandler())).
+		Methods(http.MethodPost, http.MethodOptions)
+	bot.r.Handle("/"+c.DbUpdateToken+"/delta", bot.enforceJsonMiddleware(bot.dbDeltaHandler(3*time.Second))).
+		Methods(http.MethodPost, http.MethodOptions)
 	bot.r.HandleFunc("/"+c.UptimeToken, bot.uptimeHandler).Methods(http.MethodGet, http.MethodOptions)
 	bot.r.HandleFunc("/"+c.BotToken, bot.telegramUpdateHandler).Methods(http.MethodPost, http.MethodOptions)
+	if c.RetentionToken != "" {
+		bot.r.Handle("/"+c.RetentionToken, bot.retentionHandler(c.Retention)).
+			Methods(http.MethodDelete, http.MethodOptions)
+	}
+	if c.CalendarToken != "" {
+		bot.r.Handle("/"+c.CalendarToken+"/calendar.ics", bot.calendarHandler()).
+			Methods(http.MethodGet, http.MethodOptions)
+	}
+	if c.APIToken != "" {
+		bot.apiEndpoints(c.APIToken)
+	}
 }
 
 // closerMiddleware drains and close request body at the end
@@ -140,10 +259,15 @@ func (_ *Bot) enforceJsonMiddleware(next http.Handler) http.Handler {
 
 // writeResponse is helper function that writes response message to w
 func writeResponse(w http.ResponseWriter, message string, httpStatusCode int) {
-	w.WriteHeader(httpStatusCode)
-	_ = json.NewEncoder(w).Encode(map[string]string{
+	writeJSON(w, map[string]string{
 		"response": message,
-	})
+	}, httpStatusCode)
+}
+
+// writeJSON is helper function that writes v as json to w
+func writeJSON(w http.ResponseWriter, v any, httpStatusCode int) {
+	w.WriteHeader(httpStatusCode)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
 // uptimeHandler recieves uptime call from external resource
@@ -163,6 +287,39 @@ func (bot *Bot) telegramUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	bot.tgh.handleUpdate(&update)
 }
 
+// roster keeps the members seeded from configuration
+type roster interface {
+	Members(r botDB.Role) ([]botDB.Member, error)
+	Grant(chat int64, r botDB.Role, by int64) error
+	Enroll(chat int64, r botDB.Role) error
+}
+
+// seedMembers adds the chats from configuration to the members
+// of the bot. Allowed chats are added only while there are no members,
+// so the chats revoked by the admins don't come back on restart
+func seedMembers(d roster, c *Config) error {
+	ms, err := d.Members(botDB.NoRole)
+	if err != nil {
+		return err
+	}
+	seeded := len(ms) > 0
+
+	for chat := range c.Admins {
+		if err = d.Grant(chat, botDB.Admin, 0); err != nil {
+			return err
+		}
+	}
+	if seeded {
+		return nil
+	}
+	for chat := range c.AllowedChats {
+		if err = d.Enroll(chat, botDB.Viewer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func initTelegramApi(c *Config) (*tgbotapi.BotAPI, error) {
 	botAPI, err := tgbotapi.NewBotAPI(c.BotToken)
 	if err != nil {
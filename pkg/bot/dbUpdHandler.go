@@ -1,37 +1,133 @@
 package bot
 
 import (
+	"errors"
 	"net/http"
+	botDB "tbot/pkg/db"
 	"time"
 )
 
 // database update handler message
 const (
-	dbUpdateSuccess = "database was successfully updated"
-	dbUpdateFailure = "unable to update database"
+	dbUpdateSuccess  = "database was successfully updated"
+	dbUpdateFailure  = "unable to update database"
+	dbUpdateRejected = "update contains invalid records"
+	dbSyncFailure    = "unable to compare records"
 )
 
+// updateQueueSize is how many database updates
+// wait for the notifier while it's busy
+const updateQueueSize = 16
+
+// dbUpdateResponse is the response of the database update
+// handlers with the list of rejected records and the fields
+// kept as they were edited in the bot if any
+type dbUpdateResponse struct {
+	Response  string                  `json:"response"`
+	Rejected  []botDB.ValidationError `json:"rejected,omitempty"`
+	Conflicts []botDB.Conflict        `json:"conflicts,omitempty"`
+}
+
 func (bot *Bot) dbUpdateHandler(updateTimeout time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// pass body to database handler
-		err := bot.db.Upsert(r.Body)
+		res, err := bot.db.Upsert(r.Body, upsertMode(r))
+		if !bot.writeUpdateResult(w, "DB Update Handler", res, err) {
+			return
+		}
+
+		bot.informUpdate(updateTimeout, res.Changes)
+	})
+}
+
+// upsertMode returns upsert mode requested by the client.
+// By default whole update is rejected if any record is invalid,
+// 'mode=partial' query parameter allows to upsert valid records only
+func upsertMode(r *http.Request) botDB.UpsertMode {
+	if r.URL.Query().Get("mode") == "partial" {
+		return botDB.Partial
+	}
+	return botDB.Strict
+}
+
+// writeUpdateResult writes response of the database update
+// with rejected records. Reports if the update was successful
+func (bot *Bot) writeUpdateResult(w http.ResponseWriter, handler string,
+	res botDB.UpdateResult, err error) bool {
+	rejected := res.Rejected
+
+	switch {
+	case errors.Is(err, botDB.ErrInvalidRecords):
+		bot.logger.Printf("[%s] -> [%s: rejected=%d]", handler, dbUpdateRejected, len(rejected))
+		writeJSON(w, dbUpdateResponse{Response: dbUpdateRejected, Rejected: rejected},
+			http.StatusUnprocessableEntity)
+		return false
+
+	case err != nil:
+		bot.logger.Printf("[%s] -> [due updating records: err=%v]", handler, err)
+		writeJSON(w, dbUpdateResponse{Response: dbUpdateFailure, Rejected: rejected},
+			http.StatusInternalServerError)
+		return false
+	}
+
+	bot.logger.Printf("[%s] -> [%s: rejected=%d conflicts=%d]", handler, dbUpdateSuccess,
+		len(rejected), len(res.Conflicts))
+	writeJSON(w, dbUpdateResponse{Response: dbUpdateSuccess, Rejected: rejected,
+		Conflicts: res.Conflicts}, http.StatusOK)
+	return true
+}
+
+// dbSyncHandler receives digests of the client records
+// and answers with registry numbers the client needs to send
+func (bot *Bot) dbSyncHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need, err := bot.db.Stale(r.Body)
 		if err != nil {
-			bot.logger.Printf(" | [DB Update Handler] -> [due updating records: err=%v]", err)
-			writeResponse(w, dbUpdateFailure, http.StatusInternalServerError)
+			bot.logger.Printf("[DB Sync Handler] -> [due comparing records: err=%v]", err)
+			writeResponse(w, dbSyncFailure, http.StatusInternalServerError)
 			return
 		}
 
-		go func() {
-			select {
-			// inform to update channel
-			case bot.dbUpd <- struct{}{}:
-				// or wait for a timeout and go off
-			case <-time.After(updateTimeout):
-				return
-			}
-		}()
-
-		bot.logger.Printf("[DB Update Handler] -> [%s]", dbUpdateSuccess)
-		writeResponse(w, dbUpdateSuccess, http.StatusOK)
+		if need == nil {
+			need = []string{}
+		}
+
+		bot.logger.Printf("[DB Sync Handler] -> [stale records: %d]", len(need))
+		writeJSON(w, map[string][]string{
+			"need": need,
+		}, http.StatusOK)
 	})
 }
+
+// dbDeltaHandler applies incremental update
+// i.e. changed records and tombstones
+func (bot *Bot) dbDeltaHandler(updateTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := bot.db.ApplyDelta(r.Body, upsertMode(r))
+		if !bot.writeUpdateResult(w, "DB Delta Handler", res, err) {
+			return
+		}
+
+		bot.informUpdate(updateTimeout, res.Changes)
+	})
+}
+
+// informUpdate lets the notifier know that the database
+// was updated and what was changed. Updates are queued while
+// the notifier is busy, the batch is dropped only if the queue
+// is still full after the timeout. Nothing is sent without notifier
+func (bot *Bot) informUpdate(updateTimeout time.Duration, changes []botDB.Change) {
+	if bot.dbUpd == nil {
+		return
+	}
+
+	go func() {
+		select {
+		// inform to update channel
+		case bot.dbUpd <- changes:
+			// or wait for a timeout and go off
+		case <-time.After(updateTimeout):
+			bot.logger.Printf("[DB Update] -> [notifier is busy, update is dropped: changes=%d]", len(changes))
+		}
+	}()
+}
@@ -1,11 +1,15 @@
 package bot
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	botDB "tbot/pkg/db"
 	"tbot/pkg/db/memdb"
 	"testing"
 	"time"
@@ -16,7 +20,7 @@ func TestBot_dbUpdateHandler(t *testing.T) {
 	// set up database update handler
 	// with mocked database handler
 	dbm := memdb.New(false)
-	upd := make(chan struct{})
+	upd := make(chan []botDB.Change)
 	defer close(upd)
 	logger := log.New(io.Discard, "", 0)
 	tb := Bot{
@@ -27,11 +31,16 @@ func TestBot_dbUpdateHandler(t *testing.T) {
 
 	timeout := 1000 * time.Millisecond
 	h := tb.headersMiddleware(tb.enforceJsonMiddleware(tb.dbUpdateHandler(timeout)))
-	req := httptest.NewRequest(http.MethodPost, "http://test.com/", nil)
-	req.Header["Content-Type"] = []string{"application/json"}
+	newReq := func() *http.Request {
+		body := jsonBody([]botDB.PurchaseRecord{testRecord("0000000000000000001", time.Now())}, t)
+		req := httptest.NewRequest(http.MethodPost, "http://test.com/", body)
+		req.Header["Content-Type"] = []string{"application/json"}
+		return req
+	}
 
 	t.Run("good_request", func(t *testing.T) {
 
+		req := newReq()
 		w := httptest.NewRecorder()
 		h.ServeHTTP(w, req)
 
@@ -57,7 +66,7 @@ func TestBot_dbUpdateHandler(t *testing.T) {
 		tb.db = memdb.New(true)
 
 		w := httptest.NewRecorder()
-		h.ServeHTTP(w, req)
+		h.ServeHTTP(w, newReq())
 
 		resp := w.Result()
 
@@ -83,6 +92,99 @@ func TestBot_dbUpdateHandler(t *testing.T) {
 	})
 }
 
+func TestBot_dbSyncHandler(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	tb := Bot{
+		db:     memdb.New(false),
+		logger: logger,
+	}
+
+	h := tb.headersMiddleware(tb.enforceJsonMiddleware(tb.dbSyncHandler()))
+
+	t.Run("good_request", func(t *testing.T) {
+
+		// the mock has no hash, unknown record is needed too
+		body := jsonBody([]botDB.RecordDigest{
+			{RegistryNumber: memdb.MockPurchase.RegistryNumber, Hash: ""},
+			{RegistryNumber: "0000000000000000001", Hash: "abc"},
+		}, t)
+		req := httptest.NewRequest(http.MethodPost, "http://test.com/sync", body)
+		req.Header["Content-Type"] = []string{"application/json"}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert("Bot.dbSyncHandler()", resp.StatusCode, http.StatusOK, t)
+
+		var r map[string][]string
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatalf("Bot.dbSyncHandler() error=%v", err)
+		}
+
+		assert("Bot.dbSyncHandler()", len(r["need"]), 1, t)
+		assert("Bot.dbSyncHandler()", r["need"][0], "0000000000000000001", t)
+	})
+
+	t.Run("bad_request_db", func(t *testing.T) {
+
+		// this time we expect error
+		tb.db = memdb.New(true)
+
+		req := httptest.NewRequest(http.MethodPost, "http://test.com/sync", jsonBody([]botDB.RecordDigest{}, t))
+		req.Header["Content-Type"] = []string{"application/json"}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert("Bot.dbSyncHandler()", resp.StatusCode, http.StatusInternalServerError, t)
+
+		r := decodeResponse("Bot.dbSyncHandler()", resp.Body, t)
+
+		assert("Bot.dbSyncHandler()", r["response"], dbSyncFailure, t)
+	})
+}
+
+func TestBot_dbDeltaHandler(t *testing.T) {
+
+	upd := make(chan []botDB.Change)
+	defer close(upd)
+	logger := log.New(io.Discard, "", 0)
+	tb := Bot{
+		db:     memdb.New(false),
+		logger: logger,
+		dbUpd:  upd,
+	}
+
+	timeout := 1000 * time.Millisecond
+	h := tb.headersMiddleware(tb.enforceJsonMiddleware(tb.dbDeltaHandler(timeout)))
+
+	body := jsonBody(botDB.Delta{
+		Records:    []botDB.PurchaseRecord{testRecord("0000000000000000001", time.Now())},
+		Tombstones: []string{memdb.MockPurchase.RegistryNumber},
+	}, t)
+	req := httptest.NewRequest(http.MethodPost, "http://test.com/delta", body)
+	req.Header["Content-Type"] = []string{"application/json"}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	select {
+	case <-upd:
+	case <-time.After(timeout):
+		t.Fatal("Bot.dbDeltaHandler() expected to receive update msg, got nothing")
+	}
+
+	resp := w.Result()
+
+	assert("Bot.dbDeltaHandler()", resp.StatusCode, http.StatusOK, t)
+
+	r := decodeResponse("Bot.dbDeltaHandler()", resp.Body, t)
+
+	assert("Bot.dbDeltaHandler()", r["response"], dbUpdateSuccess, t)
+}
+
 func assert[T comparable](op string, got T, want T, t *testing.T) {
 	if got != want {
 		t.Fatalf("%s got=%v, want=%v", op, got, want)
@@ -97,3 +199,78 @@ func decodeResponse(op string, body io.ReadCloser, t *testing.T) map[string]stri
 	}
 	return r
 }
+
+// jsonBody returns v encoded as the request body
+func jsonBody(v any, t *testing.T) io.Reader {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error=%v", err)
+	}
+	return bytes.NewReader(b)
+}
+
+// testRecord returns the valid incoming
+// record with applications deadline at collecting
+func testRecord(num string, collecting time.Time) botDB.PurchaseRecord {
+	return botDB.PurchaseRecord{
+		RegistryNumber:      num,
+		PurchaseSubject:     "поставка бумаги",
+		PurchaseSubjectAbbr: "ПБ",
+		PurchaseType:        "ЭА",
+		Region:              "Тверская",
+		CustomerType:        "ГУ",
+		CollectingDateTime:  collecting,
+		MaxPrice:            100000,
+	}
+}
+
+// upsert puts the records to the mock database
+func upsert(d *memdb.MemDB, t *testing.T, recs ...botDB.PurchaseRecord) {
+	rc := io.NopCloser(jsonBody(recs, t))
+	if _, err := d.Upsert(rc, botDB.Strict); err != nil {
+		t.Fatalf("MemDB.Upsert() error=%v", err)
+	}
+}
+
+func TestBot_informUpdate(t *testing.T) {
+	var b syncBuffer
+	tb := Bot{logger: log.New(&b, "", 0), dbUpd: make(chan []botDB.Change, 1)}
+	changes := []botDB.Change{{RegistryNumber: "0000000000000000001"}}
+
+	// the queued update waits for the busy notifier
+	tb.informUpdate(time.Millisecond, changes)
+	// the update doesn't fit into the queue and is dropped
+	tb.informUpdate(time.Millisecond, changes)
+
+	deadline := time.After(time.Second)
+	for !strings.Contains(b.String(), "update is dropped") {
+		select {
+		case <-deadline:
+			t.Fatalf("Bot.informUpdate() expected dropped update to be logged, got %q", b.String())
+		case <-time.After(time.Millisecond):
+		}
+	}
+	assert("Bot.informUpdate()", len(tb.dbUpd), 1, t)
+
+	// nothing is sent without notifier
+	nb := Bot{logger: log.New(io.Discard, "", 0)}
+	nb.informUpdate(time.Millisecond, changes)
+}
+
+// syncBuffer is the buffer safe for concurrent use
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	botDB "tbot/pkg/db"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// how many days ahead the guarantee money is summed up for the digest
+const digestMoneyDays = 7
+
+// tgDigest holds the logic of the
+// daily digest to the notification chat
+type tgDigest struct {
+	logger *log.Logger
+	clock  botDB.Clock
+	cal    botDB.Calendar
+	q      querier
+	api    *tgbotapi.BotAPI
+	chat   int64
+	at     time.Duration  // digest time since the midnight
+	links  botDB.ETPLinks // procedure pages of the ETPs
+}
+
+func newTgDigest(logger *log.Logger, clock botDB.Clock, cal botDB.Calendar, q querier,
+	api *tgbotapi.BotAPI, chat int64, at time.Duration, links botDB.ETPLinks) *tgDigest {
+	return &tgDigest{
+		logger: logger,
+		clock:  clock,
+		cal:    cal,
+		q:      q,
+		api:    api,
+		chat:   chat,
+		at:     at,
+		links:  links,
+	}
+}
+
+// parseDigestTime parses digest time of the form '09:00'
+// and returns the duration since the midnight
+func parseDigestTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("digest time must be of the form 'HH:MM': %v", err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// notify will send the digest to
+// specified telegram chat every workday
+func (d *tgDigest) notify() {
+	for {
+		now := d.clock.Now()
+		next := d.next(now)
+
+		d.logger.Printf("[Digest] -> [next digest at %s]", next.Format("02.01.2006 15:04"))
+
+		<-time.After(next.Sub(now))
+
+		if err := sendMessages(d.api, d.chat, d.messages(next)...); err != nil {
+			d.logger.Println(err)
+		}
+	}
+}
+
+// next returns the time of the next digest.
+// Weekends and holidays are skipped
+func (d *tgDigest) next(now time.Time) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(d.at)
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	for !d.cal.IsWorkday(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// messages builds the digest: today's auctions, applications
+// due by the next workday and the guarantee money for the week
+func (d *tgDigest) messages(day time.Time) []message {
+	header := mdReplacer.Replace(fmt.Sprintf("☀️ *Доброе утро!* Сводка на %s\n\n", day.Format("02.01.2006")))
+
+	recs, err := d.q.Query(0, botDB.TodayAuction, botDB.TodayGo)
+	if err != nil {
+		d.logger.Printf("[Digest] -> [error due fetching records: %v]", err)
+		return plain(header + errorMsg)
+	}
+
+	money, err := d.q.Query(digestMoneyDays, botDB.FutureMoney)
+	if err != nil {
+		d.logger.Printf("[Digest] -> [error due fetching records: %v]", err)
+		return plain(header + errorMsg)
+	}
+
+	msgs := buildMessages(d.links, recs...)
+	msgs[0].text = header + msgs[0].text
+
+	if len(money) > 0 {
+		msgs = append(msgs, buildMessages(d.links, money...)...)
+	}
+
+	return msgs
+}
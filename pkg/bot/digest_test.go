@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"io"
+	"log"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+)
+
+func Test_tgDigest_next(t *testing.T) {
+	at, err := parseDigestTime("09:00")
+	if err != nil {
+		t.Fatalf("parseDigestTime() error=%v", err)
+	}
+	d := newTgDigest(log.New(io.Discard, "", 0), botDB.SystemClock{}, botDB.Calendar{}, memdb.New(false), nil, 1, at, nil)
+
+	// 2022-07-04 is monday
+	monday := time.Date(2022, time.July, 4, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before_digest", monday, monday.Add(time.Hour)},
+		{"after_digest", monday.Add(time.Hour * 2), monday.AddDate(0, 0, 1).Add(time.Hour)},
+		{"friday_after_digest", monday.AddDate(0, 0, 4).Add(time.Hour * 2), monday.AddDate(0, 0, 7).Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.next(tt.now); !got.Equal(tt.want) {
+				t.Errorf("tgDigest.next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// holidays of the calendar are skipped too
+	d.cal = botDB.NewCalendar([]time.Time{monday.AddDate(0, 0, 1)})
+	want := monday.AddDate(0, 0, 2).Add(time.Hour)
+	if got := d.next(monday.Add(time.Hour * 2)); !got.Equal(want) {
+		t.Errorf("tgDigest.next() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseDigestTime(t *testing.T) {
+	if _, err := parseDigestTime("9am"); err == nil {
+		t.Fatal("parseDigestTime() expected error, got nil")
+	}
+}
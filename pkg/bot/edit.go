@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+)
+
+// edit command
+const (
+	setCmd   = "set"
+	unsetCmd = "unset"
+)
+
+// clearValue clears the field in the '/set' command
+const clearValue = "-"
+
+// edit message
+const (
+	invalidValueMsg = "Извини, такое значение не подходит для поля 🤷"
+	notEditedMsg    = "Это поле в боте не менялось 🤷"
+	setHelpMsg      = `*/` + setCmd + `    _ID_ _ПОЛЕ_ _ЗНАЧЕНИЕ_* меняет поле закупки` + "\n" +
+		`*/` + unsetCmd + `    _ID_ _ПОЛЕ_* снова берет поле из таблицы` + "\n" +
+		`Поля: *status* \- статус, *participant* \- наш участник, *estimation* \- расчёт, ` +
+		`*winner* \- победитель, *price* \- цена победителя` + "\n" +
+		`Значение *` + clearValue + `* очищает поле, суммы должны быть больше нуля\. Измененное в боте поле не перезаписывается ` +
+		`обновлением из таблицы, пока в таблице не будет такого же значения`
+)
+
+// editor changes the purchases on behalf of the users
+type editor interface {
+	Edit(id int64, f botDB.Field, value string, by int64) (botDB.Change, error)
+	Release(id int64, f botDB.Field) (bool, error)
+}
+
+// parseEditArgs returns purchase id and the field from the command arguments
+func parseEditArgs(args []string) (int64, botDB.Field, bool) {
+	if len(args) < 2 {
+		return 0, "", false
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", false
+	}
+	f, ok := botDB.ParseField(strings.ToLower(args[1]))
+	return id, f, ok
+}
+
+// setCmdResponse is the '/set' command handler
+func (t *tgUpdHandler) setCmdResponse(by int64, f *flags) []message {
+	id, field, ok := parseEditArgs(f.args)
+	if !ok || len(f.args) < 3 {
+		return plain(invalidArgsMsg, setHelpMsg)
+	}
+
+	value := strings.Join(f.args[2:], " ")
+	if value == clearValue {
+		value = ""
+	}
+
+	c, err := t.e.Edit(id, field, value, by)
+	switch {
+	case errors.Is(err, botDB.ErrNoRows):
+		return plain(notFoundIdMsg)
+	case errors.Is(err, botDB.ErrInvalidValue):
+		return plain(invalidValueMsg)
+	case err != nil:
+		t.logger.Printf("[Telegram] -> [due editing record %v]", err)
+		return plain(errorMsg)
+	}
+
+	return plain(mdReplacer.Replace(fmt.Sprintf("✏️ [%d] %s: %s ➡️ %s",
+		c.PurchaseId, c.Label(), emptyValue(c.Old), emptyValue(c.New))))
+}
+
+// unsetCmdResponse is the '/unset' command handler
+func (t *tgUpdHandler) unsetCmdResponse(f *flags) []message {
+	id, field, ok := parseEditArgs(f.args)
+	if !ok || len(f.args) != 2 {
+		return plain(invalidArgsMsg, setHelpMsg)
+	}
+
+	released, err := t.e.Release(id, field)
+	switch {
+	case errors.Is(err, botDB.ErrNoRows):
+		return plain(notFoundIdMsg)
+	case err != nil:
+		t.logger.Printf("[Telegram] -> [due releasing field %v]", err)
+		return plain(errorMsg)
+	case !released:
+		return plain(notEditedMsg)
+	}
+
+	return plain(mdReplacer.Replace(fmt.Sprintf("Поле '%s' закупки [%d] снова берется из таблицы 📄",
+		field.Label(), id)))
+}
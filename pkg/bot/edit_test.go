@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"io"
+	"log"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+)
+
+func Test_tgUpdHandler_setCmdResponse(t *testing.T) {
+	db := memdb.New(false)
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, db, db, nil, db, db, nil, false, nil, nil)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"value_with_spaces", []string{"1", "winner", "ООО", "Ромашка"}, "✏️ \\[1\\] Победитель: test winner ➡️ ООО Ромашка"},
+		{"cleared", []string{"1", "status", clearValue}, "✏️ \\[1\\] Статус: test status ➡️ —"},
+		{"not_found", []string{"2", "status", "идем"}, notFoundIdMsg},
+		{"unknown_field", []string{"1", "subject", "что-то"}, invalidArgsMsg},
+		{"no_value", []string{"1", "status"}, invalidArgsMsg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.setCmdResponse(1, &flags{args: tt.args})
+			assert("tgUpdHandler.setCmdResponse()", got[0].text, tt.want, t)
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// exportFormat is the file format of the listing export
+type exportFormat string
+
+// export format
+const (
+	noExport   exportFormat = ""
+	csvExport  exportFormat = "csv"
+	xlsxExport exportFormat = "xlsx"
+)
+
+// exportColumn is the column of the exported listing. Value is
+// either string, int64, float64, sql.NullFloat64 or time.Time.
+// Nullable numbers are blank only when there is no value
+type exportColumn struct {
+	header string
+	value  func(p *botDB.PurchaseRecord) any
+}
+
+// purchaseColumns are all fields of the purchase
+var purchaseColumns = []exportColumn{
+	{"ID", func(p *botDB.PurchaseRecord) any { return p.PurchaseId }},
+	{"Реестровый номер", func(p *botDB.PurchaseRecord) any { return p.RegistryNumber }},
+	{"Предмет", func(p *botDB.PurchaseRecord) any { return p.PurchaseSubject }},
+	{"Код", func(p *botDB.PurchaseRecord) any { return p.PurchaseSubjectAbbr }},
+	{"Тип закупки", func(p *botDB.PurchaseRecord) any { return p.PurchaseType }},
+	{"Подача", func(p *botDB.PurchaseRecord) any { return p.CollectingDateTime }},
+	{"Рассмотрение", func(p *botDB.PurchaseRecord) any { return p.ApprovalDateTimeSql.Time }},
+	{"Аукцион", func(p *botDB.PurchaseRecord) any { return p.BiddingDateTimeSql.Time }},
+	{"Регион", func(p *botDB.PurchaseRecord) any { return p.Region }},
+	{"Заказчик", func(p *botDB.PurchaseRecord) any { return p.CustomerType }},
+	{"НМЦК", func(p *botDB.PurchaseRecord) any { return p.MaxPrice }},
+	{"Обеспечение заявки", func(p *botDB.PurchaseRecord) any { return p.ApplicationGuaranteeSql }},
+	{"Обеспечение контракта", func(p *botDB.PurchaseRecord) any { return p.ContractGuaranteeSql }},
+	{"Статус", func(p *botDB.PurchaseRecord) any { return p.StatusSql.String }},
+	{"Участник", func(p *botDB.PurchaseRecord) any { return p.OurParticipantsSql.String }},
+	{"Расчёт", func(p *botDB.PurchaseRecord) any { return p.EstimationSql }},
+	{"Площадка", func(p *botDB.PurchaseRecord) any { return p.EtpSql.String }},
+	{"Победитель", func(p *botDB.PurchaseRecord) any { return p.WinnerSql.String }},
+	{"Цена победителя", func(p *botDB.PurchaseRecord) any { return p.WinnerPriceSql }},
+	{"Участники", func(p *botDB.PurchaseRecord) any { return p.ParticipantsSql.String }},
+}
+
+// moneyColumns are the fields of the guarantee money records
+var moneyColumns = []exportColumn{
+	{"Участник", func(p *botDB.PurchaseRecord) any { return p.OurParticipantsSql.String }},
+	{"Статус", func(p *botDB.PurchaseRecord) any { return p.StatusSql.String }},
+	{"Обеспечение заявки", func(p *botDB.PurchaseRecord) any { return p.ApplicationGuaranteeSql }},
+}
+
+// exportTable is the listing of the records of the same kind
+type exportTable struct {
+	name string // file name without extension
+	cols []exportColumn
+	recs []botDB.PurchaseRecord
+}
+
+// exportTables splits the records by their kind,
+// guarantee money records have their own columns
+func exportTables(name string, recs []botDB.PurchaseRecord) []exportTable {
+	purchases := exportTable{name: name, cols: purchaseColumns}
+	money := exportTable{name: name + "_обеспечения", cols: moneyColumns}
+
+	for i := range recs {
+		if recs[i].QueryType == botDB.FutureMoney {
+			money.recs = append(money.recs, recs[i])
+			continue
+		}
+		purchases.recs = append(purchases.recs, recs[i])
+	}
+
+	var res []exportTable
+	for _, t := range []exportTable{purchases, money} {
+		if len(t.recs) > 0 {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// export returns the records of the query as documents
+// of the format instead of the text messages
+func (t *tgUpdHandler) export(name string, format exportFormat, daysLimit int, opts ...botDB.QueryOpt) []message {
+	recs, err := t.q.Query(daysLimit, opts...)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching records %v]", err)
+		return plain(errorMsg)
+	}
+	if len(recs) == 0 {
+		return buildMessages(t.links)
+	}
+
+	var msgs []message
+	for _, tbl := range exportTables(name, recs) {
+		var data []byte
+		switch format {
+		case csvExport:
+			data, err = buildCSV(tbl)
+		default:
+			data, err = buildXLSX(tbl)
+		}
+		if err != nil {
+			t.logger.Printf("[Telegram] -> [due exporting records %v]", err)
+			return plain(errorMsg)
+		}
+
+		msgs = append(msgs, message{
+			text: fmt.Sprintf("📎 Записей: *%d*", len(tbl.recs)),
+			doc:  &tgbotapi.FileBytes{Name: tbl.name + "." + string(format), Bytes: data},
+		})
+	}
+	return msgs
+}
+
+// exportName returns the file name of the command export
+func exportName(cmd string, now time.Time) string {
+	return fmt.Sprintf("%s_%s", cmd, now.Format("2006-01-02"))
+}
+
+// buildCSV renders the table the way Excel opens it
+// with russian locale: BOM, semicolon separator
+// and decimal comma
+func buildCSV(tbl exportTable) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("\ufeff")
+
+	w := csv.NewWriter(&b)
+	w.Comma = ';'
+	w.UseCRLF = true
+
+	row := make([]string, len(tbl.cols))
+	for i := range tbl.cols {
+		row[i] = tbl.cols[i].header
+	}
+	if err := w.Write(row); err != nil {
+		return nil, err
+	}
+
+	for i := range tbl.recs {
+		for j := range tbl.cols {
+			row[j] = csvValue(tbl.cols[j].value(&tbl.recs[i]))
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', 2, 64), ".", ",", 1)
+	case sql.NullFloat64:
+		if !v.Valid {
+			return ""
+		}
+		return csvValue(v.Float64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("02.01.2006 15:04")
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xlsx cell style as in the styles part
+const (
+	xlsxTextStyle   = 0
+	xlsxHeaderStyle = 1
+	xlsxDateStyle   = 2
+	xlsxMoneyStyle  = 3
+)
+
+// xlsx package parts
+const (
+	xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`
+	xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Закупки" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`
+	xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="dd.mm.yyyy hh:mm"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="4">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`</cellXfs></styleSheet>`
+)
+
+// buildXLSX renders the table as the single sheet workbook.
+// Strings are inline, so there is no shared strings part
+func buildXLSX(tbl exportTable) ([]byte, error) {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	sheet.WriteString(`<row r="1">`)
+	for j := range tbl.cols {
+		writeXLSXCell(&sheet, 1, j, tbl.cols[j].header, xlsxHeaderStyle)
+	}
+	sheet.WriteString(`</row>`)
+
+	for i := range tbl.recs {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+2)
+		for j := range tbl.cols {
+			writeXLSXCell(&sheet, i+2, j, tbl.cols[j].value(&tbl.recs[i]), xlsxTextStyle)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	for _, p := range parts {
+		w, err := z.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeXLSXCell writes the cell of the value type.
+// Empty values are skipped to keep the cells blank
+func writeXLSXCell(b *bytes.Buffer, row, col int, v any, style int) {
+	ref := xlsxColumn(col) + strconv.Itoa(row)
+
+	switch v := v.(type) {
+	case int64:
+		fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, v)
+	case float64:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxMoneyStyle,
+			strconv.FormatFloat(v, 'f', -1, 64))
+	case sql.NullFloat64:
+		if v.Valid {
+			writeXLSXCell(b, row, col, v.Float64, style)
+		}
+	case time.Time:
+		if !v.IsZero() {
+			fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxDateStyle,
+				strconv.FormatFloat(xlsxDate(v), 'f', -1, 64))
+		}
+	case string:
+		if v != "" {
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr" s="%d"><is><t xml:space="preserve">`, ref, style)
+			xml.EscapeText(b, []byte(v))
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+}
+
+// xlsxColumn returns the letters of the column, 0 is 'A'
+func xlsxColumn(col int) string {
+	var s []byte
+	for col++; col > 0; col = (col - 1) / 26 {
+		s = append([]byte{byte('A' + (col-1)%26)}, s...)
+	}
+	return string(s)
+}
+
+// xlsxEpoch is the day zero of the spreadsheet dates
+var xlsxEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxDate returns the spreadsheet serial date of
+// the time, i.e. days since epoch with the time
+// of day as a fraction, in the time zone of the time
+func xlsxDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(xlsxEpoch).Hours() / 24
+}
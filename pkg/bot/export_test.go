@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"io"
+	"strings"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+)
+
+func Test_buildCSV(t *testing.T) {
+	p := memdb.MockPurchase
+	p.MaxPrice = 1234.5
+	// records come in the time zone of the purchases
+	p.CollectingDateTime = time.Date(2022, time.July, 5, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	data, err := buildCSV(exportTable{cols: purchaseColumns, recs: []botDB.PurchaseRecord{p}})
+	if err != nil {
+		t.Fatalf("buildCSV() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\r\n")
+	assert("buildCSV() header", strings.HasPrefix(lines[0], "ID;Реестровый номер;"), true, t)
+	assert("buildCSV() price", strings.Contains(lines[1], ";1234,50;"), true, t)
+	assert("buildCSV() date", strings.Contains(lines[1], ";05.07.2022 10:00;"), true, t)
+}
+
+func Test_csvValue(t *testing.T) {
+	assert("csvValue() zero", csvValue(sql.NullFloat64{Valid: true}), "0,00", t)
+	assert("csvValue() null", csvValue(sql.NullFloat64{}), "", t)
+	assert("csvValue() float", csvValue(12.5), "12,50", t)
+}
+
+func Test_writeXLSXCell(t *testing.T) {
+	var b bytes.Buffer
+	writeXLSXCell(&b, 2, 0, sql.NullFloat64{Valid: true}, xlsxTextStyle)
+	assert("writeXLSXCell() zero", b.String(), `<c r="A2" s="3"><v>0</v></c>`, t)
+
+	b.Reset()
+	writeXLSXCell(&b, 2, 0, sql.NullFloat64{}, xlsxTextStyle)
+	assert("writeXLSXCell() null", b.String(), "", t)
+}
+
+func Test_buildXLSX(t *testing.T) {
+	data, err := buildXLSX(exportTable{cols: purchaseColumns, recs: []botDB.PurchaseRecord{memdb.MockPurchase}})
+	if err != nil {
+		t.Fatalf("buildXLSX() error = %v", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("buildXLSX() is not a zip: %v", err)
+	}
+
+	var sheet string
+	for _, f := range z.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(b)
+	}
+
+	assert("buildXLSX() parts", len(z.File), 6, t)
+	assert("buildXLSX() header", strings.Contains(sheet, `<c r="B1" t="inlineStr" s="1"><is><t xml:space="preserve">Реестровый номер</t></is></c>`), true, t)
+	assert("buildXLSX() id", strings.Contains(sheet, `<c r="A2"><v>1</v></c>`), true, t)
+}
+
+func Test_xlsxColumn(t *testing.T) {
+	assert("xlsxColumn()", xlsxColumn(0), "A", t)
+	assert("xlsxColumn()", xlsxColumn(25), "Z", t)
+	assert("xlsxColumn()", xlsxColumn(26), "AA", t)
+	assert("xlsxColumn()", xlsxColumn(701), "ZZ", t)
+}
+
+func Test_xlsxDate(t *testing.T) {
+	// midday in Moscow is taken as is
+	assert("xlsxDate()", xlsxDate(time.Date(2022, time.July, 5, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))), 44747.5, t)
+}
+
+func Test_exportTables(t *testing.T) {
+	money := memdb.MockPurchase
+	money.QueryType = botDB.FutureMoney
+
+	got := exportTables("f", []botDB.PurchaseRecord{memdb.MockPurchase, money, memdb.MockPurchase})
+	assert("exportTables()", len(got), 2, t)
+	assert("exportTables() purchases", len(got[0].recs), 2, t)
+	assert("exportTables() money", len(got[1].recs), 1, t)
+	assert("exportTables() money name", got[1].name, "f_обеспечения", t)
+}
+
+func Test_flags_exportFormat(t *testing.T) {
+	f, err := parseFlags([]string{"-" + xlsxKey, "-" + auctionKey})
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+	assert("flags.exportFormat()", f.exportFormat(), xlsxExport, t)
+}
@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// calendar command
+const icsCmd = "ics"
+
+// calendar feed handler message
+const calendarFailure = "unable to build calendar"
+
+// calendar message
+const noEventsMsg = "У этой закупки нет дат для календаря 🤷"
+
+// how many days back the calendar feed keeps the events
+const feedHistoryDays = 30
+
+// iCalendar format
+const (
+	icsTimeLayout = "20060102T150405Z"
+	icsLineLen    = 75 // the longest content line in octets
+	icsProdID     = "-//torgi-contracts-bot//RU"
+	icsSummaryLen = 100 // the subject is cut in the event summary
+)
+
+// icsEvent is the purchase event in the calendar
+type icsEvent struct {
+	ev       event
+	at       time.Time
+	duration time.Duration
+	title    string
+}
+
+// icsEvents returns the calendar events of the purchase
+func icsEvents(p *botDB.PurchaseRecord) []icsEvent {
+	var evs []icsEvent
+	if !p.CollectingDateTime.IsZero() {
+		evs = append(evs, icsEvent{deadlineEvent, p.CollectingDateTime, 0, "Окончание подачи заявок"})
+	}
+	if p.BiddingDateTimeSql.Valid {
+		evs = append(evs, icsEvent{auctionEvent, p.BiddingDateTimeSql.Time, time.Hour, "Аукцион"})
+	}
+	return evs
+}
+
+// buildCalendar renders the events of the purchases
+// as RFC 5545 calendar, now is the time stamp of the events
+func buildCalendar(name string, recs []botDB.PurchaseRecord, now time.Time) []byte {
+	var b strings.Builder
+
+	line := func(name, value string) { writeICSLine(&b, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", icsProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", icsText(name))
+
+	stamp := now.UTC().Format(icsTimeLayout)
+
+	for i := range recs {
+		p := &recs[i]
+		for _, e := range icsEvents(p) {
+			line("BEGIN", "VEVENT")
+			line("UID", fmt.Sprintf("%d-%s@torgi-contracts-bot", p.PurchaseId, e.ev))
+			line("DTSTAMP", stamp)
+			line("DTSTART", e.at.UTC().Format(icsTimeLayout))
+			if e.duration > 0 {
+				line("DTEND", e.at.Add(e.duration).UTC().Format(icsTimeLayout))
+			}
+			line("SUMMARY", icsText(fmt.Sprintf("%s [%d]: %s", e.title, p.PurchaseId,
+				cut(p.PurchaseSubject, icsSummaryLen))))
+			line("DESCRIPTION", icsText(icsDescription(p)))
+			if u := p.EISURL(); u != "" {
+				line("URL", u)
+			}
+			line("END", "VEVENT")
+		}
+	}
+
+	line("END", "VCALENDAR")
+
+	return []byte(b.String())
+}
+
+// icsDescription returns the details of the purchase in the event
+func icsDescription(p *botDB.PurchaseRecord) string {
+	return fmt.Sprintf("Реестровый номер: %s\nРегион: %s\nПлощадка: %s\nСтатус: %s",
+		p.RegistryNumber, emptyValue(p.Region), emptyValue(p.EtpSql.String), emptyValue(p.StatusSql.String))
+}
+
+// icsText escapes the value of the text property
+var icsText = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace
+
+// cut returns at most n runes of s
+func cut(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// writeICSLine writes the content line folded to the lines
+// of at most icsLineLen octets without splitting the runes
+func writeICSLine(b *strings.Builder, l string) {
+	n := 0
+	for _, r := range l {
+		size := utf8.RuneLen(r)
+		if n+size > icsLineLen {
+			// continuation line starts with the space
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+}
+
+// calendarHandler serves the calendar feed of the purchases.
+// The feed is filtered by 'participant' and 'region' query parameters
+func (bot *Bot) calendarHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := bot.clock.Now()
+		f := botDB.FeedFilter{
+			Participant: r.URL.Query().Get("participant"),
+			Region:      r.URL.Query().Get("region"),
+			Since:       now.AddDate(0, 0, -feedHistoryDays),
+		}
+
+		recs, err := bot.db.Feed(f)
+		if err != nil {
+			bot.logger.Printf("[Calendar Handler] -> [due fetching records: err=%v]", err)
+			writeResponse(w, calendarFailure, http.StatusInternalServerError)
+			return
+		}
+
+		bot.logger.Printf("[Calendar Handler] -> [records: %d]", len(recs))
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buildCalendar("Торги", recs, now))
+	})
+}
+
+// icsCmdResponse is the '/ics' command handler
+func (t *tgUpdHandler) icsCmdResponse(f *flags) []message {
+	if len(f.args) != 1 {
+		return plain(invalidArgsMsg)
+	}
+	id, err := strconv.ParseInt(f.args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return plain(invalidArgsMsg)
+	}
+
+	p, err := t.q.QueryRow(id)
+	if err != nil {
+		if err == botDB.ErrNoRows {
+			return plain(notFoundIdMsg)
+		}
+		t.logger.Printf("[Telegram] -> [due fetching record %v]", err)
+		return plain(errorMsg)
+	}
+
+	if len(icsEvents(&p)) == 0 {
+		return plain(noEventsMsg)
+	}
+
+	name := fmt.Sprintf("Закупка %d", p.PurchaseId)
+	return []message{{
+		text: fmt.Sprintf("📅 Закупка *\\[%d\\]*", p.PurchaseId),
+		doc: &tgbotapi.FileBytes{Name: fmt.Sprintf("purchase_%d.ics", p.PurchaseId),
+			Bytes: buildCalendar(name, []botDB.PurchaseRecord{p}, t.clock.Now())},
+	}}
+}
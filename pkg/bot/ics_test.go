@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func Test_writeICSLine(t *testing.T) {
+	var b strings.Builder
+	writeICSLine(&b, "DESCRIPTION:"+strings.Repeat("я", 60))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert("writeICSLine() lines", len(lines), 2, t)
+	for _, l := range lines {
+		if len(l) > icsLineLen || !utf8.ValidString(l) {
+			t.Errorf("writeICSLine() line %q is %d octets long", l, len(l))
+		}
+	}
+	assert("writeICSLine() continuation", strings.HasPrefix(lines[1], " "), true, t)
+}
+
+func Test_icsText(t *testing.T) {
+	assert("icsText()", icsText("a,b;c\\d\ne"), `a\,b\;c\\d\ne`, t)
+}
+
+func TestBot_calendarHandler(t *testing.T) {
+	d := memdb.New(false)
+	upsert(d, t, testRecord("0000000000000000001", time.Now().Add(24*time.Hour)))
+	tb := Bot{db: d, logger: log.New(io.Discard, "", 0), clock: botDB.SystemClock{}}
+	h := tb.headersMiddleware(tb.calendarHandler())
+
+	t.Run("good_request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://test.com/calendar.ics?region=Тверская", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert("Bot.calendarHandler()", resp.StatusCode, http.StatusOK, t)
+		assert("Bot.calendarHandler()", resp.Header.Get("Content-Type"), "text/calendar; charset=utf-8", t)
+		assert("Bot.calendarHandler()", strings.HasPrefix(string(body), "BEGIN:VCALENDAR\r\n"), true, t)
+		assert("Bot.calendarHandler()", strings.Contains(string(body), "UID:2-deadline@torgi-contracts-bot\r\n"), true, t)
+		// the mock purchase is long gone
+		assert("Bot.calendarHandler()", strings.Contains(string(body), "UID:1-"), false, t)
+	})
+
+	t.Run("db_error", func(t *testing.T) {
+		tb := Bot{db: memdb.New(true), logger: log.New(io.Discard, "", 0), clock: botDB.SystemClock{}}
+		w := httptest.NewRecorder()
+		tb.calendarHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://test.com/calendar.ics", nil))
+
+		assert("Bot.calendarHandler()", w.Result().StatusCode, http.StatusInternalServerError, t)
+	})
+}
+
+func Test_buildCalendar(t *testing.T) {
+	p := memdb.MockPurchase
+	p.CollectingDateTime = time.Date(2022, time.July, 5, 7, 0, 0, 0, time.UTC)
+	p.BiddingDateTimeSql.Valid = false
+
+	got := string(buildCalendar("Торги", []botDB.PurchaseRecord{p, p}, time.Now()))
+	assert("buildCalendar() events", strings.Count(got, "BEGIN:VEVENT"), 2, t)
+	assert("buildCalendar() start", strings.Contains(got, "DTSTART:20220705T070000Z\r\n"), true, t)
+}
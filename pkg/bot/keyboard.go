@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// callback query action
+const (
+	infoAction    = "i" // show the purchase
+	historyAction = "h" // show the purchase history
+	remindAction  = "r" // remind the user about the purchase events
+)
+
+// how many purchase buttons are in the keyboard row
+const keyboardRowLen = 4
+
+// callback query answer
+const (
+	remindAnswer   = "Напомню в личных сообщениях 🔔"
+	noRemindAnswer = "Напоминания отключены 🤷"
+	notFoundAnswer = "Не нашел такую закупку 🤷"
+	badQueryAnswer = "Не понял запрос 🤷"
+)
+
+// watcher is responsible for the personal
+// reminders about the specific purchases
+type watcher interface {
+	watch(id, chat int64)
+	forget(chat int64)
+}
+
+// callbackData builds the data of the callback query button
+func callbackData(action string, id int64) string {
+	return fmt.Sprintf("%s:%d", action, id)
+}
+
+// parseCallbackData returns action and purchase
+// id from the data of the callback query
+func parseCallbackData(data string) (string, int64, error) {
+	action, v, ok := strings.Cut(data, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid callback data '%s'", data)
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid callback data '%s': %v", data, err)
+	}
+	return action, id, nil
+}
+
+// keyboard returns the keyboard of the purchases in the message.
+// Single purchase in the general view gets the detail actions,
+// otherwise there is a button per purchase to show it
+func keyboard(recs []botDB.PurchaseRecord) *tgbotapi.InlineKeyboardMarkup {
+	if len(recs) == 1 && recs[0].QueryType == botDB.General && recs[0].PurchaseId != 0 {
+		return detailsKeyboard(&recs[0])
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+
+	for i := range recs {
+		// i.e. guarantee money records are not the purchases
+		if recs[i].PurchaseId == 0 {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📝 %d", recs[i].PurchaseId), callbackData(infoAction, recs[i].PurchaseId)))
+		if len(row) == keyboardRowLen {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &kb
+}
+
+// detailsKeyboard returns the actions on the purchase
+func detailsKeyboard(p *botDB.PurchaseRecord) *tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📜 История", callbackData(historyAction, p.PurchaseId)),
+		tgbotapi.NewInlineKeyboardButtonData("🔔 Напомнить", callbackData(remindAction, p.PurchaseId)),
+	)}
+	if u := p.EISURL(); u != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("🌐 Открыть в ЕИС", u)))
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &kb
+}
+
+// handleCallback processes the pressed keyboard button
+func (t *tgUpdHandler) handleCallback(cq *tgbotapi.CallbackQuery) {
+	// buttons are only sent with our messages
+	if cq.Message == nil {
+		t.answer(cq, badQueryAnswer)
+		return
+	}
+
+	chat := cq.Message.Chat.ID
+
+	// unknown chat can only ask for the access
+	if strings.HasPrefix(cq.Data, requestAction+":") {
+		t.logger.Printf("[Telegram] -> [access request: chatID=%d from=%v]", chat, cq.From)
+		t.requestAccess(cq)
+		return
+	}
+
+	// restricted access
+	role := t.role(chat)
+	if role == botDB.NoRole {
+		t.logger.Printf("[Telegram] -> [chatID=%d from=%v; restricted access]", chat, cq.From)
+		t.answer(cq, notAllowedMsg)
+		return
+	}
+
+	t.logger.Printf("[Telegram] -> [callback: chatID=%d from=%v data=%s]", chat, cq.From, cq.Data)
+
+	switch {
+	case isAccessDecision(cq.Data):
+		// the decision is of the admin who pressed the button
+		if !t.userRole(cq.From).Allows(botDB.Admin) {
+			t.answer(cq, forbiddenMsg)
+			return
+		}
+		t.decideAccess(cq)
+		return
+	case cq.Data == noopAction:
+		t.answer(cq, "")
+		return
+	case strings.HasPrefix(cq.Data, pageAction+":"):
+		t.turnPage(cq)
+		return
+	}
+
+	answer, msgs := t.callbackResponse(cq)
+	t.answer(cq, answer)
+
+	if err := sendMessages(t.api, chat, msgs...); err != nil {
+		t.logger.Println(err)
+	}
+}
+
+// callbackResponse returns the answer to the callback
+// query and the messages to the chat
+func (t *tgUpdHandler) callbackResponse(cq *tgbotapi.CallbackQuery) (string, []message) {
+	action, id, err := parseCallbackData(cq.Data)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due parsing callback %v]", err)
+		return badQueryAnswer, nil
+	}
+
+	p, err := t.q.QueryRow(id)
+	if err != nil {
+		if err == botDB.ErrNoRows {
+			return notFoundAnswer, nil
+		}
+		t.logger.Printf("[Telegram] -> [due fetching record %v]", err)
+		return "", plain(errorMsg)
+	}
+
+	switch action {
+	case infoAction:
+		return "", buildMessages(t.links, p)
+	case historyAction:
+		return "", t.historyResponse(p)
+	case remindAction:
+		if t.w == nil {
+			return noRemindAnswer, nil
+		}
+		// user's id is the id of the private chat with the user
+		t.w.watch(p.PurchaseId, int64(cq.From.ID))
+		return remindAnswer, nil
+	default:
+		return badQueryAnswer, nil
+	}
+}
+
+// answer stops the progress of the pressed button
+// and shows the text to the user if it is not empty
+func (t *tgUpdHandler) answer(cq *tgbotapi.CallbackQuery, text string) {
+	if t.api == nil {
+		return
+	}
+	if _, err := t.api.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, text)); err != nil {
+		t.logger.Printf("[Telegram] -> [due answering callback %v]", err)
+	}
+}
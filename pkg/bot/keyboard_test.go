@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"io"
+	"log"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func Test_parseCallbackData(t *testing.T) {
+	action, id, err := parseCallbackData(callbackData(historyAction, 42))
+	if err != nil {
+		t.Fatalf("parseCallbackData() error = %v", err)
+	}
+	assert("action", action, historyAction, t)
+	assert("id", id, int64(42), t)
+
+	for _, data := range []string{"", "h", "h:abc"} {
+		if _, _, err = parseCallbackData(data); err == nil {
+			t.Errorf("parseCallbackData(%q) expected error, got nil", data)
+		}
+	}
+}
+
+func Test_keyboard(t *testing.T) {
+	// general view gets the detail actions and the link
+	kb := keyboard([]botDB.PurchaseRecord{memdb.MockPurchase})
+	if kb == nil {
+		t.Fatal("keyboard() = nil, want details keyboard")
+	}
+	assert("details rows", len(kb.InlineKeyboard), 2, t)
+	assert("history data", *kb.InlineKeyboard[0][0].CallbackData,
+		callbackData(historyAction, memdb.MockPurchase.PurchaseId), t)
+
+	// listing gets a button per purchase
+	recs := make([]botDB.PurchaseRecord, keyboardRowLen+1)
+	for i := range recs {
+		recs[i] = memdb.MockPurchase
+		recs[i].PurchaseId = int64(i + 1)
+		recs[i].QueryType = botDB.TodayAuction
+	}
+	kb = keyboard(recs)
+	if kb == nil {
+		t.Fatal("keyboard() = nil, want purchase buttons")
+	}
+	assert("list rows", len(kb.InlineKeyboard), 2, t)
+	assert("info data", *kb.InlineKeyboard[1][0].CallbackData,
+		callbackData(infoAction, int64(keyboardRowLen+1)), t)
+
+	// records without purchases have no buttons
+	if kb = keyboard([]botDB.PurchaseRecord{{QueryType: botDB.FutureMoney}}); kb != nil {
+		t.Errorf("keyboard() = %v, want nil", kb)
+	}
+}
+
+type memWatcher map[int64]int64
+
+func (w memWatcher) watch(id, chat int64) { w[id] = chat }
+
+func (w memWatcher) forget(chat int64) {
+	for id := range w {
+		if w[id] == chat {
+			delete(w, id)
+		}
+	}
+}
+
+func Test_tgUpdHandler_callbackResponse(t *testing.T) {
+	w := memWatcher{}
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), w, memdb.New(false), memdb.New(false), nil, false, nil, nil)
+
+	cq := &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 7}, Data: callbackData(remindAction, 1)}
+
+	answer, msgs := h.callbackResponse(cq)
+	assert("answer", answer, remindAnswer, t)
+	assert("messages", len(msgs), 0, t)
+	assert("watcher", w[memdb.MockPurchase.PurchaseId], int64(7), t)
+
+	cq.Data = "garbage"
+	answer, _ = h.callbackResponse(cq)
+	assert("bad answer", answer, badQueryAnswer, t)
+
+	// update without message must not panic
+	h.handleUpdate(&tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "1"}})
+	h.handleUpdate(&tgbotapi.Update{})
+}
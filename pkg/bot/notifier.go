@@ -1,7 +1,10 @@
 package bot
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"sync"
 	botDB "tbot/pkg/db"
 	"time"
 
@@ -12,121 +15,617 @@ import (
 // we must send notification
 const howLongBefore = time.Minute * 10
 
-const utcOffset = time.Hour * 3
-
 const idlingDuration = time.Hour * 24
 
+// Schedule holds how long before the purchase
+// events the reminders must be sent. Every
+// lead time is a separate reminder stage
+type Schedule struct {
+	Auction  []time.Duration // before the bidding
+	Deadline []time.Duration // before the end of the applications collecting
+}
+
+// DefaultSchedule is the reminder schedule
+// used when nothing else is configured
+var DefaultSchedule = Schedule{
+	Auction:  []time.Duration{howLongBefore},
+	Deadline: []time.Duration{time.Hour * 24, time.Hour * 3},
+}
+
+// event is the kind of purchase
+// event we send notifications about
+type event int
+
+// purchase event
+const (
+	auctionEvent  event = iota // bidding time
+	deadlineEvent              // end of the applications collecting
+)
+
+// String returns string representation of event
+func (e event) String() string {
+	return []string{"auction", "deadline"}[e]
+}
+
+// parseEvent returns event by its string representation
+func parseEvent(s string) (event, bool) {
+	for _, e := range []event{auctionEvent, deadlineEvent} {
+		if e.String() == s {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// CatchUpPolicy defines what to do with reminders
+// that were missed i.e. due to application restart
+type CatchUpPolicy int
+
+// catch up policy
+const (
+	CatchUpLate CatchUpPolicy = iota // send missed reminder with the marker
+	CatchUpSkip                      // don't send missed reminder
+)
+
+// missedAfter is how late reminder must
+// be to be considered as the missed one
+const missedAfter = time.Minute
+
+// retryAfter is how long the notifier waits
+// before loading the records again after the failure
+const retryAfter = time.Minute
+
+// ledger keeps track of the sent reminders,
+// so they survive application restarts
+type ledger interface {
+	SentNotifications(since time.Time) ([]botDB.SentNotification, error)
+	PruneSentNotifications(before time.Time) (int64, error)
+	MarkSent(botDB.SentNotification) error
+}
+
+// watchList keeps the private chats reminded about
+// the purchase events, so they survive application restarts
+type watchList interface {
+	Watch(id, chat int64) error
+	Watchers() ([]botDB.Watcher, error)
+	PruneWatchers(before time.Time) (int64, error)
+}
+
+// reminder is the single notification
+// about the purchase event
+type reminder struct {
+	rec  botDB.PurchaseRecord
+	ev   event
+	at   time.Time     // time of the event
+	lead time.Duration // how long before the event we notify
+}
+
+// due returns time when notification must be sent
+func (r *reminder) due() time.Time { return r.at.Add(-r.lead) }
+
+// stage identifies the reminder of the purchase event.
+// Event time is the part of the stage, so the rescheduled
+// event will be reminded about again
+type stage struct {
+	id   int64
+	ev   event
+	lead time.Duration
+	at   int64
+}
+
+// stage returns the reminder stage
+func (r *reminder) stage() stage {
+	return stage{id: r.rec.PurchaseId, ev: r.ev, lead: r.lead, at: r.at.Unix()}
+}
+
+// sentNotification returns the ledger entry of the reminder
+func (r *reminder) sentNotification() botDB.SentNotification {
+	return botDB.SentNotification{PurchaseId: r.rec.PurchaseId,
+		Event: r.ev.String(), Lead: r.lead, EventTime: r.at}
+}
+
 // tgNotifier holds the notification logic
 type tgNotifier struct {
 	logger *log.Logger
+	clock  botDB.Clock
 	q      querier
+	l      ledger        // nil ledger means in-memory tracking only
+	s      subscriptions // nil means there are no personal subscriptions
+	wl     watchList     // nil means watchers are kept in memory only
 	api    *tgbotapi.BotAPI
-	recs   []botDB.PurchaseRecord
+	rems   []reminder // pending reminders sorted by due time
+	loaded time.Time  // when reminders were set up
+	retry  time.Time  // when the failed load is retried, zero if the last one succeeded
+	leads  map[event][]time.Duration
+	fired  map[stage]time.Time // stages we already notified about with the event time
+	policy CatchUpPolicy
 	chat   int64
-	upd    <-chan struct{}
+	kinds  map[botDB.ChangeKind]bool // announced change kinds
+	upd    <-chan []botDB.Change
+	links  botDB.ETPLinks // procedure pages of the ETPs
+
+	mu       sync.Mutex
+	watchers map[int64]map[int64]bool // private chats to remind by purchase id
 }
 
-func newTgNotifier(logger *log.Logger, q querier,
-	api *tgbotapi.BotAPI, chat int64, upd <-chan struct{}) *tgNotifier {
-	return &tgNotifier{logger: logger,
-		q:    q,
-		api:  api,
-		recs: nil,
-		chat: chat,
-		upd:  upd,
+func newTgNotifier(logger *log.Logger, clock botDB.Clock, q querier, l ledger, s subscriptions, wl watchList,
+	api *tgbotapi.BotAPI, chat int64,
+	sch Schedule, policy CatchUpPolicy, kinds []botDB.ChangeKind, upd <-chan []botDB.Change,
+	links botDB.ETPLinks) *tgNotifier {
+	if len(sch.Auction) == 0 {
+		sch.Auction = DefaultSchedule.Auction
+	}
+	if len(sch.Deadline) == 0 {
+		sch.Deadline = DefaultSchedule.Deadline
 	}
+	n := &tgNotifier{logger: logger,
+		clock: clock,
+		q:     q,
+		l:     l,
+		s:     s,
+		wl:    wl,
+		api:   api,
+		rems:  nil,
+		leads: map[event][]time.Duration{
+			auctionEvent:  sortedLeads(sch.Auction),
+			deadlineEvent: sortedLeads(sch.Deadline),
+		},
+		fired:    make(map[stage]time.Time),
+		policy:   policy,
+		chat:     chat,
+		kinds:    make(map[botDB.ChangeKind]bool, len(kinds)),
+		upd:      upd,
+		links:    links,
+		watchers: make(map[int64]map[int64]bool),
+	}
+	for _, k := range kinds {
+		n.kinds[k] = true
+	}
+	return n
 }
 
 // notify will send notification to specified telegram chat
-// close to event time
+// close to event time. Failed loads of the records don't stop
+// it, previous reminders are kept and the load is retried
 func (n *tgNotifier) notify() {
 	// set today's records
-	if err := n.todays(); err != nil {
-		n.logger.Printf("[Notifier] -> [error due fetching records: %v]", err)
-		return
-	}
+	n.reload()
 
 	// get remaining time to the closest
-	// event and record index of that event
-	i, d := n.nearestEventTime()
-	n.logNearestEventTime(i, d)
+	// event and reminder index of that event
+	i, d := n.next()
 
 	for {
 		select {
 		// if databse was updated we need to
 		// update notifier records and
 		// remaining time to next event
-		case <-n.upd:
+		case changes := <-n.upd:
+			// tell what was changed
+			n.announce(changes)
+
 			// update records
-			if err := n.todays(); err != nil {
-				n.logger.Printf("[Notifier] -> [error due fetching records: %v]", err)
-				return
-			}
+			n.reload()
 			n.logger.Println("[Notifier] -> [got update]")
 			// update remaining time to next event
-			// and record index of that event
-			i, d = n.nearestEventTime()
-			n.logNearestEventTime(i, d)
+			// and reminder index of that event
+			i, d = n.next()
 
 		// if remaining time is expired, we notify
 		case <-time.After(d):
-			// in case we don't have any active records
-			if i < 0 {
-				continue
+			switch {
+			// in case we don't have any active
+			// reminders or the day is changed
+			// we need fresh records
+			case n.reloadDue():
+				n.reload()
+			case i >= 0 && !n.rems[i].due().After(n.clock.Now()):
+				r := n.rems[i]
+				n.remind(&r)
+
+				// dequeue the reminder we notified about
+				n.rems = append(n.rems[:i], n.rems[i+1:]...)
 			}
+			// update remaining time to next event
+			// and reminder index of that event
+			i, d = n.next()
+		}
+	}
+
+}
+
+// reload sets up the reminders from the fresh records.
+// If it fails the previous reminders are kept
+// and the load is retried a bit later
+func (n *tgNotifier) reload() {
+	if err := n.todays(); err != nil {
+		n.logger.Printf("[Notifier] -> [error due fetching records, retry in %s: %v]", retryAfter, err)
+		n.retry = n.clock.Now().Add(retryAfter)
+		return
+	}
+	n.retry = time.Time{}
+}
+
+// reloadDue reports if the reminders need the fresh records
+func (n *tgNotifier) reloadDue() bool {
+	if !n.retry.IsZero() {
+		return !n.clock.Now().Before(n.retry)
+	}
+	return len(n.rems) == 0 || n.dayChanged()
+}
 
-			if err := send(n.api, n.chat, buildMessages(n.recs[i])...); err != nil {
+// next returns the index of the nearest reminder and
+// the time to wait for it or for the retry of the load
+func (n *tgNotifier) next() (int, time.Duration) {
+	i, d := n.nearestEventTime()
+	if !n.retry.IsZero() {
+		d = minDuration(d, n.retry.Sub(n.clock.Now()))
+		if d < 0 {
+			d = 0
+		}
+	}
+	n.logNearestEventTime(i, d)
+	return i, d
+}
+
+// remind sends the reminder according to catch up
+// policy and remembers it, so it won't be sent again
+func (n *tgNotifier) remind(r *reminder) {
+	late := n.clock.Now().Sub(r.due()) > missedAfter
+
+	switch {
+	case late && n.policy == CatchUpSkip:
+		n.logger.Printf("[Notifier] -> [missed reminder skipped: id=%d event=%s lead=%s]",
+			r.rec.PurchaseId, r.ev, r.lead)
+	default:
+		msgs := buildMessages(n.links, r.rec)
+		msgs[0].text = reminderHeader(r, late) + msgs[0].text
+		if err := sendMessages(n.api, n.chat, msgs...); err != nil {
+			n.logger.Println(err)
+		}
+		for _, chat := range n.recipients(&r.rec, n.subscriptions()) {
+			if err := sendMessages(n.api, chat, msgs...); err != nil {
 				n.logger.Println(err)
 			}
+		}
+	}
 
-			// dequeue the record we notified about
-			if len(n.recs) > 1 {
-				n.recs = n.recs[i+1:]
-			} else {
-				n.recs = nil // if it's last
-			}
-			// update remaining time to next event
-			// and record index of that event
-			i, d = n.nearestEventTime()
-			n.logNearestEventTime(i, d)
+	// remember the stage, so it won't
+	// be sent again after reload or restart
+	n.fired[r.stage()] = r.at
+	if n.l != nil {
+		if err := n.l.MarkSent(r.sentNotification()); err != nil {
+			n.logger.Printf("[Notifier] -> [error due marking reminder as sent: %v]", err)
+		}
+	}
+}
+
+// watch makes the reminders about the purchase to be
+// sent to the private chat as well. Watchers are stored
+// until the purchase events are past
+func (n *tgNotifier) watch(id, chat int64) {
+	if n.wl != nil {
+		if err := n.wl.Watch(id, chat); err != nil {
+			n.logger.Printf("[Notifier] -> [error due saving watcher: %v]", err)
 		}
 	}
 
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.watchers[id] == nil {
+		n.watchers[id] = make(map[int64]bool)
+	}
+	n.watchers[id][chat] = true
 }
 
-// nearestEventTime returns nearest remaining time
-// to next event and also an inner slice index of nearest event record.
-// If there are no records then -1 index will be returned
-func (n *tgNotifier) nearestEventTime() (int, time.Duration) {
+// forget stops the reminders to the private chat. Stored
+// watchers of the chat are removed along with its access
+func (n *tgNotifier) forget(chat int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-	now := time.Now().Add(utcOffset)
+	for _, chats := range n.watchers {
+		delete(chats, chat)
+	}
+}
 
-	for i := range n.recs {
-		t := n.recs[i].BiddingDateTimeSql.Time
+// loadWatchers forgets the watchers of the past events
+// and reloads the rest of them from the watch list.
+// The last watchers are kept if the load fails
+func (n *tgNotifier) loadWatchers(now time.Time) error {
+	if n.wl == nil {
+		return nil
+	}
+	if _, err := n.wl.PruneWatchers(now); err != nil {
+		n.logger.Printf("[Notifier] -> [error due pruning watchers: %v]", err)
+	}
+	ws, err := n.wl.Watchers()
+	if err != nil {
+		return err
+	}
 
-		if t.Add(-howLongBefore).After(now) {
-			return i, t.Add(-howLongBefore).Sub(now)
+	watchers := make(map[int64]map[int64]bool)
+	for _, w := range ws {
+		if watchers[w.PurchaseId] == nil {
+			watchers[w.PurchaseId] = make(map[int64]bool)
 		}
+		watchers[w.PurchaseId][w.Chat] = true
+	}
+
+	n.mu.Lock()
+	n.watchers = watchers
+	n.mu.Unlock()
+
+	return nil
+}
+
+// recipients returns the private chats watching the purchase
+// or subscribed to it. Notification chat is not the one of them
+func (n *tgNotifier) recipients(p *botDB.PurchaseRecord, subs []botDB.Subscription) []int64 {
+	seen := map[int64]bool{n.chat: true}
+	var chats []int64
 
-		if t.After(now) {
-			return i, 0
+	n.mu.Lock()
+	for chat := range n.watchers[p.PurchaseId] {
+		if !seen[chat] {
+			seen[chat] = true
+			chats = append(chats, chat)
 		}
 	}
-	return -1, idlingDuration
+	n.mu.Unlock()
+
+	for i := range subs {
+		if !seen[subs[i].Chat] && subs[i].Match(p) {
+			seen[subs[i].Chat] = true
+			chats = append(chats, subs[i].Chat)
+		}
+	}
+
+	return chats
+}
+
+// subscriptions returns personal subscriptions of all chats
+func (n *tgNotifier) subscriptions() []botDB.Subscription {
+	if n.s == nil {
+		return nil
+	}
+	subs, err := n.s.Subscriptions(0)
+	if err != nil {
+		n.logger.Printf("[Notifier] -> [error due fetching subscriptions: %v]", err)
+	}
+	return subs
+}
+
+// nearestEventTime returns nearest remaining time
+// to next notification and also an inner slice index of nearest reminder.
+// If there are no reminders then -1 index will be returned
+func (n *tgNotifier) nearestEventTime() (int, time.Duration) {
+	now := n.clock.Now()
+
+	// we wake up at the day change
+	// anyway to look for new events
+	tomorrow := startOfDay(now).AddDate(0, 0, 1)
+
+	if len(n.rems) == 0 {
+		return -1, minDuration(idlingDuration, tomorrow.Sub(now))
+	}
+
+	if d := n.rems[0].due().Sub(now); d > 0 {
+		return 0, minDuration(d, tomorrow.Sub(now))
+	}
+	return 0, 0
+}
+
+// dayChanged reports if reminders
+// were set up in the previous day
+func (n *tgNotifier) dayChanged() bool {
+	now := n.clock.Now()
+	return now.YearDay() != n.loaded.YearDay() || now.Year() != n.loaded.Year()
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
 }
 
-// todays gets the today's records from the DB.
+// todays gets the records of the upcoming events from the DB
+// and sets up reminders for them. Reminders are replaced
+// only if everything is loaded.
 // The db returns records in asc order
 func (n *tgNotifier) todays() error {
-	var err error
-	n.recs, err = n.q.Query(0, botDB.TodayAuction)
+	auctions, err := n.q.Query(leadDays(n.leads[auctionEvent]), botDB.UpcomingAuction)
 	if err != nil {
 		return err
 	}
 
+	deadlines, err := n.q.Query(leadDays(n.leads[deadlineEvent]), botDB.UpcomingGo)
+	if err != nil {
+		return err
+	}
+
+	now := n.clock.Now()
+
+	// stages sent before restart, the past events are
+	// forgotten. Pruning is the clean up, so its failure
+	// doesn't keep the reminders from being set up
+	var sent []botDB.SentNotification
+	if n.l != nil {
+		if _, err = n.l.PruneSentNotifications(now); err != nil {
+			n.logger.Printf("[Notifier] -> [error due pruning sent notifications: %v]", err)
+		}
+		if sent, err = n.l.SentNotifications(now); err != nil {
+			return err
+		}
+	}
+
+	// personal reminders are the extra, so the
+	// failed load doesn't keep the others from being set up
+	if err = n.loadWatchers(now); err != nil {
+		n.logger.Printf("[Notifier] -> [error due loading watchers, the last ones are kept: %v]", err)
+	}
+
+	n.loaded = now
+	n.rems = nil
+
+	// forget stages of the past events
+	for s, at := range n.fired {
+		if !at.After(now) {
+			delete(n.fired, s)
+		}
+	}
+
+	for i := range sent {
+		ev, ok := parseEvent(sent[i].Event)
+		if !ok {
+			continue
+		}
+		s := stage{id: sent[i].PurchaseId, ev: ev, lead: sent[i].Lead, at: sent[i].EventTime.Unix()}
+		n.fired[s] = sent[i].EventTime
+	}
+
+	for i := range auctions {
+		n.schedule(auctions[i], auctionEvent, auctions[i].BiddingDateTimeSql.Time, now)
+	}
+	for i := range deadlines {
+		n.schedule(deadlines[i], deadlineEvent, deadlines[i].CollectingDateTime, now)
+	}
+
+	sort.SliceStable(n.rems, func(i, j int) bool {
+		return n.rems[i].due().Before(n.rems[j].due())
+	})
+
 	return nil
 }
 
+// schedule sets up reminders of the purchase event.
+// Stages that were already sent are skipped along with
+// all earlier ones. Stages which time has come are dropped
+// except the latest one, so it will be sent right away
+func (n *tgNotifier) schedule(rec botDB.PurchaseRecord, ev event, at, now time.Time) {
+	if !at.After(now) {
+		return
+	}
+
+	// leads are sorted in ascending order
+	// i.e. from the latest stage to the earliest
+	for _, l := range n.leads[ev] {
+		r := reminder{rec: rec, ev: ev, at: at, lead: l}
+		if _, ok := n.fired[r.stage()]; ok {
+			break
+		}
+		n.rems = append(n.rems, r)
+		if !r.due().After(now) {
+			break
+		}
+	}
+}
+
+// sortedLeads returns copy of leads in ascending order
+func sortedLeads(leads []time.Duration) []time.Duration {
+	res := append([]time.Duration(nil), leads...)
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// leadDays returns how many days ahead
+// we need to look to cover provided leads
+func leadDays(leads []time.Duration) int {
+	var max time.Duration
+	for _, l := range leads {
+		if l > max {
+			max = l
+		}
+	}
+	return int((max + time.Hour*24 - 1) / (time.Hour * 24))
+}
+
+// reminderHeader returns escaped first
+// line of the reminder message. Late
+// reminders are marked as such
+func reminderHeader(r *reminder, late bool) string {
+	var h string
+	switch r.ev {
+	case deadlineEvent:
+		h = fmt.Sprintf("🔔 *До окончания подачи заявок %s*\n", leadString(r.lead))
+	default:
+		h = fmt.Sprintf("🔔 *До аукциона %s*\n", leadString(r.lead))
+	}
+	if late {
+		h += "⏱ _Напоминание отправлено с опозданием_\n"
+	}
+	return mdReplacer.Replace(h + "\n")
+}
+
+// leadString returns human readable lead time
+func leadString(d time.Duration) string {
+	switch {
+	case d >= time.Hour*24 && d%(time.Hour*24) == 0:
+		return fmt.Sprintf("%d д", d/(time.Hour*24))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d ч", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d мин", d/time.Minute)
+	}
+}
+
+// announce sends messages about
+// the changes of purchases of the allowed kinds
+func (n *tgNotifier) announce(changes []botDB.Change) {
+	if msgs := buildChangeMessages(changes, n.kinds); len(msgs) > 0 {
+		if err := send(n.api, n.chat, msgs...); err != nil {
+			n.logger.Println(err)
+		}
+	}
+
+	for chat, pc := range n.personalChanges(changes) {
+		if msgs := buildChangeMessages(pc, n.kinds); len(msgs) > 0 {
+			if err := send(n.api, chat, msgs...); err != nil {
+				n.logger.Println(err)
+			}
+		}
+	}
+}
+
+// personalChanges returns the changes of the purchases
+// by the private chats watching or subscribed to them
+func (n *tgNotifier) personalChanges(changes []botDB.Change) map[int64][]botDB.Change {
+	subs := n.subscriptions()
+
+	n.mu.Lock()
+	watched := len(n.watchers) > 0
+	n.mu.Unlock()
+
+	if len(subs) == 0 && !watched {
+		return nil
+	}
+
+	res := make(map[int64][]botDB.Change)
+
+	for i := 0; i < len(changes); {
+		// changes are grouped by purchase
+		j := i
+		for j < len(changes) && changes[j].PurchaseId == changes[i].PurchaseId {
+			j++
+		}
+
+		p, err := n.q.QueryRow(changes[i].PurchaseId)
+		if err != nil {
+			n.logger.Printf("[Notifier] -> [error due fetching record: %v]", err)
+		} else {
+			for _, chat := range n.recipients(&p, subs) {
+				res[chat] = append(res[chat], changes[i:j]...)
+			}
+		}
+
+		i = j
+	}
+
+	return res
+}
+
 func (n *tgNotifier) logNearestEventTime(idx int, nt time.Duration) {
 	if idx < 0 {
 		n.logger.Printf("[Notifier] -> [no nearest events; next check in %s]", nt)
@@ -134,6 +633,6 @@ func (n *tgNotifier) logNearestEventTime(idx int, nt time.Duration) {
 	}
 
 	n.logger.Printf("[Notifier] -> [nearest event is |%s, %s, %s, %s|; remaining time to notification %s]",
-		n.recs[idx].RegistryNumber, n.recs[idx].PurchaseType,
-		n.recs[idx].EtpSql.String, n.recs[idx].BiddingDateTimeSql.Time, nt)
+		n.rems[idx].rec.RegistryNumber, n.rems[idx].rec.PurchaseType,
+		n.rems[idx].rec.EtpSql.String, n.rems[idx].at, nt)
 }
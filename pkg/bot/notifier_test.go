@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"errors"
+	"io"
+	"log"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+)
+
+func Test_tgNotifier_schedule(t *testing.T) {
+	n := newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, nil, nil, 1,
+		Schedule{Deadline: []time.Duration{time.Hour * 24, time.Hour * 3}}, CatchUpLate, nil, nil, nil)
+
+	now := time.Date(2022, time.July, 5, 12, 0, 0, 0, time.UTC)
+
+	t.Run("all_stages_ahead", func(t *testing.T) {
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, deadlineEvent, now.Add(time.Hour*48), now)
+
+		assert("tgNotifier.schedule()", len(n.rems), 2, t)
+	})
+
+	t.Run("latest_passed_stage_kept", func(t *testing.T) {
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, deadlineEvent, now.Add(time.Hour*2), now)
+
+		assert("tgNotifier.schedule()", len(n.rems), 1, t)
+		assert("tgNotifier.schedule()", n.rems[0].lead, time.Hour*3, t)
+	})
+
+	t.Run("fired_stage_skipped", func(t *testing.T) {
+		at := now.Add(time.Hour * 48)
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, deadlineEvent, at, now)
+		// the earliest stage was sent
+		n.fired[n.rems[1].stage()] = at
+
+		// after reload only the remaining stage is expected
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, deadlineEvent, at, now)
+
+		assert("tgNotifier.schedule()", len(n.rems), 1, t)
+		assert("tgNotifier.schedule()", n.rems[0].lead, time.Hour*3, t)
+
+		// rescheduled event is reminded about again
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, deadlineEvent, at.Add(time.Hour), now)
+
+		assert("tgNotifier.schedule()", len(n.rems), 2, t)
+	})
+
+	t.Run("event_passed", func(t *testing.T) {
+		n.rems = nil
+		n.schedule(memdb.MockPurchase, auctionEvent, now.Add(-time.Minute), now)
+
+		assert("tgNotifier.schedule()", len(n.rems), 0, t)
+	})
+}
+
+func Test_leadDays(t *testing.T) {
+	assert("leadDays()", leadDays([]time.Duration{time.Hour * 3}), 1, t)
+	assert("leadDays()", leadDays([]time.Duration{time.Hour * 3, time.Hour * 24}), 1, t)
+	assert("leadDays()", leadDays([]time.Duration{time.Hour * 25}), 2, t)
+}
+
+// memLedger is in-memory ledger for testing purposes
+type memLedger []botDB.SentNotification
+
+func (l *memLedger) SentNotifications(_ time.Time) ([]botDB.SentNotification, error) {
+	return *l, nil
+}
+
+func (l *memLedger) PruneSentNotifications(before time.Time) (int64, error) {
+	kept := (*l)[:0]
+	for _, sn := range *l {
+		if !sn.EventTime.Before(before) {
+			kept = append(kept, sn)
+		}
+	}
+	n := int64(len(*l) - len(kept))
+	*l = kept
+	return n, nil
+}
+
+func (l *memLedger) MarkSent(sn botDB.SentNotification) error {
+	*l = append(*l, sn)
+	return nil
+}
+
+func Test_tgNotifier_remind(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	clock := botDB.ClockFunc(func() time.Time { return now })
+
+	l := &memLedger{}
+	n := newTgNotifier(log.New(io.Discard, "", 0), clock, memdb.New(false), l, nil, nil, nil, 1,
+		DefaultSchedule, CatchUpSkip, nil, nil, nil)
+
+	// reminder which is due half an hour ago is
+	// missed and must be skipped without sending
+	r := reminder{rec: memdb.MockPurchase, ev: auctionEvent,
+		at: now.Add(time.Minute * 30), lead: time.Hour}
+	n.remind(&r)
+
+	if _, ok := n.fired[r.stage()]; !ok {
+		t.Fatalf("tgNotifier.remind() expected stage %v to be fired", r.stage())
+	}
+	assert("tgNotifier.remind()", len(*l), 1, t)
+
+	// entries of the past events are pruned on reload
+	*l = append(*l, botDB.SentNotification{PurchaseId: 2, Event: auctionEvent.String(),
+		Lead: time.Hour, EventTime: now.Add(-time.Hour)})
+
+	// ledger entries survive the restart
+	n = newTgNotifier(log.New(io.Discard, "", 0), clock, memdb.New(false), l, nil, nil, nil, 1,
+		DefaultSchedule, CatchUpSkip, nil, nil, nil)
+	if err := n.todays(); err != nil {
+		t.Fatalf("tgNotifier.todays() error=%v", err)
+	}
+	if _, ok := n.fired[r.stage()]; !ok {
+		t.Fatalf("tgNotifier.todays() expected stage %v to be loaded from ledger", r.stage())
+	}
+	assert("tgNotifier.todays()", len(*l), 1, t)
+}
+
+// brokenLedger fails to prune the sent notifications
+type brokenLedger struct{ memLedger }
+
+func (l *brokenLedger) PruneSentNotifications(_ time.Time) (int64, error) {
+	return 0, errors.New("prune failed")
+}
+
+func Test_tgNotifier_reload(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	clock := botDB.ClockFunc(func() time.Time { return now })
+
+	// failed pruning doesn't keep the reminders from being set up
+	n := newTgNotifier(log.New(io.Discard, "", 0), clock, memdb.New(false), &brokenLedger{}, nil, nil, nil, 1,
+		DefaultSchedule, CatchUpSkip, nil, nil, nil)
+	if err := n.todays(); err != nil {
+		t.Fatalf("tgNotifier.todays() error=%v", err)
+	}
+
+	r := reminder{rec: memdb.MockPurchase, ev: auctionEvent, at: now.Add(time.Hour), lead: time.Minute}
+	n.rems = []reminder{r}
+
+	// failed load keeps the reminders and is retried later
+	n.q = memdb.New(true)
+	n.reload()
+	assert("tgNotifier.reload() reminders", len(n.rems), 1, t)
+	assert("tgNotifier.reload() retry", n.retry, now.Add(retryAfter), t)
+	assert("tgNotifier.reloadDue()", n.reloadDue(), false, t)
+	_, d := n.next()
+	assert("tgNotifier.next()", d, retryAfter, t)
+
+	now = now.Add(retryAfter)
+	assert("tgNotifier.reloadDue()", n.reloadDue(), true, t)
+
+	n.q = memdb.New(false)
+	n.reload()
+	assert("tgNotifier.reload() retry", n.retry.IsZero(), true, t)
+}
+
+// memWatchList is in-memory watch list for testing purposes.
+// Events are the times of the purchase events by id
+type memWatchList struct {
+	ws     []botDB.Watcher
+	events map[int64]time.Time
+}
+
+func (l *memWatchList) Watch(id, chat int64) error {
+	l.ws = append(l.ws, botDB.Watcher{PurchaseId: id, Chat: chat})
+	return nil
+}
+
+func (l *memWatchList) Watchers() ([]botDB.Watcher, error) {
+	return l.ws, nil
+}
+
+func (l *memWatchList) PruneWatchers(before time.Time) (int64, error) {
+	kept := l.ws[:0]
+	for _, w := range l.ws {
+		if !l.events[w.PurchaseId].Before(before) {
+			kept = append(kept, w)
+		}
+	}
+	n := int64(len(l.ws) - len(kept))
+	l.ws = kept
+	return n, nil
+}
+
+func Test_tgNotifier_loadWatchers(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	p := memdb.MockPurchase
+	wl := &memWatchList{events: map[int64]time.Time{p.PurchaseId: now.Add(time.Hour)}}
+
+	n := newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, wl, nil, 1,
+		DefaultSchedule, CatchUpLate, nil, nil, nil)
+	n.watch(p.PurchaseId, 2)
+	assert("tgNotifier.watch()", len(wl.ws), 1, t)
+
+	// watchers survive the restart
+	n = newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, wl, nil, 1,
+		DefaultSchedule, CatchUpLate, nil, nil, nil)
+	if err := n.loadWatchers(now); err != nil {
+		t.Fatalf("tgNotifier.loadWatchers() error=%v", err)
+	}
+	got := n.recipients(&p, nil)
+	assert("tgNotifier.loadWatchers()", len(got), 1, t)
+	assert("tgNotifier.loadWatchers()", got[0], int64(2), t)
+
+	// watchers of the past events are pruned
+	if err := n.loadWatchers(now.Add(2 * time.Hour)); err != nil {
+		t.Fatalf("tgNotifier.loadWatchers() error=%v", err)
+	}
+	assert("tgNotifier.loadWatchers()", len(n.recipients(&p, nil)), 0, t)
+	assert("tgNotifier.loadWatchers()", len(wl.ws), 0, t)
+}
+
+// brokenWatchList fails to load and prune the watchers
+type brokenWatchList struct{ memWatchList }
+
+func (l *brokenWatchList) Watchers() ([]botDB.Watcher, error) {
+	return nil, errors.New("load failed")
+}
+
+func (l *brokenWatchList) PruneWatchers(_ time.Time) (int64, error) {
+	return 0, errors.New("prune failed")
+}
+
+func Test_tgNotifier_loadWatchers_failed(t *testing.T) {
+	p := memdb.MockPurchase
+	n := newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, &brokenWatchList{}, nil, 1,
+		DefaultSchedule, CatchUpLate, nil, nil, nil)
+	n.watch(p.PurchaseId, 2)
+
+	// reminders are set up with the last watchers
+	if err := n.todays(); err != nil {
+		t.Fatalf("tgNotifier.todays() error=%v", err)
+	}
+	got := n.recipients(&p, nil)
+	assert("tgNotifier.todays() watchers", len(got), 1, t)
+}
+
+func Test_tgNotifier_forget(t *testing.T) {
+	n := newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, nil, nil, 1,
+		DefaultSchedule, CatchUpLate, nil, nil, nil)
+
+	p := memdb.MockPurchase
+	n.watch(p.PurchaseId, 2)
+	n.watch(p.PurchaseId, 3)
+	n.forget(2)
+
+	got := n.recipients(&p, nil)
+	assert("tgNotifier.forget()", len(got), 1, t)
+	assert("tgNotifier.forget()", got[0], int64(3), t)
+}
+
+func Test_tgNotifier_recipients(t *testing.T) {
+	n := newTgNotifier(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, nil, nil, nil, 1,
+		DefaultSchedule, CatchUpLate, nil, nil, nil)
+
+	p := memdb.MockPurchase
+	n.watch(p.PurchaseId, 2)
+	n.watch(p.PurchaseId, 1) // notification chat gets the reminders anyway
+
+	subs := []botDB.Subscription{
+		{Chat: 2},                         // already watching
+		{Chat: 3, Region: p.Region},       // matches
+		{Chat: 4, Region: "other region"}, // doesn't match
+	}
+
+	got := n.recipients(&p, subs)
+	assert("recipients", len(got), 2, t)
+	assert("watcher", got[0], int64(2), t)
+	assert("subscriber", got[1], int64(3), t)
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	botDB "tbot/pkg/db"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// callback query action
+const (
+	pageAction = "g" // show the page of the listing
+	noopAction = "x" // button does nothing i.e. page counter
+)
+
+// pageData builds the data of the page button.
+// The listing query is the part of the data, so
+// the page can be built again without any state
+// i.e. 'g:2:7:1.3' is the page 2 of the query with 7 days limit
+func pageData(page, daysLimit int, opts []botDB.QueryOpt) string {
+	o := make([]string, len(opts))
+	for i := range opts {
+		o[i] = strconv.Itoa(int(opts[i]))
+	}
+	return fmt.Sprintf("%s:%d:%d:%s", pageAction, page, daysLimit, strings.Join(o, "."))
+}
+
+// parsePageData returns the page and
+// the listing query from the page button data
+func parsePageData(data string) (int, int, []botDB.QueryOpt, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 4 || parts[0] != pageAction || parts[3] == "" {
+		return 0, 0, nil, fmt.Errorf("invalid page data '%s'", data)
+	}
+
+	page, err := strconv.Atoi(parts[1])
+	if err != nil || page < 0 {
+		return 0, 0, nil, fmt.Errorf("invalid page data '%s'", data)
+	}
+	days, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid page data '%s'", data)
+	}
+
+	var opts []botDB.QueryOpt
+	for _, v := range strings.Split(parts[3], ".") {
+		o, err := strconv.Atoi(v)
+		if err != nil || o <= int(botDB.General) || o >= int(botDB.Found) {
+			return 0, 0, nil, fmt.Errorf("invalid page data '%s'", data)
+		}
+		opts = append(opts, botDB.QueryOpt(o))
+	}
+
+	return page, days, opts, nil
+}
+
+// withPages returns the page of the messages
+// with the navigation row in its keyboard
+func withPages(msgs []message, page, daysLimit int, opts []botDB.QueryOpt) message {
+	if page >= len(msgs) {
+		page = len(msgs) - 1
+	}
+	m := msgs[page]
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", pageData(page-1, daysLimit, opts)))
+	}
+	nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+		fmt.Sprintf("%d / %d", page+1, len(msgs)), noopAction))
+	if page < len(msgs)-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", pageData(page+1, daysLimit, opts)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if m.keyboard != nil {
+		rows = append(rows, m.keyboard.InlineKeyboard...)
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(append(rows, nav)...)
+	m.keyboard = &kb
+
+	return m
+}
+
+// turnPage edits the listing message in place
+// to show the page from the pressed button
+func (t *tgUpdHandler) turnPage(cq *tgbotapi.CallbackQuery) {
+	page, days, opts, err := parsePageData(cq.Data)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due parsing callback %v]", err)
+		t.answer(cq, badQueryAnswer)
+		return
+	}
+	t.answer(cq, "")
+
+	recs, err := t.q.Query(days, opts...)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching records %v]", err)
+		return
+	}
+
+	m := withPages(buildMessages(t.links, recs...), page, days, opts)
+
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, m.text)
+	edit.ParseMode = parseMode
+	edit.ReplyMarkup = m.keyboard
+
+	if _, err = t.api.Send(edit); err != nil {
+		t.logger.Printf("[Telegram] -> [due editing message: chat=%d; err=%v]", cq.Message.Chat.ID, err)
+	}
+}
@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"reflect"
+	botDB "tbot/pkg/db"
+	"testing"
+)
+
+func Test_parsePageData(t *testing.T) {
+	opts := []botDB.QueryOpt{botDB.FutureAuction, botDB.FutureGo}
+
+	page, days, got, err := parsePageData(pageData(2, 7, opts))
+	if err != nil {
+		t.Fatalf("parsePageData() error = %v", err)
+	}
+	assert("page", page, 2, t)
+	assert("days", days, 7, t)
+	if !reflect.DeepEqual(got, opts) {
+		t.Errorf("parsePageData() opts = %v, want %v", got, opts)
+	}
+
+	for _, data := range []string{"g:1:0:", "g:-1:0:3", "g:1:0:99", "i:1"} {
+		if _, _, _, err = parsePageData(data); err == nil {
+			t.Errorf("parsePageData(%q) expected error, got nil", data)
+		}
+	}
+}
+
+func Test_withPages(t *testing.T) {
+	msgs := plain("first", "second", "third")
+	opts := []botDB.QueryOpt{botDB.Past}
+
+	tests := []struct {
+		name string
+		page int
+		nav  []string // data of the navigation buttons
+	}{
+		{"first", 0, []string{noopAction, pageData(1, 0, opts)}},
+		{"middle", 1, []string{pageData(0, 0, opts), noopAction, pageData(2, 0, opts)}},
+		{"last", 2, []string{pageData(1, 0, opts), noopAction}},
+		{"out_of_range", 5, []string{pageData(1, 0, opts), noopAction}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := withPages(msgs, tt.page, 0, opts)
+			rows := m.keyboard.InlineKeyboard
+			var nav []string
+			for _, b := range rows[len(rows)-1] {
+				nav = append(nav, *b.CallbackData)
+			}
+			if !reflect.DeepEqual(nav, tt.nav) {
+				t.Errorf("withPages() nav = %v, want %v", nav, tt.nav)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	botDB "tbot/pkg/db"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// ReportPeriod is the period of the scheduled report
+type ReportPeriod string
+
+// report period
+const (
+	WeeklyReport  ReportPeriod = "weekly"
+	MonthlyReport ReportPeriod = "monthly"
+)
+
+// ReportPeriods is the list of all report periods
+var ReportPeriods = []ReportPeriod{WeeklyReport, MonthlyReport}
+
+const (
+	// default time of the scheduled reports since the midnight
+	defaultReportAt = 9 * time.Hour
+	// how many days the '/report' command covers by default
+	reportDefaultDays = 7
+)
+
+// bounds returns the last complete period before now
+func (p ReportPeriod) bounds(now time.Time) (time.Time, time.Time) {
+	today := startOfDay(now)
+	switch p {
+	case MonthlyReport:
+		to := today.AddDate(0, 0, 1-today.Day())
+		return to.AddDate(0, -1, 0), to
+	default:
+		// week starts on monday
+		to := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return to.AddDate(0, 0, -7), to
+	}
+}
+
+// due reports if the report of the period
+// must be sent on the day
+func (p ReportPeriod) due(day time.Time) bool {
+	switch p {
+	case MonthlyReport:
+		return day.Day() == 1
+	default:
+		return day.Weekday() == time.Monday
+	}
+}
+
+// title returns the report header
+func (p ReportPeriod) title() string {
+	switch p {
+	case MonthlyReport:
+		return "Итоги месяца"
+	default:
+		return "Итоги недели"
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// tgReporter holds the logic of the scheduled
+// performance reports to the notification chat
+type tgReporter struct {
+	logger  *log.Logger
+	clock   botDB.Clock
+	q       querier
+	api     *tgbotapi.BotAPI
+	chat    int64
+	at      time.Duration // report time since the midnight
+	periods []ReportPeriod
+}
+
+func newTgReporter(logger *log.Logger, clock botDB.Clock, q querier, api *tgbotapi.BotAPI,
+	chat int64, at time.Duration, periods []ReportPeriod) *tgReporter {
+	return &tgReporter{
+		logger:  logger,
+		clock:   clock,
+		q:       q,
+		api:     api,
+		chat:    chat,
+		at:      at,
+		periods: periods,
+	}
+}
+
+// notify will send the reports to specified
+// telegram chat at the start of every period
+func (r *tgReporter) notify() {
+	for {
+		now := r.clock.Now()
+		next := r.next(now)
+
+		r.logger.Printf("[Report] -> [next report at %s]", next.Format("02.01.2006 15:04"))
+
+		<-time.After(next.Sub(now))
+
+		for _, p := range r.periods {
+			if !p.due(next) {
+				continue
+			}
+			from, to := p.bounds(next)
+			if err := send(r.api, r.chat, r.report(p.title(), from, to)); err != nil {
+				r.logger.Println(err)
+			}
+		}
+	}
+}
+
+// next returns the time of the next report
+func (r *tgReporter) next(now time.Time) time.Time {
+	t := startOfDay(now).Add(r.at)
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	for !r.due(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func (r *tgReporter) due(day time.Time) bool {
+	for _, p := range r.periods {
+		if p.due(day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *tgReporter) report(title string, from, to time.Time) string {
+	rep, err := r.q.Report(from, to)
+	if err != nil {
+		r.logger.Printf("[Report] -> [error due fetching report: %v]", err)
+		return errorMsg
+	}
+	return buildReportMessage(title, rep)
+}
+
+// buildReportMessage builds the message of the performance report
+func buildReportMessage(title string, r botDB.Report) string {
+	var b strings.Builder
+
+	// period is [from, to) so we show the last day of it
+	fmt.Fprintf(&b, "📊 *%s* %s – %s\n\n", title,
+		r.From.Format("02.01.2006"), r.To.AddDate(0, 0, -1).Format("02.01.2006"))
+
+	if r.Total.Applications == 0 && r.Total.Auctions == 0 {
+		b.WriteString("За период ничего не было 🤷")
+		return mdReplacer.Replace(b.String())
+	}
+
+	t := &r.Total
+	fmt.Fprintf(&b, "Заявки: *%d* 📝\nАукционы: *%d* ⚔️\n", t.Applications, t.Auctions)
+	fmt.Fprintf(&b, "Победы: *%d* 🏆\nПоражения: *%d*\nДоля побед: *%.1f%%*\n", t.Wins, t.Losses, t.WinRate())
+	if t.MaxPrice > 0 {
+		fmt.Fprintf(&b, "НМЦК: *%.2f ₽* 🔝\nЦена победителей: *%.2f ₽*\nСнижение: *%.1f%%* ⬇️\n",
+			t.MaxPrice, t.WinnerPrice, t.PriceDrop())
+	}
+	fmt.Fprintf(&b, "Обеспечение в работе: *%.2f ₽* 💸\n", t.Guarantee)
+
+	reportGroup(&b, "По участникам", r.ByParticipant)
+	reportGroup(&b, "По регионам", r.ByRegion)
+
+	return mdReplacer.Replace(b.String())
+}
+
+// reportGroup writes short performance of the groups
+func reportGroup(b *strings.Builder, header string, rows []botDB.ReportRow) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n*%s*\n", header)
+	for i := range rows {
+		fmt.Fprintf(b, "_%s_: заявки *%d*, аукционы *%d*, победы *%d* (*%.1f%%*)\n",
+			rows[i].Name, rows[i].Applications, rows[i].Auctions, rows[i].Wins, rows[i].WinRate())
+	}
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"io"
+	"log"
+	"strings"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+	"time"
+)
+
+func TestReportPeriod_bounds(t *testing.T) {
+	// 2022-07-06 is wednesday
+	now := time.Date(2022, time.July, 6, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		p        ReportPeriod
+		from, to time.Time
+	}{
+		{"weekly", WeeklyReport, time.Date(2022, time.June, 27, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.July, 4, 0, 0, 0, 0, time.UTC)},
+		{"monthly", MonthlyReport, time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := tt.p.bounds(now)
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("ReportPeriod.bounds() = [%v, %v), want [%v, %v)", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func Test_tgReporter_next(t *testing.T) {
+	r := newTgReporter(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), nil, 1,
+		defaultReportAt, []ReportPeriod{WeeklyReport, MonthlyReport})
+
+	// 2022-07-04 is monday
+	monday := time.Date(2022, time.July, 4, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before_report", monday, monday.Add(time.Hour)},
+		{"after_report", monday.Add(time.Hour * 2), monday.AddDate(0, 0, 7).Add(time.Hour)},
+		{"first_of_month", time.Date(2022, time.July, 28, 8, 0, 0, 0, time.UTC),
+			time.Date(2022, time.August, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.next(tt.now); !got.Equal(tt.want) {
+				t.Errorf("tgReporter.next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildReportMessage(t *testing.T) {
+	r := botDB.Report{
+		From:  time.Date(2022, time.June, 27, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2022, time.July, 4, 0, 0, 0, 0, time.UTC),
+		Total: botDB.ReportRow{Applications: 4, Auctions: 4, Wins: 1, Losses: 3, MaxPrice: 100, WinnerPrice: 90},
+	}
+
+	msg := buildReportMessage(WeeklyReport.title(), r)
+
+	for _, want := range []string{`27\.06\.2022 – 03\.07\.2022`, "Доля побед: *25\\.0%*", "Снижение: *10\\.0%*"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("buildReportMessage() = %s, want it to contain %s", msg, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"net/http"
+	"strconv"
+	botDB "tbot/pkg/db"
+	"time"
+)
+
+// retention handler message
+const (
+	retentionSuccess     = "old records were successfully deleted"
+	retentionFailure     = "unable to delete old records"
+	retentionInvalidDays = "'days' must be a positive integer"
+)
+
+// retentionHandler removes old records from the database
+// according to provided policy. The retention window
+// can be overridden by the 'days' query parameter
+func (bot *Bot) retentionHandler(rp botDB.RetentionPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := rp
+		if d := r.URL.Query().Get("days"); d != "" {
+			days, err := strconv.Atoi(d)
+			if err != nil || days <= 0 {
+				writeResponse(w, retentionInvalidDays, http.StatusBadRequest)
+				return
+			}
+			p.Days = days
+		}
+
+		n, err := bot.db.Delete(p)
+		if err != nil {
+			bot.logger.Printf("[Retention Handler] -> [due deleting records: err=%v]", err)
+			writeResponse(w, retentionFailure, http.StatusInternalServerError)
+			return
+		}
+
+		bot.logger.Printf("[Retention Handler] -> [%s: deleted=%d days=%d]", retentionSuccess, n, p.Days)
+		writeJSON(w, map[string]any{
+			"response": retentionSuccess,
+			"deleted":  n,
+		}, http.StatusOK)
+	})
+}
+
+// retention removes old records from the database
+// every interval according to provided policy
+func (bot *Bot) retention(rp botDB.RetentionPolicy, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		n, err := bot.db.Delete(rp)
+		if err != nil {
+			bot.logger.Printf("[Retention] -> [due deleting records: err=%v]", err)
+			continue
+		}
+		bot.logger.Printf("[Retention] -> [%s: deleted=%d days=%d]", retentionSuccess, n, rp.Days)
+	}
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
+	"testing"
+)
+
+func TestBot_retentionHandler(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	tb := Bot{
+		db:     memdb.New(false),
+		logger: logger,
+	}
+
+	h := tb.headersMiddleware(tb.retentionHandler(botDB.RetentionPolicy{Days: botDB.DefaultRetentionDays}))
+
+	t.Run("good_request", func(t *testing.T) {
+
+		req := httptest.NewRequest(http.MethodDelete, "http://test.com/?days=30", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert("Bot.retentionHandler()", resp.StatusCode, http.StatusOK, t)
+		assert("Bot.retentionHandler()", resp.Header.Get("Content-Type"), "application/json", t)
+
+		var r struct {
+			Response string `json:"response"`
+			Deleted  int64  `json:"deleted"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatalf("Bot.retentionHandler() error=%v", err)
+		}
+
+		assert("Bot.retentionHandler()", r.Response, retentionSuccess, t)
+		assert("Bot.retentionHandler()", r.Deleted, 1, t)
+	})
+
+	t.Run("bad_request_days", func(t *testing.T) {
+
+		req := httptest.NewRequest(http.MethodDelete, "http://test.com/?days=-1", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert("Bot.retentionHandler()", resp.StatusCode, http.StatusBadRequest, t)
+
+		r := decodeResponse("Bot.retentionHandler()", resp.Body, t)
+
+		assert("Bot.retentionHandler()", r["response"], retentionInvalidDays, t)
+	})
+
+	t.Run("bad_request_db", func(t *testing.T) {
+
+		// this time we expect error
+		tb.db = memdb.New(true)
+
+		req := httptest.NewRequest(http.MethodDelete, "http://test.com/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert("Bot.retentionHandler()", resp.StatusCode, http.StatusInternalServerError, t)
+
+		r := decodeResponse("Bot.retentionHandler()", resp.Body, t)
+
+		assert("Bot.retentionHandler()", r["response"], retentionFailure, t)
+	})
+}
@@ -2,8 +2,10 @@ package bot
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	botDB "tbot/pkg/db"
+	"unicode/utf16"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -11,71 +13,264 @@ import (
 // telegram message formatting mode
 const parseMode = "MarkdownV2"
 
+// telegram message text limit
+const maxMessageLen = 4096
+
 // bot message
 const notFoundMsg = "Похоже, что ничего нет\\.\\.\\. 🙃"
 
+// we need replacer to sanitize messages
+// for telegram markdown syntax
+var mdReplacer = strings.NewReplacer(
+	"[", "\\[", "]", "\\]", "(", "\\(", ")",
+	"\\)", "~", "\\~", "`", "\\`", ">", "\\>", "#",
+	"\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
+	"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!")
+
+// mdLink matches markdown links of the form [text](url)
+var mdLink = regexp.MustCompile(`\[([^\[\]]+)\]\((https?://[^()\\\s]+)\)`)
+
+// escape sanitizes message for telegram markdown
+// syntax keeping the links of the form [text](url)
+func escape(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdLink.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(mdReplacer.Replace(s[last:m[0]]))
+		// url is kept as is, it has neither ')' nor '\'
+		// which are the only ones to be escaped there
+		fmt.Fprintf(&b, "[%s](%s)", mdReplacer.Replace(s[m[2]:m[3]]), s[m[4]:m[5]])
+		last = m[1]
+	}
+	b.WriteString(mdReplacer.Replace(s[last:]))
+	return b.String()
+}
+
+// message is the response to the telegram
+// chat with optional inline keyboard
+type message struct {
+	text     string
+	keyboard *tgbotapi.InlineKeyboardMarkup
+	doc      *tgbotapi.FileBytes // text is the caption of the document if set
+}
+
+// plain returns messages without keyboards
+func plain(msgs ...string) []message {
+	res := make([]message, len(msgs))
+	for i := range msgs {
+		res[i].text = msgs[i]
+	}
+	return res
+}
+
 // send is helper function that is responsible
 // for sending responses to the telegram chat
 func send(api *tgbotapi.BotAPI, chatID int64, msgs ...string) error {
-	m := tgbotapi.NewMessage(chatID, "")
-	m.ParseMode = parseMode
+	return sendMessages(api, chatID, plain(msgs...)...)
+}
+
+// sendMessages sends responses along
+// with their keyboards to the telegram chat
+func sendMessages(api *tgbotapi.BotAPI, chatID int64, msgs ...message) error {
 	for i := range msgs {
-		m.Text = msgs[i]
+		if msgs[i].doc != nil {
+			d := tgbotapi.NewDocumentUpload(chatID, *msgs[i].doc)
+			d.Caption = msgs[i].text
+			d.ParseMode = parseMode
+			if _, err := api.Send(d); err != nil {
+				return fmt.Errorf("[Telegram] -> [due sending document: chat=%d; name=%s; err=%v]",
+					chatID, msgs[i].doc.Name, err)
+			}
+			continue
+		}
+
+		m := tgbotapi.NewMessage(chatID, msgs[i].text)
+		m.ParseMode = parseMode
+		if msgs[i].keyboard != nil {
+			m.ReplyMarkup = *msgs[i].keyboard
+		}
 		if _, err := api.Send(m); err != nil {
 			return fmt.Errorf("[Telegram] -> [due sending response: chat=%d; msg=%v; err=%v]",
-				chatID, msgs[i], err)
+				chatID, msgs[i].text, err)
 		}
 	}
 	return nil
 }
 
 // buildMessages is the helper function that interacts with
-// database record and builds messages for the response
-func buildMessages(recs ...botDB.PurchaseRecord) []string {
+// database record and builds messages for the response.
+// Message is split between the records if it exceeds
+// the telegram limit. Every message gets the keyboard for its purchases.
+// ETPs are linked to the procedures by the links
+func buildMessages(links botDB.ETPLinks, recs ...botDB.PurchaseRecord) []message {
 	if len(recs) == 0 {
-		return []string{notFoundMsg}
+		return plain(notFoundMsg)
 	}
 
 	var b strings.Builder
-	var msgs []string
+	var msgs []message
 	var q botDB.QueryOpt
-	// we need replacer to sanitize messages
-	// for telegram markdown syntax
-	r := strings.NewReplacer(
-		"[", "\\[", "]", "\\]", "(", "\\(", ")",
-		"\\)", "~", "\\~", "`", "\\`", ">", "\\>", "#",
-		"\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
-		"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!")
+	start := 0 // the first record of the current message
+	size := 0  // the length of the current message
+
+	// complete the current message with the records up to end
+	flush := func(end int) {
+		msgs = append(msgs, message{text: b.String(), keyboard: keyboard(recs[start:end])})
+		b.Reset()
+		start, size = end, 0
+	}
 
 	for i := range recs {
 
 		// gets info string from the record
 		// and also a query option
-		s, qr := recs[i].Info()
+		s, qr := recs[i].Info(links)
 		// query option helps us to create
 		// messages separated by type
+		s = escape(s)
+		header := escape(qr.String())
 
+		switch {
 		// if we encounter new query option
 		// then the current message is complete
-		if q != qr && i != 0 {
-			msgs = append(msgs, r.Replace(b.String()))
-
-			// reseting builder and writing
-			// new message header
-			b.Reset()
-			b.WriteString(qr.String())
-		} else if q != qr {
-			// if its first record
-			// we only write header
-			b.WriteString(qr.String())
+		case q != qr && i != 0:
+			flush(i)
+			b.WriteString(header)
+			size += textLen(header)
+		// if its first record
+		// we only write header
+		case q != qr:
+			b.WriteString(header)
+			size += textLen(header)
+		// the record doesn't fit, so it goes
+		// to the next message under the same header
+		case size+textLen(s) > maxMessageLen:
+			flush(i)
+			b.WriteString(header)
+			size += textLen(header)
 		}
 
 		b.WriteString(s)
+		size += textLen(s)
 		q = qr
 	}
 
 	// appending the last message
-	msgs = append(msgs, r.Replace(b.String()))
+	flush(len(recs))
 
 	return msgs
 }
+
+// textLen returns the length of the message text the way
+// telegram counts it i.e. in UTF-16 code units. Escaped text is
+// longer than the parsed one, so we are on the safe side
+func textLen(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// buildHistoryMessages builds the timeline
+// of the purchase changes
+func buildHistoryMessages(p botDB.PurchaseRecord, changes []botDB.Change) []string {
+	var b strings.Builder
+	var last string
+
+	b.WriteString(fmt.Sprintf("*История* 📜 *[%d]* _%s_\n", p.PurchaseId, p.RegistryNumber))
+
+	for i := range changes {
+		// changes made by one update are grouped together
+		if at := changes[i].When(); at != last {
+			b.WriteString(fmt.Sprintf("\n*%s*\n", at))
+			last = at
+		}
+		b.WriteString(fmt.Sprintf("%s: _%s_ ➡️ *%s*\n",
+			changes[i].Label(), emptyValue(changes[i].Old), emptyValue(changes[i].New)))
+	}
+
+	return []string{mdReplacer.Replace(b.String())}
+}
+
+// emptyValue returns placeholder for the empty value
+func emptyValue(v string) string {
+	if v == "" {
+		return "—"
+	}
+	return v
+}
+
+// buildChangeMessages builds one message per purchase
+// about its changes of the provided kinds
+func buildChangeMessages(changes []botDB.Change, kinds map[botDB.ChangeKind]bool) []string {
+	var msgs []string
+	var b strings.Builder
+
+	for i := 0; i < len(changes); {
+		// changes are grouped by purchase
+		j := i
+		for j < len(changes) && changes[j].PurchaseId == changes[i].PurchaseId {
+			j++
+		}
+
+		b.Reset()
+		for k := i; k < j; k++ {
+			if kinds[changes[k].Kind()] {
+				b.WriteString(changeString(changes[i:j], &changes[k]))
+			}
+		}
+
+		if b.Len() > 0 {
+			msgs = append(msgs, mdReplacer.Replace(fmt.Sprintf("🔔 *[%d]* _%s_\n%s",
+				changes[i].PurchaseId, changes[i].RegistryNumber, b.String())))
+		}
+
+		i = j
+	}
+
+	return msgs
+}
+
+// changeString returns the line about the single change.
+// Purchase changes are needed to describe the winner
+func changeString(pc []botDB.Change, c *botDB.Change) string {
+	switch c.Kind() {
+	case botDB.BiddingChange:
+		if c.New == "" {
+			return "Аукцион отменён ❌\n"
+		}
+		return fmt.Sprintf("Аукцион перенесён на *%s* ⏰\n", shortTime(c.Old, c.New))
+	case botDB.CollectingChange:
+		return fmt.Sprintf("Подача заявок перенесена на *%s* ⏳\n", shortTime(c.Old, c.New))
+	case botDB.StatusChange:
+		return fmt.Sprintf("Статус изменён: _%s_ ➡️ *%s*\n", emptyValue(c.Old), emptyValue(c.New))
+	case botDB.ParticipantChange:
+		return fmt.Sprintf("Участник: _%s_ ➡️ *%s*\n", emptyValue(c.Old), emptyValue(c.New))
+	case botDB.WinnerChange:
+		// winner and price are announced together
+		if c.IsPrice() {
+			for i := range pc {
+				if pc[i].Kind() == botDB.WinnerChange && !pc[i].IsPrice() {
+					return ""
+				}
+			}
+			return fmt.Sprintf("Цена победителя: *%s ₽* 🏁\n", emptyValue(c.New))
+		}
+		price := ""
+		for i := range pc {
+			if pc[i].IsPrice() && pc[i].New != "" {
+				price = fmt.Sprintf(", цена *%s ₽*", pc[i].New)
+			}
+		}
+		return fmt.Sprintf("Победитель определён: *%s*%s 🏁\n", emptyValue(c.New), price)
+	default:
+		return fmt.Sprintf("%s: _%s_ ➡️ *%s*\n", c.Label(), emptyValue(c.Old), emptyValue(c.New))
+	}
+}
+
+// shortTime returns only the time part of the new
+// value if the date of the event remains the same
+func shortTime(old, new string) string {
+	// values are in '02.01.2006 15:04' form
+	if len(old) == len(new) && len(new) > 10 && old[:10] == new[:10] {
+		return new[11:]
+	}
+	return emptyValue(new)
+}
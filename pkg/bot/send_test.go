@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"strings"
 	botDB "tbot/pkg/db"
 	"tbot/pkg/db/memdb"
 	"testing"
@@ -11,7 +12,7 @@ func Test_buildMessages(t *testing.T) {
 	t.Run("count_messages", func(t *testing.T) {
 
 		// one record == one message
-		res := buildMessages(memdb.MockPurchase)
+		res := buildMessages(nil, memdb.MockPurchase)
 
 		if len(res) != 1 {
 			t.Fatalf("buildMessages() got len = %d, want len = %d", len(res), 1)
@@ -23,7 +24,7 @@ func Test_buildMessages(t *testing.T) {
 		purchGo := memdb.MockPurchase
 		purchGo.QueryType = botDB.TodayGo
 
-		res = buildMessages(purchAuction, purchGo)
+		res = buildMessages(nil, purchAuction, purchGo)
 
 		if len(res) != 2 {
 			t.Fatalf("buildMessages() got len = %d, want len = %d", len(res), 2)
@@ -33,7 +34,7 @@ func Test_buildMessages(t *testing.T) {
 		purchAuctionAgain := memdb.MockPurchase
 		purchAuctionAgain.QueryType = botDB.TodayAuction
 
-		res = buildMessages(purchAuction, purchAuctionAgain)
+		res = buildMessages(nil, purchAuction, purchAuctionAgain)
 
 		if len(res) != 1 {
 			t.Fatalf("buildMessages() got len = %d, want len = %d", len(res), 1)
@@ -48,12 +49,20 @@ func Test_buildMessages(t *testing.T) {
 		purchGo.QueryType = botDB.TodayGo
 		purchFuture := memdb.MockPurchase
 		purchFuture.QueryType = botDB.FutureAuction
-		res := buildMessages(memdb.MockPurchase, purchAuction, purchGo, purchFuture)
+		links := botDB.ETPLinks{memdb.MockPurchase.EtpSql.String: "https://etp.ru/search?n={number}"}
+		res := buildMessages(links, memdb.MockPurchase, purchAuction, purchGo, purchFuture)
+
+		// ETP is linked to the procedure by the provided links
+		etp := "(https://etp.ru/search?n=" + memdb.MockPurchase.RegistryNumber + ")"
+		if !strings.Contains(res[0].text, etp) {
+			t.Errorf("buildMessages() message '%s' has no ETP link %s", res[0].text, etp)
+		}
 
 		for i := range res {
 			slash := 0
-			// seen := false
-			for idx, r := range res[i] {
+			// links are the only allowed markdown besides bold and italic
+			msg := mdLink.ReplaceAllString(res[i].text, "$1")
+			for idx, r := range msg {
 				switch r {
 				case '\\': // one slash
 					slash++
@@ -61,7 +70,7 @@ func Test_buildMessages(t *testing.T) {
 					'`', '>', '#', '+', '-', '=', '|', '.', '!':
 					if slash != 1 {
 						t.Errorf("buildMessages(): unescaped char '%c' at position %d in message '%s'",
-							r, idx, res[i])
+							r, idx, msg)
 					}
 					slash = 0
 				default:
@@ -71,3 +80,88 @@ func Test_buildMessages(t *testing.T) {
 		}
 	})
 }
+
+func Test_buildMessages_split(t *testing.T) {
+	recs := make([]botDB.PurchaseRecord, 200)
+	for i := range recs {
+		recs[i] = memdb.MockPurchase
+		recs[i].PurchaseId = int64(i + 1)
+		recs[i].QueryType = botDB.FutureAuction
+	}
+
+	res := buildMessages(nil, recs...)
+	if len(res) < 2 {
+		t.Fatalf("buildMessages() got len = %d, want the split", len(res))
+	}
+
+	header := escape(botDB.FutureAuction.String())
+	buttons := 0
+	for i := range res {
+		if l := textLen(res[i].text); l > maxMessageLen {
+			t.Errorf("buildMessages() message %d length = %d, want <= %d", i, l, maxMessageLen)
+		}
+		if !strings.HasPrefix(res[i].text, header) {
+			t.Errorf("buildMessages() message %d has no header", i)
+		}
+		// records are never broken
+		if n := strings.Count(res[i].text, "*\\["); n != strings.Count(res[i].text, "⏰") {
+			t.Errorf("buildMessages() message %d has broken record", i)
+		}
+		for _, row := range res[i].keyboard.InlineKeyboard {
+			buttons += len(row)
+		}
+	}
+	assert("buttons", buttons, len(recs), t)
+}
+
+func Test_escape(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"plain", "[1] a.b", `\[1\] a\.b`},
+		{"link", "*[1]* [0859-104](https://etp.ru/a_b?n=1).", `*\[1\]* [0859\-104](https://etp.ru/a_b?n=1)\.`},
+		{"not_url", "[text](ftp://a)", `\[text\]\(ftp://a\)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escape(tt.s); got != tt.want {
+				t.Errorf("escape() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildChangeMessages(t *testing.T) {
+	changes := []botDB.Change{
+		{PurchaseId: 1, RegistryNumber: "0859200001122007104", Field: "status_name", Old: "расчет", New: "идем"},
+		{PurchaseId: 1, RegistryNumber: "0859200001122007104", Field: "bidding",
+			Old: "17.10.2026 10:00", New: "17.10.2026 14:30"},
+		{PurchaseId: 2, RegistryNumber: "0859200001122007105", Field: "estimation", Old: "1.00", New: "2.00"},
+	}
+
+	t.Run("announced_kinds", func(t *testing.T) {
+		res := buildChangeMessages(changes, map[botDB.ChangeKind]bool{botDB.BiddingChange: true})
+
+		if len(res) != 1 {
+			t.Fatalf("buildChangeMessages() got len = %d, want len = %d", len(res), 1)
+		}
+		if !strings.Contains(res[0], "14:30") || strings.Contains(res[0], "Статус") {
+			t.Fatalf("buildChangeMessages() got unexpected message '%s'", res[0])
+		}
+	})
+
+	t.Run("one_message_per_purchase", func(t *testing.T) {
+		all := make(map[botDB.ChangeKind]bool)
+		for _, k := range botDB.ChangeKinds {
+			all[k] = true
+		}
+
+		res := buildChangeMessages(changes, all)
+
+		if len(res) != 2 {
+			t.Fatalf("buildChangeMessages() got len = %d, want len = %d", len(res), 2)
+		}
+	})
+}
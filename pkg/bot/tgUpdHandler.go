@@ -6,7 +6,9 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	botDB "tbot/pkg/db"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -22,8 +24,13 @@ const (
 	statusMsg      = "Все ок\\!"
 	errorOptionMsg = "Неправильная опция команды\n" + `➡️ */help* \-\[*_имя команды_*\]` +
 		"\nдля справки по команде"
-	notFoundIdMsg = "Не нашел ничего по заданному id"
-	notAllowedMsg = "Извини, не отвечаю тем, кого не знаю"
+	notFoundIdMsg  = "Не нашел ничего по заданному id"
+	noHistoryMsg   = "По этой закупке изменений не было 🤷"
+	noSubsMsg      = "Подписок нет 🤷 ➡️ */help* \\-" + subscribeCmd
+	ambiguousMsg   = "Под этот номер подходит несколько закупок 🤔\nУточни ➡️ */" + infoCmd + "* *_ID_*"
+	notAllowedMsg  = "Извини, не отвечаю тем, кого не знаю"
+	privateOnlyMsg = "Подписка оформляется в личном чате с ботом 🔒"
+	noPersonalMsg  = "Персональные уведомления отключены 🤷"
 )
 
 // command help message
@@ -33,61 +40,149 @@ const (
 		`*/` + futureCmd + `* \- аукционы/заявки/обеспечения в будущем 🔮` + "\n\n" +
 		`*/` + pastCmd + `* \- результаты закупок ⚰️` + "\n\n" +
 		`*/` + infoCmd + `* \- информация по закупке 📝` + "\n\n" +
+		`*/` + searchCmd + `* \- поиск закупок 🔎` + "\n\n" +
+		`*/` + reportCmd + `* \- итоги работы за период 📊` + "\n\n" +
+		`*/` + subscribeCmd + `* \- личные уведомления по фильтру 🔔` + "\n\n" +
+		`*/` + icsCmd + `* *_ID_* \- закупка файлом для календаря 📅` + "\n\n" +
+		`*/` + setCmd + `* \- изменить статус, участника, расчёт или победителя ✏️` + "\n\n" +
+		`*/` + grantCmd + `* \- управление доступом 🔑 \(для администраторов\)` + "\n\n" +
 		"Подробнее о каждой команде:" + "\n" + `*/` + helpCmd + `* \-\[*_имя команды_*\]`
 	todayHelpMsg = `*Имя команды:       /` + todayCmd + "\n" + `Использование:   /` + todayCmd + `*    \[*_опции_*\]\.\.\.` +
 		"\n" + `*Описание:*` + "\n" + `*/` + todayCmd + `* значит '*_today_*' т\.е '*_сегодня_*'` +
 		"\nПоказывает все ожидаемые сегодня торги и заявки, которые нужно подать\n" +
 		`*Опции:*` + "\n" + `*_\-` + auctionKey + `, \-` + auctionKeyLong + `_*    ` + auctionKeyUsg + "\n" +
-		`*_\-` + goKey + `, \-` + goKeyLong + `_*           ` + goKeyUsg
+		`*_\-` + goKey + `, \-` + goKeyLong + `_*           ` + goKeyUsg + "\n" +
+		`*_\-` + xlsxKey + `_*                  ` + xlsxKeyUsg + "\n" +
+		`*_\-` + csvKeyLong + `_*              ` + csvKeyUsg
 	futureHelpMsg = `*Имя команды:       /` + futureCmd + "\n" + `Использование:   /` + futureCmd + `*    \[*_опции_*\]\.\.\. *_\=NUM_*` +
 		"\n" + `*Описание:*` + "\n" + `*/` + futureCmd + `* значит '*_future_*' т\.е '*_будущее_*'` +
 		"\nПоказывает все будущие аукционы и заявки, а также суммы обеспечения заявок\n" +
 		`*Опции:*` + "\n" + `*_\-` + auctionKey + `, \-` + auctionKeyLong + `_*    ` + auctionKeyUsg + "\n" +
 		`*_\-` + goKey + `, \-` + goKeyLong + `_*           ` + goKeyUsg + "\n" +
 		`*_\-` + moneyKey + `, \-` + moneyKeyLong + `_*       ` + moneyKeyUsg + "\n" +
-		`*_\-` + daysKey + `, \-` + daysKeyLong + `\=NUM_* ` + daysKeyUsg + " вперед"
+		`*_\-` + daysKey + `, \-` + daysKeyLong + `\=NUM_* ` + daysKeyUsg + " вперед" + "\n" +
+		`*_\-` + xlsxKey + `_*                  ` + xlsxKeyUsg + "\n" +
+		`*_\-` + csvKeyLong + `_*              ` + csvKeyUsg
 	pastHelpMsg = `*Имя команды:       /` + pastCmd + "\n" + `Использование:   /` + pastCmd + `*    \[*_опции_*\]\.\.\. *_\=NUM_*` +
 		"\n" + `*Описание:*` + "\n" +
 		`*/` + pastCmd + `* значит '*_past_*' т\.е '*_прошлое_*'` + "\nПоказывает результаты прошедших закупок\n" +
-		`*Опции:*` + "\n" + `*_\-` + daysKey + `, \-` + daysKeyLong + `\=NUM_* ` + daysKeyUsg + " назад"
+		`*Опции:*` + "\n" + `*_\-` + daysKey + `, \-` + daysKeyLong + `\=NUM_* ` + daysKeyUsg + " назад" + "\n" +
+		`*_\-` + xlsxKey + `_*                  ` + xlsxKeyUsg + "\n" +
+		`*_\-` + csvKeyLong + `_*              ` + csvKeyUsg
 	infoHelpMsg = `*Имя команды:      /` + infoCmd + "\n" + `Использование:   /` + infoCmd + `    \=ID*` +
 		"\n" + `*Описание:*` + "\n" + `*/` + infoCmd + `* Показывает информацию по конкретной закупке` + "\n" +
 		`В выводе других команд есть значение в форме \[*_ID_*\]\.` + "\n" +
-		`Это значение нужно ввести как аргумент для этой команды т\.е '*/` + infoCmd + `  _ID_'*`
+		`Это значение нужно ввести как аргумент для этой команды т\.е '*/` + infoCmd + `  _ID_'*` + "\n" +
+		`Также можно ввести реестровый номер из ЕИС или его последние 3 цифры` + "\n" +
+		`*Опции:*` + "\n" + `*_\-` + historyKey + `, \-` + historyKeyLong + `_*     ` + historyKeyUsg + "\n" +
+		`*_\-` + numberKey + `, \-` + numberKeyLong + `_*   ` + numberKeyUsg
+	searchHelpMsg = `*Имя команды:       /` + searchCmd + "\n" + `Использование:   /` + searchCmd + `*    \[*_опции_*\]\.\.\. \[*_ТЕКСТ_*\]` +
+		"\n" + `*Описание:*` + "\n" + `*/` + searchCmd + `* значит '*_search_*' т\.е '*_поиск_*'` +
+		"\nИщет закупки по словам из предмета закупки или по реестровому номеру \\(полному или последним цифрам\\)\n" +
+		`Опции можно сочетать, значения опций пишутся без пробелов` + "\n" +
+		`*Опции:*` + "\n" + `*_\-` + regionKey + `, \-` + regionKeyLong + `\=STR_*          ` + regionKeyUsg + "\n" +
+		`*_\-` + etpKey + `, \-` + etpKeyLong + `\=STR_*                ` + etpKeyUsg + "\n" +
+		`*_\-` + customerKey + `, \-` + customerKeyLong + `\=STR_*     ` + customerKeyUsg + "\n" +
+		`*_\-` + participantKey + `, \-` + participantKeyLong + `\=STR_* ` + participantKeyUsg
+	subscribeHelpMsg = `*Имя команды:       /` + subscribeCmd + "\n" + `Использование:   /` + subscribeCmd + `*    \[*_опции_*\]\.\.\.` +
+		"\n" + `*Описание:*` + "\n" + `*/` + subscribeCmd + `* подписывает чат на напоминания и изменения закупок, подходящих под фильтр` + "\n" +
+		`Опции можно сочетать, значения опций пишутся без пробелов\. Без опций \- все закупки` + "\n" +
+		`*Опции:*` + "\n" + `*_\-` + regionKey + `, \-` + regionKeyLong + `\=STR_*          ` + regionKeyUsg + "\n" +
+		`*_\-` + etpKey + `, \-` + etpKeyLong + `\=STR_*                ` + etpKeyUsg + "\n" +
+		`*_\-` + participantKey + `, \-` + participantKeyLong + `\=STR_* ` + participantKeyUsg + "\n" +
+		`*_\-` + statusKey + `, \-` + statusKeyLong + `\=STR_*          ` + statusKeyUsg + "\n" +
+		`*_\-` + priceFromKey + `\=NUM_*                        ` + priceFromKeyUsg + "\n" +
+		`*_\-` + priceToKey + `\=NUM_*                        ` + priceToKeyUsg + "\n\n" +
+		`*/` + subscriptionsCmd + `* показывает подписки чата` + "\n" +
+		`*/` + unsubscribeCmd + `    \[*_ID_*\]* отменяет подписку или все подписки чата`
+	reportHelpMsg = `*Имя команды:       /` + reportCmd + "\n" + `Использование:   /` + reportCmd + `*    \[*_опции_*\]\.\.\. *_\=NUM_*` +
+		"\n" + `*Описание:*` + "\n" + `*/` + reportCmd + `* значит '*_report_*' т\.е '*_отчёт_*'` +
+		"\nПоказывает заявки, аукционы, долю побед, снижение цены и обеспечения в работе\n" +
+		`По умолчанию за последние 7 дней` + "\n" +
+		`*Опции:*` + "\n" + `*_\-` + weekKey + `, \-` + weekKeyLong + `_*         ` + weekKeyUsg + "\n" +
+		`*_\-` + monthKeyLong + `_*             ` + monthKeyUsg + "\n" +
+		`*_\-` + daysKey + `, \-` + daysKeyLong + `\=NUM_* ` + daysKeyUsg + " назад"
 	cmdHelp = "помощь по команде /"
 )
 
+// registry number
+const (
+	// the shortest registry number (223-FZ), 44-FZ ones are 19 digits long
+	minRegistryNumberLen = 11
+	// the registry number suffix shown in the listings
+	registryNumberSuffixLen = 3
+)
+
 // bot command
 const (
-	todayCmd  = "t"
-	futureCmd = "f"
-	pastCmd   = "p"
-	infoCmd   = "i"
-	helpCmd   = "help"
-	statusCmd = "status"
-	startCmd  = "start"
-	hiCmd     = "hi"
-	chatCmd   = "chat"
+	todayCmd         = "t"
+	futureCmd        = "f"
+	pastCmd          = "p"
+	infoCmd          = "i"
+	searchCmd        = "s"
+	reportCmd        = "report"
+	subscribeCmd     = "subscribe"
+	unsubscribeCmd   = "unsubscribe"
+	subscriptionsCmd = "subscriptions"
+	helpCmd          = "help"
+	statusCmd        = "status"
+	startCmd         = "start"
+	hiCmd            = "hi"
+	chatCmd          = "chat"
 )
 
 // bot command key
 const (
-	auctionKey     = "a"
-	auctionKeyLong = "auction"
-	goKey          = "g"
-	goKeyLong      = "go"
-	moneyKey       = "m"
-	moneyKeyLong   = "money"
-	daysKey        = "d"
-	daysKeyLong    = "days"
+	auctionKey         = "a"
+	auctionKeyLong     = "auction"
+	goKey              = "g"
+	goKeyLong          = "go"
+	moneyKey           = "m"
+	moneyKeyLong       = "money"
+	daysKey            = "d"
+	daysKeyLong        = "days"
+	historyKey         = "h"
+	historyKeyLong     = "history"
+	weekKey            = "w"
+	weekKeyLong        = "week"
+	monthKeyLong       = "month"
+	numberKey          = "n"
+	numberKeyLong      = "number"
+	regionKey          = "r"
+	regionKeyLong      = "region"
+	etpKey             = "e"
+	etpKeyLong         = "etp"
+	customerKey        = "c"
+	customerKeyLong    = "customer"
+	participantKey     = "u"
+	participantKeyLong = "participant"
+	statusKey          = "st"
+	statusKeyLong      = "status"
+	priceFromKey       = "min"
+	priceToKey         = "max"
+	xlsxKey            = "x"
+	csvKeyLong         = "csv"
 )
 
 // key usage
 const (
-	auctionKeyUsg = "показывает аукционы"
-	goKeyUsg      = "показывает заявки"
-	moneyKeyUsg   = "показывает суммы обеспечения"
-	daysKeyUsg    = "ограничивает выборку на NUM дней"
+	auctionKeyUsg     = "показывает аукционы"
+	goKeyUsg          = "показывает заявки"
+	moneyKeyUsg       = "показывает суммы обеспечения"
+	daysKeyUsg        = "ограничивает выборку на NUM дней"
+	historyKeyUsg     = "показывает историю изменений"
+	weekKeyUsg        = "за прошлую неделю"
+	monthKeyUsg       = "за прошлый месяц"
+	numberKeyUsg      = "ищет по реестровому номеру"
+	regionKeyUsg      = "ищет по региону"
+	etpKeyUsg         = "ищет по площадке"
+	customerKeyUsg    = "ищет по типу заказчика"
+	participantKeyUsg = "ищет по нашему участнику"
+	statusKeyUsg      = "ищет по статусу"
+	priceFromKeyUsg   = "НМЦК не меньше NUM"
+	priceToKeyUsg     = "НМЦК не больше NUM"
+	xlsxKeyUsg        = "присылает файл Excel"
+	csvKeyUsg         = "присылает файл CSV"
 )
 
 // querier is responsible
@@ -95,37 +190,72 @@ const (
 type querier interface {
 	Query(int, ...botDB.QueryOpt) ([]botDB.PurchaseRecord, error)
 	QueryRow(int64) (botDB.PurchaseRecord, error)
+	History(int64) ([]botDB.Change, error)
+	Report(from, to time.Time) (botDB.Report, error)
+	Search(botDB.SearchFilter) ([]botDB.PurchaseRecord, error)
+	QueryNumber(string) ([]botDB.PurchaseRecord, error)
+}
+
+// subscriptions keeps personal
+// notification filters of the chats
+type subscriptions interface {
+	Subscribe(botDB.Subscription) (int64, error)
+	Unsubscribe(chat, id int64) (int64, error)
+	Subscriptions(chat int64) ([]botDB.Subscription, error)
 }
 
 // tgUpdHandler processes incoming telegram updates
 type tgUpdHandler struct {
 	logger *log.Logger
+	clock  botDB.Clock
 	api    *tgbotapi.BotAPI
 	q      querier
-	chats  map[int64]bool
+	s      subscriptions
+	w      watcher // nil watcher means personal reminders are disabled
+	a      members
+	e      editor
+	paging bool           // long listings are shown page by page
+	admins map[int64]bool // configured admins, their role can't be changed in the bot
+	links  botDB.ETPLinks // procedure pages of the ETPs
+
+	mu      sync.Mutex
+	pending map[int64]bool // chats which access requests await the decision
 }
 
-func newTgUpdHandler(logger *log.Logger, q querier,
-	api *tgbotapi.BotAPI, allowedChats map[int64]bool) *tgUpdHandler {
+func newTgUpdHandler(logger *log.Logger, clock botDB.Clock, q querier, s subscriptions, w watcher,
+	a members, e editor, api *tgbotapi.BotAPI, paging bool, admins map[int64]bool, links botDB.ETPLinks) *tgUpdHandler {
 	return &tgUpdHandler{
-		logger: logger,
-		q:      q,
-		api:    api,
-		chats:  allowedChats,
+		logger:  logger,
+		clock:   clock,
+		q:       q,
+		s:       s,
+		w:       w,
+		api:     api,
+		a:       a,
+		e:       e,
+		paging:  paging,
+		admins:  admins,
+		links:   links,
+		pending: make(map[int64]bool),
 	}
 }
 
 // handleUpdate redirects incoming update to appropriate handler
 func (t *tgUpdHandler) handleUpdate(u *tgbotapi.Update) {
-	if !u.Message.IsCommand() {
+	switch {
+	case u.CallbackQuery != nil:
+		t.handleCallback(u.CallbackQuery)
+		return
+	case u.Message == nil || !u.Message.IsCommand():
 		return
 	}
 
 	// restricted access
-	if !t.chats[u.Message.Chat.ID] {
+	role := t.role(u.Message.Chat.ID)
+	if role == botDB.NoRole {
 		t.logger.Printf("[Telegram] -> [chatID=%d from=%v; restricted access]",
 			u.Message.Chat.ID, u.Message.From)
-		if err := send(t.api, u.Message.Chat.ID, t.notAllowed(u.Message)); err != nil {
+		if err := sendMessages(t.api, u.Message.Chat.ID, t.notAllowed(u.Message)); err != nil {
 			t.logger.Println(err)
 		}
 		return
@@ -137,6 +267,13 @@ func (t *tgUpdHandler) handleUpdate(u *tgbotapi.Update) {
 	// we parse flags from this message as if it was
 	// command line arguments
 	flags, err := parseMsgArgs(u.Message.CommandArguments())
+	if rawArgsCmds[u.Message.Command()] {
+		// these commands take chat ids which may be
+		// negative or values with spaces and dashes,
+		// so all arguments are positional ones
+		flags, err = parseFlags(nil)
+		flags.args = strings.Fields(u.Message.CommandArguments())
+	}
 	if err != nil {
 		t.logger.Printf("[Telegram] -> [due parsing message arguments %v]", err)
 		if err = send(t.api, u.Message.Chat.ID, errorOptionMsg); err != nil {
@@ -146,15 +283,25 @@ func (t *tgUpdHandler) handleUpdate(u *tgbotapi.Update) {
 	}
 
 	// get responses from command handlers
-	msgs := t.responses(u, flags)
+	msgs := t.responses(u, flags, role)
 
 	// sending responses
-	if err = send(t.api, u.Message.Chat.ID, msgs...); err != nil {
+	if err = sendMessages(t.api, u.Message.Chat.ID, msgs...); err != nil {
 		t.logger.Println(err)
 	}
 }
 
-func (t *tgUpdHandler) responses(u *tgbotapi.Update, flags *flags) []string {
+func (t *tgUpdHandler) responses(u *tgbotapi.Update, flags *flags, role botDB.Role) []message {
+	required := requiredRole(u.Message.Command())
+	// member chat opens the bot to everyone in it, while
+	// the privileged commands need the role of the sender
+	if required != botDB.Viewer {
+		role = t.userRole(u.Message.From)
+	}
+	if !role.Allows(required) {
+		return plain(forbiddenMsg)
+	}
+
 	// choosing appropriate handler
 	switch u.Message.Command() {
 	case todayCmd:
@@ -167,38 +314,61 @@ func (t *tgUpdHandler) responses(u *tgbotapi.Update, flags *flags) []string {
 		return t.helpCmdResponse(flags)
 	case infoCmd:
 		return t.infoCmdResponse(flags)
+	case searchCmd:
+		return t.searchCmdResponse(flags)
+	case reportCmd:
+		return t.reportCmdResponse(flags)
+	case icsCmd:
+		return t.icsCmdResponse(flags)
+	case subscribeCmd:
+		return t.subscribeCmdResponse(u.Message.Chat, flags)
+	case unsubscribeCmd:
+		return t.unsubscribeCmdResponse(u.Message.Chat.ID, flags)
+	case subscriptionsCmd:
+		return t.subscriptionsCmdResponse(u.Message.Chat.ID, flags)
+	case grantCmd:
+		return t.grantCmdResponse(int64(u.Message.From.ID), flags)
+	case revokeCmd:
+		return t.revokeCmdResponse(int64(u.Message.From.ID), flags)
+	case membersCmd:
+		return t.membersCmdResponse(flags)
+	case setCmd:
+		return t.setCmdResponse(int64(u.Message.From.ID), flags)
+	case unsetCmd:
+		return t.unsetCmdResponse(flags)
 	case startCmd:
-		return []string{startMsg}
+		return plain(startMsg)
 	case statusCmd:
-		return []string{statusMsg}
+		return plain(statusMsg)
 	case hiCmd:
-		return []string{t.hiCmdResponse(u.Message)}
+		return plain(t.hiCmdResponse(u.Message))
 	case chatCmd:
-		return []string{fmt.Sprint(u.Message.Chat.ID)}
+		return plain(chatString(u.Message.Chat.ID))
 	default:
-		return []string{unknownMsg}
+		return plain(unknownMsg)
 	}
 }
 
 // parseMsgArgs inspects provided arguments
 // and returns parsed flags or error
 func parseMsgArgs(args string) (*flags, error) {
-	var s []string
-	if args != "" {
-		// we split incoming message command arguments
-		s = strings.Split(args, " ")
-	}
+	// we split incoming message command arguments
+	s := strings.Fields(args)
 	// then we parse flags from this message as if it was
 	// command line arguments
 	// if args is empty we pass a nil slice
 	return parseFlags(s)
 }
 
-// flags holds flag set and all expected flags
+// flags holds flag set, all expected flags
+// and positional arguments
 type flags struct {
-	set                         *flag.FlagSet
-	tf, ff, pf, af, gf, mf, inf bool
-	df                          int
+	set                                                                   *flag.FlagSet
+	tf, ff, pf, af, gf, mf, inf, hf, rf, wf, monf, sf, nf, subf, xf, csvf bool
+	df                                                                    int
+	priceFrom, priceTo                                                    float64
+	region, etp, customer, participant, status                            string
+	args                                                                  []string
 }
 
 // parseFlags parses expected flags to the flags struct
@@ -221,9 +391,43 @@ func parseFlags(args []string) (*flags, error) {
 	f.set.BoolVar(&f.inf, infoCmd, false, cmdHelp+infoCmd)
 	f.set.IntVar(&f.df, daysKey, 0, daysKeyUsg)
 	f.set.IntVar(&f.df, daysKeyLong, 0, daysKeyUsg)
-	err := f.set.Parse(args)
-	if err != nil {
-		return &f, err
+	f.set.BoolVar(&f.hf, historyKey, false, historyKeyUsg)
+	f.set.BoolVar(&f.hf, historyKeyLong, false, historyKeyUsg)
+	f.set.BoolVar(&f.rf, reportCmd, false, cmdHelp+reportCmd)
+	f.set.BoolVar(&f.wf, weekKey, false, weekKeyUsg)
+	f.set.BoolVar(&f.wf, weekKeyLong, false, weekKeyUsg)
+	f.set.BoolVar(&f.monf, monthKeyLong, false, monthKeyUsg)
+	f.set.BoolVar(&f.sf, searchCmd, false, cmdHelp+searchCmd)
+	f.set.BoolVar(&f.nf, numberKey, false, numberKeyUsg)
+	f.set.BoolVar(&f.nf, numberKeyLong, false, numberKeyUsg)
+	f.set.StringVar(&f.region, regionKey, "", regionKeyUsg)
+	f.set.StringVar(&f.region, regionKeyLong, "", regionKeyUsg)
+	f.set.StringVar(&f.etp, etpKey, "", etpKeyUsg)
+	f.set.StringVar(&f.etp, etpKeyLong, "", etpKeyUsg)
+	f.set.StringVar(&f.customer, customerKey, "", customerKeyUsg)
+	f.set.StringVar(&f.customer, customerKeyLong, "", customerKeyUsg)
+	f.set.StringVar(&f.participant, participantKey, "", participantKeyUsg)
+	f.set.StringVar(&f.participant, participantKeyLong, "", participantKeyUsg)
+	f.set.StringVar(&f.status, statusKey, "", statusKeyUsg)
+	f.set.StringVar(&f.status, statusKeyLong, "", statusKeyUsg)
+	f.set.Float64Var(&f.priceFrom, priceFromKey, 0, priceFromKeyUsg)
+	f.set.Float64Var(&f.priceTo, priceToKey, 0, priceToKeyUsg)
+	f.set.BoolVar(&f.subf, subscribeCmd, false, cmdHelp+subscribeCmd)
+	f.set.BoolVar(&f.xf, xlsxKey, false, xlsxKeyUsg)
+	f.set.BoolVar(&f.csvf, csvKeyLong, false, csvKeyUsg)
+
+	// flag set stops parsing at the first positional
+	// argument, so we put it aside and go on with the rest
+	for {
+		err := f.set.Parse(args)
+		if err != nil {
+			return &f, err
+		}
+		if f.set.NArg() == 0 {
+			break
+		}
+		f.args = append(f.args, f.set.Arg(0))
+		args = f.set.Args()[1:]
 	}
 
 	return &f, nil
@@ -240,28 +444,17 @@ func (t *tgUpdHandler) hiCmdResponse(m *tgbotapi.Message) string {
 	}
 }
 
-// notAllowed is response for unauthorized request
-func (t *tgUpdHandler) notAllowed(m *tgbotapi.Message) string {
-	if m.From.FirstName != "" {
-		return fmt.Sprintf("Привет, %s 👋\n%s", m.From.FirstName, notAllowedMsg)
-	} else if m.From.UserName != "" {
-		return fmt.Sprintf("Привет, %s 👋\n%s", m.From.UserName, notAllowedMsg)
-	} else {
-		return notAllowedMsg
-	}
-}
-
 // unknownArgsErr returns error message when
 // input arguments contains some garbage leftovers
-func unknownArgsErr(f *flags) []string {
-	return []string{fmt.Sprintf("Переданы непонятные для меня аргументы ➡️ %v", f.set.Args())}
+func unknownArgsErr(f *flags) []message {
+	return plain(fmt.Sprintf("Переданы непонятные для меня аргументы ➡️ %v", f.args))
 }
 
 // helpCmdResponse is the '/help' command handler
-func (t *tgUpdHandler) helpCmdResponse(f *flags) []string {
+func (t *tgUpdHandler) helpCmdResponse(f *flags) []message {
 
 	if f.set.NFlag() == 0 {
-		return []string{generalHelpMsg}
+		return plain(generalHelpMsg)
 	}
 
 	msg := make([]string, 0, f.set.NFlag())
@@ -278,22 +471,27 @@ func (t *tgUpdHandler) helpCmdResponse(f *flags) []string {
 	if f.inf {
 		msg = append(msg, infoHelpMsg)
 	}
+	if f.sf {
+		msg = append(msg, searchHelpMsg)
+	}
+	if f.rf {
+		msg = append(msg, reportHelpMsg)
+	}
+	if f.subf {
+		msg = append(msg, subscribeHelpMsg)
+	}
 
-	return msg
+	return plain(msg...)
 }
 
 // todayCmdResponse is the '/t' command handler
-func (t *tgUpdHandler) todayCmdResponse(f *flags) []string {
+func (t *tgUpdHandler) todayCmdResponse(f *flags) []message {
 
 	// check for the garbage in arguments
-	if f.set.NArg() > 0 {
+	if len(f.args) > 0 {
 		return unknownArgsErr(f)
 	}
 
-	if f.set.NFlag() == 0 {
-		return t.query(0, botDB.Today)
-	}
-
 	opts := make([]botDB.QueryOpt, 0, f.set.NFlag())
 
 	if f.af {
@@ -303,14 +501,18 @@ func (t *tgUpdHandler) todayCmdResponse(f *flags) []string {
 		opts = append(opts, botDB.TodayGo)
 	}
 
-	return t.query(0, opts...)
+	if len(opts) == 0 {
+		opts = append(opts, botDB.Today)
+	}
+
+	return t.listing(todayCmd, f, 0, opts...)
 }
 
 // futureCmdResponse is the '/f' command handler
-func (t *tgUpdHandler) futureCmdResponse(f *flags) []string {
+func (t *tgUpdHandler) futureCmdResponse(f *flags) []message {
 
 	// check for the garbage in arguments
-	if f.set.NArg() > 0 {
+	if len(f.args) > 0 {
 		return unknownArgsErr(f)
 	}
 
@@ -330,55 +532,316 @@ func (t *tgUpdHandler) futureCmdResponse(f *flags) []string {
 		opts = append(opts, botDB.Future)
 	}
 
-	return t.query(f.df, opts...)
+	return t.listing(futureCmd, f, f.df, opts...)
 }
 
 // infoCmdResponse is the '/i' command handler
-func (t *tgUpdHandler) infoCmdResponse(f *flags) []string {
+func (t *tgUpdHandler) infoCmdResponse(f *flags) []message {
 
 	// we expecting only one argument which is id
-	if f.set.NArg() != 1 {
-		return []string{invalidArgsMsg}
+	if len(f.args) != 1 {
+		return plain(invalidArgsMsg)
+	}
+
+	arg := f.args[0]
+	if !isNumber(arg) {
+		return plain(invalidArgsMsg)
+	}
+
+	// registry number is asked explicitly or it can't be an id
+	if f.nf || len(arg) >= minRegistryNumberLen || strings.HasPrefix(arg, "0") {
+		return t.numberResponse(arg, f.hf)
 	}
 
-	id, err := strconv.ParseInt(f.set.Arg(0), 10, 0)
+	id, err := strconv.ParseInt(arg, 10, 0)
 	if err != nil {
 		t.logger.Printf("[Telegram] -> [due converting id %v]", err)
-		return []string{errorMsg}
+		return plain(errorMsg)
 	}
 
 	p, err := t.q.QueryRow(id)
 	if err != nil {
 		if err == botDB.ErrNoRows {
-			return []string{notFoundIdMsg}
+			// it may be the registry number suffix
+			if len(arg) == registryNumberSuffixLen {
+				return t.numberResponse(arg, f.hf)
+			}
+			return plain(notFoundIdMsg)
 		}
 		t.logger.Printf("[Telegram] -> [due fetching record %v]", err)
-		return []string{errorMsg}
+		return plain(errorMsg)
 	}
 
-	return buildMessages(p)
+	if f.hf {
+		return t.historyResponse(p)
+	}
+
+	return buildMessages(t.links, p)
+}
+
+// numberResponse returns the purchase found by the registry
+// number or its suffix. If several purchases are found
+// the list of them is returned to choose from
+func (t *tgUpdHandler) numberResponse(num string, history bool) []message {
+	recs, err := t.q.QueryNumber(num)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching records by number %v]", err)
+		return plain(errorMsg)
+	}
+
+	switch {
+	case len(recs) == 0:
+		return plain(notFoundIdMsg)
+	case len(recs) == 1 || recs[0].RegistryNumber == num:
+		// exact match comes first
+		if history {
+			return t.historyResponse(recs[0])
+		}
+		return buildMessages(t.links, recs[0])
+	}
+
+	for i := range recs {
+		recs[i].QueryType = botDB.Found
+	}
+
+	return append(plain(ambiguousMsg), buildMessages(t.links, recs...)...)
+}
+
+// isNumber reports if s consists of digits only
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// historyResponse returns the timeline of the purchase changes
+func (t *tgUpdHandler) historyResponse(p botDB.PurchaseRecord) []message {
+	changes, err := t.q.History(p.PurchaseId)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching history %v]", err)
+		return plain(errorMsg)
+	}
+
+	if len(changes) == 0 {
+		return plain(noHistoryMsg)
+	}
+
+	return plain(buildHistoryMessages(p, changes)...)
 }
 
 // pastCmdResponse is the '/p' command handler
-func (t *tgUpdHandler) pastCmdResponse(f *flags) []string {
+func (t *tgUpdHandler) pastCmdResponse(f *flags) []message {
+	// check for the garbage in arguments
+	if len(f.args) > 0 {
+		return unknownArgsErr(f)
+	}
+
+	return t.listing(pastCmd, f, f.df, botDB.Past)
+}
+
+// searchCmdResponse is the '/s' command handler
+func (t *tgUpdHandler) searchCmdResponse(f *flags) []message {
+	sf := botDB.SearchFilter{
+		Text:         strings.Join(f.args, " "),
+		Region:       f.region,
+		ETP:          f.etp,
+		CustomerType: f.customer,
+		Participant:  f.participant,
+	}
+
+	// we need at least something to look for
+	if sf.IsEmpty() {
+		return plain(invalidArgsMsg)
+	}
+
+	recs, err := t.q.Search(sf)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due searching records %v]", err)
+		return plain(errorMsg)
+	}
+
+	return buildMessages(t.links, recs...)
+}
+
+// reportCmdResponse is the '/report' command handler
+func (t *tgUpdHandler) reportCmdResponse(f *flags) []message {
+	// check for the garbage in arguments
+	if len(f.args) > 0 {
+		return unknownArgsErr(f)
+	}
+
+	now := t.clock.Now()
+
+	var title string
+	var from, to time.Time
+
+	switch {
+	case f.monf:
+		title = MonthlyReport.title()
+		from, to = MonthlyReport.bounds(now)
+	case f.wf:
+		title = WeeklyReport.title()
+		from, to = WeeklyReport.bounds(now)
+	default:
+		days := f.df
+		if days <= 0 {
+			days = reportDefaultDays
+		}
+		title = "Отчёт"
+		to = startOfDay(now).AddDate(0, 0, 1)
+		from = to.AddDate(0, 0, -days)
+	}
+
+	r, err := t.q.Report(from, to)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching report %v]", err)
+		return plain(errorMsg)
+	}
+
+	return plain(buildReportMessage(title, r))
+}
+
+// subscribeCmdResponse is the '/subscribe' command handler.
+// Subscriptions are delivered to the private chats by
+// the notifier, so nothing is subscribed without them
+func (t *tgUpdHandler) subscribeCmdResponse(chat *tgbotapi.Chat, f *flags) []message {
+	if t.w == nil {
+		return plain(noPersonalMsg)
+	}
+	if !chat.IsPrivate() {
+		return plain(privateOnlyMsg)
+	}
+
 	// check for the garbage in arguments
-	if f.set.NArg() > 0 {
+	if len(f.args) > 0 {
 		return unknownArgsErr(f)
 	}
 
-	return t.query(f.df, botDB.Past)
+	if f.priceFrom < 0 || f.priceTo < 0 || (f.priceTo != 0 && f.priceFrom > f.priceTo) {
+		return plain(invalidArgsMsg)
+	}
+
+	s := botDB.Subscription{
+		Chat:        chat.ID,
+		Region:      f.region,
+		Participant: f.participant,
+		ETP:         f.etp,
+		Status:      f.status,
+		PriceFrom:   f.priceFrom,
+		PriceTo:     f.priceTo,
+	}
+
+	id, err := t.s.Subscribe(s)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due subscribing %v]", err)
+		return plain(errorMsg)
+	}
+
+	return plain(mdReplacer.Replace(fmt.Sprintf("Подписка *[%d]* оформлена 🔔\n%s", id, s.String())))
+}
+
+// unsubscribeCmdResponse is the '/unsubscribe' command handler
+func (t *tgUpdHandler) unsubscribeCmdResponse(chat int64, f *flags) []message {
+	var id int64
+	var err error
+
+	switch len(f.args) {
+	case 0: // all subscriptions of the chat
+	case 1:
+		if id, err = strconv.ParseInt(f.args[0], 10, 64); err != nil || id <= 0 {
+			return plain(invalidArgsMsg)
+		}
+	default:
+		return plain(invalidArgsMsg)
+	}
+
+	n, err := t.s.Unsubscribe(chat, id)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due unsubscribing %v]", err)
+		return plain(errorMsg)
+	}
+
+	if n == 0 {
+		return plain(noSubsMsg)
+	}
+
+	return plain(fmt.Sprintf("Отменено подписок: *%d* 🔕", n))
+}
+
+// subscriptionsCmdResponse is the '/subscriptions' command handler
+func (t *tgUpdHandler) subscriptionsCmdResponse(chat int64, f *flags) []message {
+	// check for the garbage in arguments
+	if len(f.args) > 0 {
+		return unknownArgsErr(f)
+	}
+
+	subs, err := t.s.Subscriptions(chat)
+	if err != nil {
+		t.logger.Printf("[Telegram] -> [due fetching subscriptions %v]", err)
+		return plain(errorMsg)
+	}
+
+	if len(subs) == 0 {
+		return plain(noSubsMsg)
+	}
+
+	var b strings.Builder
+	b.WriteString("*Подписки* 🔔\n\n")
+	for i := range subs {
+		fmt.Fprintf(&b, "*[%d]* %s\n", subs[i].Id, subs[i].String())
+	}
+
+	return plain(mdReplacer.Replace(b.String()))
+}
+
+// listing returns the query results as messages
+// or as the document if the export is asked
+func (t *tgUpdHandler) listing(cmd string, f *flags, daysLimit int, opts ...botDB.QueryOpt) []message {
+	switch format := f.exportFormat(); {
+	case f.xf && f.csvf:
+		return plain(invalidArgsMsg)
+	case format != noExport:
+		return t.export(exportName(cmd, t.clock.Now()), format, daysLimit, opts...)
+	default:
+		return t.query(daysLimit, opts...)
+	}
 }
 
 // query is the helper method that transmits
 // options to database handler and then
 // passes results to the message builder
-func (t *tgUpdHandler) query(daysLimit int, opts ...botDB.QueryOpt) []string {
+func (t *tgUpdHandler) query(daysLimit int, opts ...botDB.QueryOpt) []message {
 
 	recs, err := t.q.Query(daysLimit, opts...) // gets results
 	if err != nil {
 		t.logger.Printf("[Telegram] -> [due fetching records %v]", err)
-		return []string{errorMsg}
+		return plain(errorMsg)
+	}
+
+	msgs := buildMessages(t.links, recs...) // passes results
+
+	// we show the first page, the rest are reached by the buttons
+	if t.paging && len(msgs) > 1 {
+		return []message{withPages(msgs, 0, daysLimit, opts)}
 	}
 
-	return buildMessages(recs...) // passes results
+	return msgs
+}
+
+// exportFormat returns the file format asked with the flags
+func (f *flags) exportFormat() exportFormat {
+	switch {
+	case f.xf:
+		return xlsxExport
+	case f.csvf:
+		return csvExport
+	default:
+		return noExport
+	}
 }
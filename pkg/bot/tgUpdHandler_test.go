@@ -1,8 +1,16 @@
 package bot
 
 import (
+	"io"
+	"log"
 	"reflect"
+	"strings"
+	botDB "tbot/pkg/db"
+	"tbot/pkg/db/memdb"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 // func Test_parseFlags1(t *testing.T) {
@@ -103,6 +111,26 @@ func Test_parseFlags(t *testing.T) {
 			args:    args{[]string{"--auction", "--go", "--money", "--days", "1", "-t", "-f", "-p"}},
 			wantErr: false,
 		},
+		{
+			name:    "positional_between_flags",
+			want:    &flags{set: nil, hf: true, inf: true, args: []string{"123", "456"}},
+			args:    args{[]string{"-i", "123", "-h", "456"}},
+			wantErr: false,
+		},
+		{
+			name:    "subscribe",
+			want:    &flags{set: nil, region: "Тверская", status: "идем", priceFrom: 1000, priceTo: 5e6},
+			args:    args{[]string{"-r", "Тверская", "-st", "идем", "-min", "1000", "-max", "5e6"}},
+			wantErr: false,
+		},
+		{
+			name: "search",
+			want: &flags{set: nil, region: "Москва", etp: "РТС", customer: "ГБУ",
+				participant: "ООО", args: []string{"уборка", "помещений"}},
+			args: args{[]string{"уборка", "-r", "Москва", "-etp", "РТС", "помещений",
+				"-c", "ГБУ", "-participant", "ООО"}},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -118,3 +146,111 @@ func Test_parseFlags(t *testing.T) {
 		})
 	}
 }
+
+func Test_tgUpdHandler_infoCmdResponse(t *testing.T) {
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, memdb.New(false), memdb.New(false), nil, false, nil, nil)
+	found := buildMessages(nil, memdb.MockPurchase)
+
+	tests := []struct {
+		name string
+		args []string
+		want []message
+	}{
+		{"registry_number", []string{memdb.MockPurchase.RegistryNumber}, found},
+		{"suffix_flag", []string{"-n", "104"}, found},
+		{"suffix_leading_zero", []string{"0007104"}, plain(notFoundIdMsg)},
+		{"not_a_number", []string{"abc"}, plain(invalidArgsMsg)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+			if got := h.infoCmdResponse(f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tgUpdHandler.infoCmdResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tgUpdHandler_listings(t *testing.T) {
+	// tuesday, the next workday is tomorrow
+	now := time.Date(2022, time.July, 5, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	clock := botDB.ClockFunc(func() time.Time { return now })
+	d := memdb.NewWithClock(clock, botDB.Calendar{})
+
+	auctionToday := testRecord("0000000000000000001", now.AddDate(0, 0, -3))
+	auctionToday.Status, auctionToday.BiddingDateTime = "допущены", now.Add(5*time.Hour)
+	auctionTomorrow := testRecord("0000000000000000002", now.AddDate(0, 0, -3))
+	auctionTomorrow.Status, auctionTomorrow.BiddingDateTime = "заявлены", now.AddDate(0, 0, 1)
+	goTomorrow := testRecord("0000000000000000003", now.AddDate(0, 0, 1))
+	goTomorrow.Status = "идем"
+	upsert(d, t, auctionToday, auctionTomorrow, goTomorrow)
+
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), clock, d, d, nil, d, d, nil, false, nil, nil)
+
+	text := func(msgs []message) string {
+		var b strings.Builder
+		for _, m := range msgs {
+			b.WriteString(m.text)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name    string
+		handler func(*flags) []message
+		want    []string
+		notWant []string
+	}{
+		{"today", h.todayCmdResponse, []string{"\\[1\\]", "\\[3\\]"}, []string{"\\[2\\]"}},
+		{"future", h.futureCmdResponse, []string{"\\[2\\]", "\\[3\\]"}, []string{"\\[1\\]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := parseFlags(nil)
+			got := text(tt.handler(f))
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("%s listing = %s, want it to contain %s", tt.name, got, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("%s listing = %s, want it not to contain %s", tt.name, got, w)
+				}
+			}
+		})
+	}
+}
+
+func Test_tgUpdHandler_subscribeCmdResponse(t *testing.T) {
+	h := newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), memWatcher{}, memdb.New(false), memdb.New(false), nil, false, nil, nil)
+	private := &tgbotapi.Chat{ID: 1, Type: "private"}
+
+	tests := []struct {
+		name string
+		h    *tgUpdHandler
+		chat *tgbotapi.Chat
+		args []string
+		want string
+	}{
+		{"subscribed", h, private, []string{"-r", "Тверская"}, "Подписка *\\[1\\]* оформлена 🔔\nРегион: Тверская"},
+		{"inverted_prices", h, private, []string{"-min", "10", "-max", "1"}, invalidArgsMsg},
+		{"garbage", h, private, []string{"-r", "Тверская", "область"}, unknownArgsErr(&flags{args: []string{"область"}})[0].text},
+		{"group", h, &tgbotapi.Chat{ID: -1, Type: "group"}, nil, privateOnlyMsg},
+		{"no_notifier", newTgUpdHandler(log.New(io.Discard, "", 0), botDB.SystemClock{}, memdb.New(false), memdb.New(false), nil, memdb.New(false), memdb.New(false), nil, false, nil, nil),
+			private, nil, noPersonalMsg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+			got := tt.h.subscribeCmdResponse(tt.chat, f)
+			assert("tgUpdHandler.subscribeCmdResponse()", got[0].text, tt.want, t)
+		})
+	}
+}
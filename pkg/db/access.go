@@ -0,0 +1,137 @@
+package botDB
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Role is the access level of the chat
+type Role string
+
+// chat role
+const (
+	NoRole  Role = ""        // unknown chat, access is restricted
+	Viewer  Role = "viewer"  // reads the purchases and gets notifications
+	Manager Role = "manager" // also edits the purchases
+	Admin   Role = "admin"   // also grants and revokes access
+)
+
+// Roles is the list of all roles from the lowest to the highest
+var Roles = []Role{Viewer, Manager, Admin}
+
+// ParseRole returns the role by its name
+func ParseRole(s string) (Role, bool) {
+	for _, r := range Roles {
+		if string(r) == s {
+			return r, true
+		}
+	}
+	return NoRole, false
+}
+
+// level returns the rank of the role, unknown role has zero rank
+func (r Role) level() int {
+	for i := range Roles {
+		if Roles[i] == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Allows reports if the role is sufficient
+// for the actions of the required role
+func (r Role) Allows(required Role) bool {
+	return r.level() > 0 && r.level() >= required.level()
+}
+
+// Member is the chat allowed to use the bot
+type Member struct {
+	Chat      int64
+	Role      Role
+	GrantedBy int64 // zero for the members from configuration
+	GrantedAt time.Time
+}
+
+// Role returns the role of the chat or NoRole if the chat is unknown
+func (m *BotDB) Role(chat int64) (Role, error) {
+	var r Role
+
+	if err := m.db.QueryRow(memberRoleStatement, chat).Scan(&r); err != nil {
+		if err == sql.ErrNoRows {
+			return NoRole, nil
+		}
+		return NoRole, newBotDbError("BotDB: Role", memberRoleStatement, err, chat)
+	}
+	return r, nil
+}
+
+// Grant sets the role of the chat, by is the chat of the admin
+func (m *BotDB) Grant(chat int64, r Role, by int64) error {
+	if _, err := m.db.Exec(memberGrantStatement, chat, r, by); err != nil {
+		return newBotDbError("BotDB: Grant", memberGrantStatement, err, chat, r, by)
+	}
+	return nil
+}
+
+// Enroll adds the chat with the role unless the
+// chat is already a member. It is used to seed
+// the members from configuration
+func (m *BotDB) Enroll(chat int64, r Role) error {
+	if _, err := m.db.Exec(memberEnrollStatement, chat, r); err != nil {
+		return newBotDbError("BotDB: Enroll", memberEnrollStatement, err, chat, r)
+	}
+	return nil
+}
+
+// Revoke removes the chat from the members along with its
+// subscriptions and watched purchases, so nothing is sent there
+// anymore. It reports if the chat was a member
+func (m *BotDB) Revoke(chat int64) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(memberDeleteStatement, chat)
+	if err != nil {
+		return false, newBotDbError("BotDB: Revoke", memberDeleteStatement, err, chat)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if _, err = tx.Exec(subDeleteStatement, chat, 0); err != nil {
+		return false, newBotDbError("BotDB: Revoke", subDeleteStatement, err, chat, 0)
+	}
+	if _, err = tx.Exec(watcherDeleteStatement, chat); err != nil {
+		return false, newBotDbError("BotDB: Revoke", watcherDeleteStatement, err, chat)
+	}
+
+	return n > 0, tx.Commit()
+}
+
+// Members returns the members with the role
+// or all of them if the role is NoRole
+func (m *BotDB) Members(r Role) ([]Member, error) {
+	var res []Member
+
+	rows, err := m.db.Query(memberSelectStatement, r)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Members", memberSelectStatement, err, r)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var mb Member
+		if err = rows.Scan(&mb.Chat, &mb.Role, &mb.GrantedBy, &mb.GrantedAt); err != nil {
+			return nil, newBotDbError("BotDB: Members Scan", memberSelectStatement, err, r)
+		}
+		res = append(res, mb)
+	}
+
+	return res, rows.Err()
+}
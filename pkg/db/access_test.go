@@ -0,0 +1,35 @@
+package botDB
+
+import "testing"
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        Role
+		required Role
+		want     bool
+	}{
+		{"same", Manager, Manager, true},
+		{"higher", Admin, Viewer, true},
+		{"lower", Viewer, Manager, false},
+		{"no_role", NoRole, Viewer, false},
+		{"no_role_required", NoRole, NoRole, false},
+		{"unknown", Role("owner"), Viewer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Allows(tt.required); got != tt.want {
+				t.Errorf("Role.Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if r, ok := ParseRole("manager"); !ok || r != Manager {
+		t.Errorf("ParseRole() = %v, %v, want %v, true", r, ok, Manager)
+	}
+	if _, ok := ParseRole("owner"); ok {
+		t.Errorf("ParseRole() expected unknown role")
+	}
+}
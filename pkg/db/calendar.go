@@ -0,0 +1,50 @@
+package botDB
+
+import (
+	"time"
+)
+
+// holidayLayout is the layout of the holiday key
+const holidayLayout = "2006-01-02"
+
+// Calendar tells the workdays. Non-working days are the
+// weekends and the holidays. Zero value has no holidays
+type Calendar struct {
+	holidays map[string]bool
+}
+
+// NewCalendar returns the calendar with the non-working days
+// besides weekends. They are taken into account when the next
+// workday is calculated
+func NewCalendar(holidays []time.Time) Calendar {
+	c := Calendar{holidays: make(map[string]bool, len(holidays))}
+	for i := range holidays {
+		c.holidays[holidays[i].Format(holidayLayout)] = true
+	}
+	return c
+}
+
+// IsWorkday reports if provided day is
+// neither weekend nor holiday
+func (c Calendar) IsWorkday(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[t.Format(holidayLayout)]
+}
+
+// NextWorkday returns the first workday after provided day
+func (c Calendar) NextWorkday(t time.Time) time.Time {
+	return t.AddDate(0, 0, c.plusDays(t))
+}
+
+// plusDays returns amount of days that
+// need to be added to provided day to get next
+// workday
+func (c Calendar) plusDays(t time.Time) int {
+	n := 1
+	for !c.IsWorkday(t.AddDate(0, 0, n)) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package botDB
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendar_plusDays(t *testing.T) {
+	// 2022-07-04 is monday
+	monday := time.Date(2022, time.July, 4, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		day      time.Time
+		holidays []time.Time
+		want     int
+	}{
+		{"monday", monday, nil, 1},
+		{"friday", monday.AddDate(0, 0, 4), nil, 3},
+		{"saturday", monday.AddDate(0, 0, 5), nil, 2},
+		{"sunday", monday.AddDate(0, 0, 6), nil, 1},
+		{"before_holiday", monday, []time.Time{monday.AddDate(0, 0, 1)}, 2},
+		{"friday_before_holiday", monday.AddDate(0, 0, 4), []time.Time{monday.AddDate(0, 0, 7)}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewCalendar(tt.holidays).plusDays(tt.day); got != tt.want {
+				t.Errorf("Calendar.plusDays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
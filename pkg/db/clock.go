@@ -0,0 +1,61 @@
+package botDB
+
+import "time"
+
+// DefaultLocationName is the time zone of
+// the purchases used when nothing else is configured
+const DefaultLocationName = "Europe/Moscow"
+
+// defaultLocation is the default time zone. Moscow has no daylight
+// saving time, so the fixed offset is used if there is no time zone database
+var defaultLocation = func() *time.Location {
+	loc, err := time.LoadLocation(DefaultLocationName)
+	if err != nil {
+		return time.FixedZone("MSK", 3*60*60)
+	}
+	return loc
+}()
+
+// LoadLocation returns the time zone with the given
+// name. Default one is returned for the empty name
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return defaultLocation, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Clock tells the current time. Days of the
+// queries are counted in the time zone of its time
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the clock of the system telling
+// the time in the time zone of the purchases
+type SystemClock struct {
+	Loc *time.Location // default time zone is used if nil
+}
+
+// Now returns the current time in the time zone of the purchases
+func (c SystemClock) Now() time.Time {
+	if c.Loc == nil {
+		return time.Now().In(defaultLocation)
+	}
+	return time.Now().In(c.Loc)
+}
+
+// ClockFunc is the function used as the clock
+type ClockFunc func() time.Time
+
+// Now returns the time told by the function
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// dayStart returns midnight n days after
+// the day of provided time in its time zone
+func dayStart(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+n, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,34 @@
+package botDB
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("")
+	if err != nil {
+		t.Fatalf("LoadLocation() error=%v", err)
+	}
+	// Moscow has no daylight saving time
+	for _, m := range []time.Month{time.January, time.July} {
+		if _, offset := time.Date(2022, m, 1, 0, 0, 0, 0, loc).Zone(); offset != 3*60*60 {
+			t.Errorf("LoadLocation() offset in %s = %d, want %d", m, offset, 3*60*60)
+		}
+	}
+
+	if _, err = LoadLocation("Nowhere/Nothing"); err == nil {
+		t.Errorf("LoadLocation() expected error for unknown time zone")
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	if got := (SystemClock{Loc: loc}).Now().Location(); got != loc {
+		t.Errorf("SystemClock.Now() location = %v, want %v", got, loc)
+	}
+
+	if _, offset := (SystemClock{}).Now().Zone(); offset != 3*60*60 {
+		t.Errorf("SystemClock.Now() default offset = %d, want %d", offset, 3*60*60)
+	}
+}
@@ -5,30 +5,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var ErrNoRows = sql.ErrNoRows
 
 // BotDB responsible for database management
 type BotDB struct {
-	db      *sql.DB
+	db *sql.DB
+
+	// mu serializes the updates, records and refMap
+	// are the state of the running one
+	mu      sync.Mutex
 	records []PurchaseRecord
-	tk      tablesKeeper
 	refMap  refTablesMap
+
+	tk    tablesKeeper
+	clock Clock
+	loc   *time.Location // time zone of the purchases, the one of the clock
+	cal   Calendar       // workdays of the queries
 }
 
 // NewBotDB is database BotDB manager constructor.
-// Expects established database connection
-func NewBotDB(db *sql.DB) *BotDB {
+// Expects established database connection. Days
+// of the queries are counted by the clock and
+// the calendar, the records are read in the clock time zone
+func NewBotDB(db *sql.DB, clock Clock, cal Calendar) *BotDB {
 	return &BotDB{
 		db:      db,
 		records: nil,
 		tk:      newTables(),
 		refMap:  nil,
+		clock:   clock,
+		loc:     clock.Now().Location(),
+		cal:     cal,
 	}
 }
 
@@ -70,8 +86,8 @@ type table interface {
 // It goes like this: "tableName":map["NameColumnKey":PrimaryKey]
 type refTablesMap map[string]map[string]int64
 
-// OpenDB establish connection to the database
-func OpenDB(DbParams string) (*sql.DB, error) {
+// Connect establish connection to the database
+func Connect(DbParams string) (*sql.DB, error) {
 
 	db, err := sql.Open("postgres", DbParams)
 	if err != nil {
@@ -81,15 +97,49 @@ func OpenDB(DbParams string) (*sql.DB, error) {
 	return db, db.Ping()
 }
 
+// OpenDB establish connection to the database and
+// applies pending schema migrations
+func OpenDB(DbParams string) (*sql.DB, error) {
+	db, err := Connect(DbParams)
+	if err != nil {
+		return nil, err
+	}
+
+	mg, err := NewMigrator(db)
+	if err == nil {
+		_, err = mg.Up()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// UpdateResult is the outcome of the database update
+type UpdateResult struct {
+	Rejected  []ValidationError // records rejected by validation
+	Changes   []Change          // changes of the already existing records
+	Conflicts []Conflict        // incoming values replaced by the ones edited in the bot
+}
+
 // Upsert reading from incoming update source
-// and try to perform an insert/update operation
-func (m *BotDB) Upsert(rc io.ReadCloser) error {
+// and try to perform an insert/update operation.
+// Incoming records are validated first, rejected ones
+// are returned along with ErrInvalidRecords in Strict mode
+// or just skipped in Partial mode
+func (m *BotDB) Upsert(rc io.ReadCloser, mode UpsertMode) (UpdateResult, error) {
+	var res UpdateResult
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	// unmarshalling incoming data
 	// and put them inside BotDB
 	err := json.NewDecoder(rc).Decode(&m.records)
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	// deferring clean up operations in case
@@ -100,15 +150,172 @@ func (m *BotDB) Upsert(rc io.ReadCloser) error {
 	}()
 
 	if len(m.records) == 0 {
-		return fmt.Errorf("the length of incoming records is zero")
+		return res, fmt.Errorf("the length of incoming records is zero")
+	}
+
+	res.Rejected = m.validate()
+	if len(res.Rejected) > 0 && (mode == Strict || len(m.records) == 0) {
+		return res, ErrInvalidRecords
 	}
 
 	// work to be done before updating
 	if err := m.prepareUpdate(); err != nil {
-		return err
+		return res, err
+	}
+
+	// transaction
+	tx, err := m.db.Begin()
+	if err != nil {
+		return res, err
+	}
+
+	defer tx.Rollback()
+
+	changes, conflicts, err := m.upsrt(tx)
+	if err != nil {
+		return res, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return res, err
+	}
+
+	res.Changes = changes
+	res.Conflicts = conflicts
+
+	return res, nil
+}
+
+// RecordDigest is the short representation of the record
+// state. Client keeps the hash of each record it sent
+// so the stale records can be found without sending them all
+type RecordDigest struct {
+	RegistryNumber string `json:"registry_number"`
+	Hash           string `json:"hash"`
+}
+
+// Delta is the incremental update of the database.
+// Records are inserted/updated, tombstones are registry
+// numbers of purchases that were removed upstream
+type Delta struct {
+	Records    []PurchaseRecord `json:"records"`
+	Tombstones []string         `json:"tombstones"`
+}
+
+// Stale reading record digests from incoming source
+// and returns registry numbers of records that are
+// either missing in database or have different hash
+func (m *BotDB) Stale(rc io.ReadCloser) ([]string, error) {
+	var digests []RecordDigest
+
+	if err := json.NewDecoder(rc).Decode(&digests); err != nil {
+		return nil, err
+	}
+
+	if len(digests) == 0 {
+		return nil, nil
+	}
+
+	nums := make([]string, len(digests))
+	for i := range digests {
+		nums[i] = digests[i].RegistryNumber
+	}
+
+	rows, err := m.db.Query(purchHashesStatement, pq.Array(nums))
+	if err != nil {
+		return nil, newBotDbError("BotDB: Stale", purchHashesStatement, err, nums)
+	}
+
+	defer rows.Close()
+
+	known := make(map[string]string, len(digests))
+	for rows.Next() {
+		var num, hash string
+		if err = rows.Scan(&num, &hash); err != nil {
+			return nil, newBotDbError("BotDB: Stale Scan", purchHashesStatement, err)
+		}
+		known[num] = hash
 	}
 
-	return m.upsrt()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stale := make([]string, 0, len(digests))
+	for i := range digests {
+		if h, ok := known[digests[i].RegistryNumber]; !ok || h != digests[i].Hash {
+			stale = append(stale, digests[i].RegistryNumber)
+		}
+	}
+
+	return stale, nil
+}
+
+// ApplyDelta reading delta from incoming update source,
+// upserts its records and removes the tombstoned ones
+// in a single transaction. Records are validated
+// the same way as in Upsert
+func (m *BotDB) ApplyDelta(rc io.ReadCloser, mode UpsertMode) (UpdateResult, error) {
+	var d Delta
+	var res UpdateResult
+
+	if err := json.NewDecoder(rc).Decode(&d); err != nil {
+		return res, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = d.Records
+
+	// deferring clean up operations in case
+	// of early returns due to error occurrences
+	defer func() {
+		m.records = nil
+		m.refMap = nil
+	}()
+
+	res.Rejected = m.validate()
+	if len(res.Rejected) > 0 && mode == Strict {
+		return res, ErrInvalidRecords
+	}
+
+	if len(m.records) > 0 {
+		if err := m.prepareUpdate(); err != nil {
+			return res, err
+		}
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return res, err
+	}
+
+	defer tx.Rollback()
+
+	var changes []Change
+	var conflicts []Conflict
+	if len(m.records) > 0 {
+		if changes, conflicts, err = m.upsrt(tx); err != nil {
+			return res, err
+		}
+	}
+
+	if len(d.Tombstones) > 0 {
+		_, err = tx.Exec(purchBuryStatement, pq.Array(d.Tombstones))
+		if err != nil {
+			return res, newBotDbError("BotDB: ApplyDelta", purchBuryStatement, err, d.Tombstones)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return res, err
+	}
+
+	res.Changes = changes
+	res.Conflicts = conflicts
+
+	return res, nil
 }
 
 // prepareUpdate sets up a reference map for BotDB,
@@ -130,44 +337,127 @@ func (m *BotDB) prepareUpdate() error {
 	return nil
 }
 
-// core upsert operation
-func (m *BotDB) upsrt() error {
+// core upsert operation. Records are split into chunks
+// so the statement stays under the bind parameters limit.
+// All chunks are executed in the provided transaction.
+// Values edited in the bot take precedence over the incoming
+// ones, the fields where they differ are returned
+func (m *BotDB) upsrt(tx *sql.Tx) ([]Change, []Conflict, error) {
 	// get main table
 	t := m.tk.table(purchTableName)
 
+	conflicts, err := m.applyOverrides(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// remember the state of records before update
+	// to keep the history of changes
+	old, err := m.existing(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// get table columns that taking part in update
+	cols := t.columns(upsert)
+	size := chunkSize(len(cols))
+
+	for n, b := range chunkBounds(len(m.records), size) {
+		chunk := m.records[b[0]:b[1]]
+
+		// savepoint lets us to keep using the
+		// transaction if chunk is failed
+		if _, err := tx.Exec(upsertSavepoint); err != nil {
+			return nil, nil, err
+		}
+
+		if err := m.upsrtChunk(tx, t, chunk); err != nil {
+			if _, rerr := tx.Exec(upsertRollbackToSavepoint); rerr != nil {
+				return nil, nil, &upsertError{Chunk: n, Err: err}
+			}
+			return nil, nil, &upsertError{Chunk: n, RegistryNumber: m.culprit(tx, t, chunk), Err: err}
+		}
+
+		// savepoints don't pile up on the large updates
+		if _, err := tx.Exec(upsertReleaseSavepoint); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	changes := m.diff(old)
+	if err = m.writeHistory(tx, changes); err != nil {
+		return nil, nil, err
+	}
+
+	return changes, conflicts, nil
+}
+
+// upsrtChunk performs upsert of the provided records
+// with one statement
+func (m *BotDB) upsrtChunk(tx *sql.Tx, t table, recs []PurchaseRecord) error {
+
 	// construct options for building an
 	// query statement
 	opts := stmtOpts{
 		tableName:   t.name(),
 		conflictKey: t.primaryKeyCol(primaryKey),
-		multiplier:  len(m.records),
+		multiplier:  len(recs),
 		withUpdate:  true,
-		// get table columns that taking part in update
-		cols: t.columns(upsert),
+		cols:        t.columns(upsert),
 	}
 
 	// building an upsert query
 	stmt := upsertStatement(opts)
 
 	// get arguments for the query
-	args := m.buildArgs()
+	args := buildArgs(recs)
 
-	// transaction
-	tx, err := m.db.Begin()
-	if err != nil {
-		return err
-	}
+	_, err := tx.Exec(stmt, args...)
+	return err
+}
 
-	defer tx.Rollback()
+// culprit returns registry number of the record that broke
+// the failed chunk. Records are upserted one by one and
+// the first failed one is returned. Duplicates are rejected
+// by the validation before, so they are not looked for here. Every attempt is rolled back, so transaction
+// remains unaffected. Returns empty string if culprit is not found
+func (m *BotDB) culprit(tx *sql.Tx, t table, recs []PurchaseRecord) string {
+	for i := range recs {
+		if _, err := tx.Exec(upsertSavepoint); err != nil {
+			return ""
+		}
 
-	_, err = tx.Exec(stmt, args...)
-	if err != nil {
-		return newBotDbError("BotDB: upsrt", stmt, err, args...)
+		err := m.upsrtChunk(tx, t, recs[i:i+1])
+
+		if _, rerr := tx.Exec(upsertRollbackToSavepoint); rerr != nil {
+			return ""
+		}
+		if _, rerr := tx.Exec(upsertReleaseSavepoint); rerr != nil {
+			return ""
+		}
+
+		if err != nil {
+			return recs[i].RegistryNumber
+		}
 	}
+	return ""
+}
 
-	return tx.Commit()
+// upsertError represents information about the
+// chunk of records that failed to be upserted
+type upsertError struct {
+	Chunk          int    // index of the failed chunk
+	RegistryNumber string // registry number of the record that broke the chunk
+	Err            error
+}
+
+func (ue *upsertError) Error() string {
+	return fmt.Sprintf("op=BotDB: upsrt chunk=%d registry_number=%s: %v",
+		ue.Chunk, ue.RegistryNumber, ue.Err)
 }
 
+func (ue *upsertError) Unwrap() error { return ue.Err }
+
 func (m *BotDB) setForeignKeys(p *PurchaseRecord) error {
 
 	// gives the record reference table map
@@ -192,15 +482,15 @@ func (m *BotDB) setForeignKeys(p *PurchaseRecord) error {
 	return m.setForeignKeys(p)
 }
 
-// buildArgs takes every record in a BotDB,
+// buildArgs takes every provided record,
 // build arguments for each and then append them to
 // one big arguments slice
-func (m *BotDB) buildArgs() []interface{} {
+func buildArgs(recs []PurchaseRecord) []interface{} {
 
-	args := make([]interface{}, 0, len(m.records)*(purchTableColsCount-1))
+	args := make([]interface{}, 0, len(recs)*(purchTableColsCount-1))
 
-	for i := range m.records {
-		args = append(args, m.records[i].args(upsert)...)
+	for i := range recs {
+		args = append(args, recs[i].args(upsert)...)
 	}
 
 	return args
@@ -291,25 +581,126 @@ func (m *BotDB) updateRefMap(um map[string]string) error {
 	return tx.Commit()
 }
 
+// RetentionPolicy is the parameter for the Delete operation
+type RetentionPolicy struct {
+	// Days is how many days records are kept after the bidding
+	// date (or collecting date if there is no bidding)
+	Days int `json:"days"`
+	// ArchiveDir is the directory where removed records are
+	// written as json before deleting. No archiving if empty
+	ArchiveDir string `json:"-"`
+}
+
+// DefaultRetentionDays is the retention window
+// used when nothing else is configured
+const DefaultRetentionDays = 60
+
 // Delete is for removing old records from DB only.
 // This should be done from time to time so that the database
 // does not grow in size too much due to restrictions of heroku platform.
+// It returns the number of removed records.
 // No functionality beyond that is provided.
-func (m *BotDB) Delete(_ io.ReadCloser) error {
+func (m *BotDB) Delete(rp RetentionPolicy) (int64, error) {
+	if rp.Days <= 0 {
+		return 0, fmt.Errorf("invalid retention window %d days", rp.Days)
+	}
 
 	tx, err := m.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	defer tx.Rollback()
 
-	_, err = tx.Exec(purchDeleteStatement)
+	// records of the days before are expired
+	expired := dayStart(m.clock.Now(), -rp.Days)
+
+	// archive records before they are gone
+	if rp.ArchiveDir != "" {
+		if err = m.archive(tx, expired, rp.ArchiveDir); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := tx.Exec(purchDeleteStatement, expired)
 	if err != nil {
-		return newBotDbError("BotDB: Delete", purchDeleteStatement, err)
+		return 0, newBotDbError("BotDB: Delete", purchDeleteStatement, err, expired)
 	}
 
-	return tx.Commit()
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// vacuum cannot be executed inside a transaction block
+	if n > 0 {
+		if _, err = m.db.Exec(purchVacuumStatement); err != nil {
+			return n, newBotDbError("BotDB: Delete", purchVacuumStatement, err)
+		}
+	}
+
+	return n, nil
+}
+
+// archive writes records that are about to be removed
+// according to retention policy to the json file
+func (m *BotDB) archive(tx *sql.Tx, expired time.Time, dir string) error {
+	var recs []PurchaseRecord
+	var r PurchaseRecord
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: purchExpiredClause,
+		orderBy:     []string{collectingColumn},
+		cols:        t.columns(query),
+	})
+
+	rows, err := tx.Query(stmt, expired)
+	if err != nil {
+		return newBotDbError("BotDB: archive", stmt, err, expired)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return newBotDbError("BotDB: archive Scan", "", err, r.args(query)...)
+		}
+		r.fillFromNullable()
+		recs = append(recs, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// nothing to archive
+	if len(recs) == 0 {
+		return nil
+	}
+
+	name := filepath.Join(dir,
+		fmt.Sprintf("%s-%s.json", purchTableName, m.clock.Now().Format("20060102T150405")))
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = json.NewEncoder(f).Encode(recs); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
 // Query performs select operations from
@@ -322,14 +713,17 @@ func (m *BotDB) Query(daysLimit int, qopts ...QueryOpt) ([]PurchaseRecord, error
 	// get main table
 	t := m.tk.table(purchTableName)
 
+	// the same day for all of the options
+	now := m.clock.Now()
+
 	// range over provided query options
 	for _, q := range qopts {
 
-		opts := q.stmtOpts(daysLimit, t) // build statement options
-		stmt := selectWhereStmt(opts)    // build statement
-		rows, err := m.db.Query(stmt)
+		opts, args := q.stmtOpts(daysLimit, now, m.cal, t) // build statement options
+		stmt := selectWhereStmt(opts)                      // build statement
+		rows, err := m.db.Query(stmt, args...)
 		if err != nil {
-			return nil, newBotDbError("BotDB: Query", stmt, err)
+			return nil, newBotDbError("BotDB: Query", stmt, err, args...)
 		}
 
 		defer rows.Close()
@@ -339,6 +733,7 @@ func (m *BotDB) Query(daysLimit int, qopts ...QueryOpt) ([]PurchaseRecord, error
 			if err != nil {
 				return nil, newBotDbError("BotDB: Query Scan", "", err, r.args(q.tableOpt())...)
 			}
+			r.inLocation(m.loc)
 			// we add specific query option to the record
 			// this needed for the record to properly
 			// build string info about itself
@@ -356,8 +751,19 @@ func (m *BotDB) Query(daysLimit int, qopts ...QueryOpt) ([]PurchaseRecord, error
 	return recs, nil
 }
 
+// rowQuerier is either the database or the transaction
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 // QueryRow looks for one specific row by id
 func (m *BotDB) QueryRow(id int64) (PurchaseRecord, error) {
+	return m.queryRow(m.db, id, false)
+}
+
+// queryRow looks for the row by id. Locked row can't
+// be changed by the others until the transaction ends
+func (m *BotDB) queryRow(q rowQuerier, id int64, lock bool) (PurchaseRecord, error) {
 	var r PurchaseRecord
 	if id == 0 {
 		return r, fmt.Errorf("invalid identifier %d", id)
@@ -372,15 +778,19 @@ func (m *BotDB) QueryRow(id int64) (PurchaseRecord, error) {
 		whereClause: fmt.Sprintf("where %s = %d", t.primaryKeyCol(secondaryKey), id),
 		cols:        t.columns(query),
 	}
+	if lock {
+		opts.lockTable = t.name()
+	}
 
 	stmt := selectWhereStmt(opts)
-	err := m.db.QueryRow(stmt).Scan(r.args(query)...)
+	err := q.QueryRow(stmt).Scan(r.args(query)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return r, ErrNoRows
 		}
 		return r, newBotDbError("BotDB: QueryRow", stmt, err, r.args(query)...)
 	}
+	r.inLocation(m.loc)
 
 	// we add specific query option to the record
 	// this needed for the record to properly
@@ -406,13 +816,17 @@ const (
 	FutureAuction
 	FutureGo
 	FutureMoney
+	UpcomingGo
+	UpcomingAuction
+	Found
 )
 
 // String returns string representation of queryOpt
 func (q QueryOpt) String() string {
 	return []string{"", "", "*Сегодня*\n\n", "*Впереди*\n\n", "*Результаты*\n\n",
 		"*Аукционы* ⚔️\n\n", "*Заявки* 🏃\n\n", "*Аукционы* ⚔️\n\n",
-		"*Заявки* 🏃\n\n", "*Обеспечения заявок* 💰\n\n"}[q]
+		"*Заявки* 🏃\n\n", "*Обеспечения заявок* 💰\n\n", "*Заявки* 🏃\n\n",
+		"*Аукционы* ⚔️\n\n", "*Найдено* 🔎\n\n"}[q]
 }
 
 // tableOpt returns tableOpt option based on self
@@ -425,108 +839,95 @@ func (q QueryOpt) tableOpt() tableOpt {
 	}
 }
 
-// stmtOpts builds stmtOpts based on self
-func (q QueryOpt) stmtOpts(daysLimit int, t table) stmtOpts {
+// stmtOpts builds stmtOpts based on self and returns
+// them with the arguments for placeholders
+func (q QueryOpt) stmtOpts(daysLimit int, now time.Time, cal Calendar, t table) (stmtOpts, []interface{}) {
+	where, args := q.whereClause(daysLimit, now, cal)
+
 	switch q {
 	case FutureMoney:
 		return stmtOpts{
 			tableName:   t.name(),
 			fromClause:  buildFromClause(t, left),
-			whereClause: q.whereClause(daysLimit),
+			whereClause: where,
 			groupBy:     []string{ourParticipants, statusName},
 			cols:        t.columns(queryMoney),
-		}
+		}, args
 	default:
 		return stmtOpts{
 			tableName:   t.name(),
 			fromClause:  buildFromClause(t, left),
-			whereClause: q.whereClause(daysLimit),
+			whereClause: where,
 			orderBy:     []string{biddingColumn},
 			cols:        t.columns(query),
-		}
+		}, args
 	}
 }
 
-// whereClause builds where clause based on self
-func (q QueryOpt) whereClause(daysLimit int) string {
-	var b strings.Builder
-
-	switch q {
+// whereClause builds where clause based on self and returns
+// it with the arguments for placeholders. Days are counted
+// from the day of now in its time zone, the next workday
+// is told by the calendar
+func (q QueryOpt) whereClause(daysLimit int, now time.Time, cal Calendar) (string, []interface{}) {
+	var args []interface{}
+
+	// day adds midnight n days after today to
+	// the arguments and returns its placeholder
+	day := func(n int) string {
+		args = append(args, dayStart(now, n))
+		return fmt.Sprintf("$%d", len(args))
+	}
 
-	case Today:
-		pd := plusDays(time.Now().Weekday())
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusAuction, statusAuction2))
-		b.WriteString(fmt.Sprintf(" and date_trunc('day', %s) = current_date::timestamp)", biddingColumn))
-		b.WriteString(fmt.Sprintf("  or (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-		b.WriteString(fmt.Sprintf(" and date_trunc('day', %s) = (current_date+%d)::timestamp)", collectingColumn, pd))
+	auction := fmt.Sprintf("%s in ('%s', '%s')", statusName, statusAuction, statusAuction2)
+	goes := fmt.Sprintf("%s in ('%s', '%s')", statusName, statusGo, statusEstim)
+	held := fmt.Sprintf("%s in ('%s', '%s')", statusName, statusWin, statusLost)
 
-	case Future:
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusAuction, statusAuction2))
+	// span is 'col' within days [from, to)
+	span := func(col string, from, to int) string {
+		return fmt.Sprintf("%s >= %s and %s < %s", col, day(from), col, day(to))
+	}
+	// between is 'col' within days [from, to] including the midnight of to
+	between := func(col string, from, to int) string {
+		return fmt.Sprintf("%s between %s and %s", col, day(from), day(to))
+	}
+	// future is the next days within the limit if any
+	future := func(col string) string {
 		if daysLimit > 0 {
-			b.WriteString(fmt.Sprintf(" and %s between (current_date+1)::timestamp and (current_date+%d)::timestamp)", biddingColumn, daysLimit))
-			b.WriteString(fmt.Sprintf("or (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-			b.WriteString(fmt.Sprintf(" and %s between (current_date+1)::timestamp and (current_date+%d)::timestamp)", collectingColumn, daysLimit))
-			return b.String()
+			return between(col, 1, daysLimit)
 		}
-		b.WriteString(fmt.Sprintf(" and %s >= (current_date+1)::timestamp)", biddingColumn))
-		b.WriteString(fmt.Sprintf("or (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-		b.WriteString(fmt.Sprintf(" and %s >= (current_date+1)::timestamp)", collectingColumn))
+		return fmt.Sprintf("%s >= %s", col, day(1))
+	}
+	// where joins the alternatives of the clause
+	where := func(conds ...string) string {
+		return "where (" + strings.Join(conds, ") or (") + ")"
+	}
 
+	switch q {
+	case Today:
+		pd := cal.plusDays(now)
+		return where(auction+" and "+span(biddingColumn, 0, 1),
+			goes+" and "+span(collectingColumn, pd, pd+1)), args
+	case Future:
+		return where(auction+" and "+future(biddingColumn), goes+" and "+future(collectingColumn)), args
 	case Past:
 		if daysLimit > 0 {
-			return fmt.Sprintf("where (%s in ('%s', '%s') and %s between (current_date-%d)::timestamp and current_date::timestamp)",
-				statusName, statusWin, statusLost, biddingColumn, daysLimit)
+			return where(held + " and " + between(biddingColumn, -daysLimit, 0)), args
 		}
-		return fmt.Sprintf("where (%s in ('%s', '%s') and %s < current_date::timestamp)",
-			statusName, statusWin, statusLost, biddingColumn)
-
+		return where(fmt.Sprintf("%s and %s < %s", held, biddingColumn, day(0))), args
 	case TodayAuction:
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusAuction, statusAuction2))
-		b.WriteString(fmt.Sprintf(" and date_trunc('day', %s) = current_date::timestamp)", biddingColumn))
-
+		return where(auction + " and " + span(biddingColumn, 0, 1)), args
 	case TodayGo:
-		pd := plusDays(time.Now().Weekday())
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-		b.WriteString(fmt.Sprintf(" and date_trunc('day', %s) = (current_date+%d)::timestamp)", collectingColumn, pd))
-
+		pd := cal.plusDays(now)
+		return where(goes + " and " + span(collectingColumn, pd, pd+1)), args
 	case FutureAuction:
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusAuction, statusAuction2))
-		if daysLimit > 0 {
-			b.WriteString(fmt.Sprintf(" and %s between (current_date+1)::timestamp and (current_date+%d)::timestamp)", biddingColumn, daysLimit))
-			return b.String()
-		}
-		b.WriteString(fmt.Sprintf(" and %s >= (current_date+1)::timestamp)", biddingColumn))
-
-	case FutureGo:
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-		if daysLimit > 0 {
-			b.WriteString(fmt.Sprintf(" and %s between (current_date+1)::timestamp and (current_date+%d)::timestamp)", collectingColumn, daysLimit))
-			return b.String()
-		}
-		b.WriteString(fmt.Sprintf(" and %s >= (current_date+1)::timestamp)", collectingColumn))
-
-	case FutureMoney:
-		b.WriteString(fmt.Sprintf("where (%s in ('%s', '%s')", statusName, statusGo, statusEstim))
-		if daysLimit > 0 {
-			b.WriteString(fmt.Sprintf(" and %s between (current_date+1)::timestamp and (current_date+%d)::timestamp)", collectingColumn, daysLimit))
-			return b.String()
-		}
-		b.WriteString(fmt.Sprintf(" and %s >= (current_date+1)::timestamp)", collectingColumn))
+		return where(auction + " and " + future(biddingColumn)), args
+	case FutureGo, FutureMoney:
+		return where(goes + " and " + future(collectingColumn)), args
+	case UpcomingGo:
+		return where(goes + " and " + span(collectingColumn, 0, daysLimit+1)), args
+	case UpcomingAuction:
+		return where(auction + " and " + span(biddingColumn, 0, daysLimit+1)), args
 	}
 
-	return b.String()
-}
-
-// plusDays returns amount of days that
-// need to be added to provided weekday to get next
-// workday
-func plusDays(d time.Weekday) int {
-	switch d {
-	case time.Friday:
-		return 3
-	case time.Saturday:
-		return 2
-	default:
-		return 1
-	}
+	return "", nil
 }
@@ -0,0 +1,66 @@
+package botDB
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// errNoConn is returned by every statement of the broken database
+var errNoConn = errors.New("no connection")
+
+// brokenConnector fails to connect, so every statement fails
+type brokenConnector struct{}
+
+func (brokenConnector) Connect(context.Context) (driver.Conn, error) { return nil, errNoConn }
+func (brokenConnector) Driver() driver.Driver                        { return brokenDriver{} }
+
+type brokenDriver struct{}
+
+func (brokenDriver) Open(string) (driver.Conn, error) { return nil, errNoConn }
+
+func TestBotDB_concurrentUpdates(t *testing.T) {
+	db := sql.OpenDB(brokenConnector{})
+	defer db.Close()
+	m := NewBotDB(db, SystemClock{}, Calendar{})
+
+	body := func(v any) io.ReadCloser {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal() error=%v", err)
+		}
+		return io.NopCloser(bytes.NewReader(b))
+	}
+
+	// updates posted at once don't share the incoming records,
+	// run with -race to catch the data race
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			recs := []PurchaseRecord{validRecord("0859200001122007104"), validRecord("0859200001122007105")}
+			if _, err := m.Upsert(body(recs), Strict); err == nil || errors.Is(err, ErrInvalidRecords) {
+				t.Errorf("BotDB.Upsert() error=%v, want database error", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			d := Delta{Records: []PurchaseRecord{validRecord("0859200001122007106")}}
+			if _, err := m.ApplyDelta(body(d), Strict); err == nil || errors.Is(err, ErrInvalidRecords) {
+				t.Errorf("BotDB.ApplyDelta() error=%v, want database error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m.records != nil || m.refMap != nil {
+		t.Errorf("BotDB state of the updates is kept: records=%v refMap=%v", m.records, m.refMap)
+	}
+}
@@ -0,0 +1,302 @@
+package botDB
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidValue is returned when
+// the edited value can't be set to the field
+var ErrInvalidValue = errors.New("invalid value of the field")
+
+// Field is the purchase field which can be edited in the bot
+type Field string
+
+// editable field
+const (
+	StatusField      Field = "status"
+	ParticipantField Field = "participant"
+	EstimationField  Field = "estimation"
+	WinnerField      Field = "winner"
+	WinnerPriceField Field = "price"
+)
+
+// Fields is the list of all editable fields
+var Fields = []Field{StatusField, ParticipantField,
+	EstimationField, WinnerField, WinnerPriceField}
+
+// editableField describes how the field is stored
+type editableField struct {
+	col     string // purchase table column
+	tracked string // tracked field name as in the history
+	numeric bool
+	set     func(p *PurchaseRecord, v string) // sets the value to the incoming record
+}
+
+var editableFields = map[Field]editableField{
+	StatusField: {statusColumn, statusName, false,
+		func(p *PurchaseRecord, v string) { p.Status = v }},
+	ParticipantField: {ourParticipants, ourParticipants, false,
+		func(p *PurchaseRecord, v string) { p.OurParticipants = v }},
+	EstimationField: {estimationColumn, estimationColumn, true,
+		func(p *PurchaseRecord, v string) { p.Estimation, _ = strconv.ParseFloat(v, 64) }},
+	WinnerField: {winnerColumn, winnerColumn, false,
+		func(p *PurchaseRecord, v string) { p.Winner = v }},
+	WinnerPriceField: {winnerPrice, winnerPrice, true,
+		func(p *PurchaseRecord, v string) { p.WinnerPrice, _ = strconv.ParseFloat(v, 64) }},
+}
+
+// editableByTracked returns the editable field by its tracked name
+func editableByTracked(tracked string) (editableField, bool) {
+	for _, f := range editableFields {
+		if f.tracked == tracked {
+			return f, true
+		}
+	}
+	return editableField{}, false
+}
+
+// ParseField returns the editable field by its name
+func ParseField(s string) (Field, bool) {
+	_, ok := editableFields[Field(s)]
+	return Field(s), ok
+}
+
+// Label returns human readable name of the field
+func (f Field) Label() string {
+	c := Change{Field: editableFields[f].tracked}
+	return c.Label()
+}
+
+// normalize returns the argument of the edit statement
+// and the value in the form it is kept in the history.
+// Empty value clears the field. Zero amount is kept in the
+// history as empty as well, so it's rejected to not be
+// mistaken for the cleared field
+func (f *editableField) normalize(v string) (any, string, error) {
+	v = strings.TrimSpace(v)
+	if !f.numeric {
+		return sql.NullString{String: v, Valid: v != ""}, v, nil
+	}
+	if v == "" {
+		return sql.NullFloat64{}, "", nil
+	}
+
+	// i.e. '1 234,56' as it is written in the sheet
+	n, err := strconv.ParseFloat(strings.ReplaceAll(strings.ReplaceAll(v, " ", ""), ",", "."), 64)
+	if err != nil || n <= 0 {
+		return nil, "", ErrInvalidValue
+	}
+	return sql.NullFloat64{Float64: n, Valid: true}, moneyValue(n), nil
+}
+
+// Apply sets the value of the field to the record the
+// same way the upsert keeps the edited one. It returns
+// the change of the field, old value is the one
+// the record had before
+func (f Field) Apply(p *PurchaseRecord, value string) (Change, error) {
+	ef, ok := editableFields[f]
+	if !ok {
+		return Change{}, ErrInvalidValue
+	}
+	_, norm, err := ef.normalize(value)
+	if err != nil {
+		return Change{}, err
+	}
+
+	c := Change{
+		RegistryNumber: p.RegistryNumber,
+		PurchaseId:     p.PurchaseId,
+		Field:          ef.tracked,
+		Old:            trackedValue(ef.tracked, p),
+		New:            norm,
+	}
+	ef.set(p, norm)
+
+	return c, nil
+}
+
+// editStatement returns the statement which sets
+// the column of the purchase with id $2 to $1.
+// Status is set by its name
+func editStatement(col string) string {
+	v := "$1"
+	if col == statusColumn {
+		v = `(select ` + statusID + ` from ` + statusTableName + ` where ` + statusName + ` = $1)`
+	}
+	return `update ` + purchTableName + ` set ` + col + ` = ` + v + ` where ` + purchaseID + ` = $2;`
+}
+
+// trackedValue returns the value of the tracked field of the record
+func trackedValue(tracked string, p *PurchaseRecord) string {
+	for i := range trackedFields {
+		if trackedFields[i].col == tracked {
+			return trackedFields[i].value(p)
+		}
+	}
+	return ""
+}
+
+// Conflict is the field of the incoming record which
+// differs from the value edited in the bot. Edited value
+// is kept until the incoming one is the same or the
+// field is released
+type Conflict struct {
+	RegistryNumber string `json:"registry_number"`
+	Field          string `json:"field"`    // tracked field name
+	Incoming       string `json:"incoming"` // value of the update
+	Kept           string `json:"kept"`     // value edited in the bot
+}
+
+// Edit sets the field of the purchase with provided id.
+// The value is kept on the following upserts, so the
+// update from the sheet doesn't revert it. The change
+// is written to the history, by is the user who edited it
+func (m *BotDB) Edit(id int64, f Field, value string, by int64) (Change, error) {
+	var c Change
+
+	ef, ok := editableFields[f]
+	if !ok {
+		return c, ErrInvalidValue
+	}
+	arg, norm, err := ef.normalize(value)
+	if err != nil {
+		return c, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return c, err
+	}
+
+	defer tx.Rollback()
+
+	// the upsert can't change the record until the edit is done
+	p, err := m.queryRow(tx, id, true)
+	if err != nil {
+		return c, err
+	}
+
+	if f == StatusField && norm != "" {
+		if _, err = tx.Exec(statusEnsureStatement, norm); err != nil {
+			return c, newBotDbError("BotDB: Edit", statusEnsureStatement, err, norm)
+		}
+	}
+
+	stmt := editStatement(ef.col)
+	if _, err = tx.Exec(stmt, arg, id); err != nil {
+		return c, newBotDbError("BotDB: Edit", stmt, err, arg, id)
+	}
+
+	args := []any{p.RegistryNumber, ef.tracked, norm, by}
+	if _, err = tx.Exec(overrideUpsertStatement, args...); err != nil {
+		return c, newBotDbError("BotDB: Edit", overrideUpsertStatement, err, args...)
+	}
+
+	c = Change{
+		RegistryNumber: p.RegistryNumber,
+		PurchaseId:     p.PurchaseId,
+		Field:          ef.tracked,
+		Old:            trackedValue(ef.tracked, &p),
+		New:            norm,
+		ChangedAt:      m.clock.Now(),
+	}
+	if c.Old != c.New {
+		if err = m.writeHistory(tx, []Change{c}); err != nil {
+			return c, err
+		}
+	}
+
+	return c, tx.Commit()
+}
+
+// Release stops keeping the edited field of the purchase,
+// so the next upsert sets it. It reports if the field was edited
+func (m *BotDB) Release(id int64, f Field) (bool, error) {
+	ef, ok := editableFields[f]
+	if !ok {
+		return false, ErrInvalidValue
+	}
+
+	p, err := m.QueryRow(id)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := m.db.Exec(overrideDeleteStatement, p.RegistryNumber, ef.tracked)
+	if err != nil {
+		return false, newBotDbError("BotDB: Release", overrideDeleteStatement, err, p.RegistryNumber, ef.tracked)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// applyOverrides sets the edited values to the incoming
+// records and returns the fields where they differ.
+// Edited values which are the same as incoming
+// ones are not needed anymore and removed. Overrides
+// are locked, so the edits wait for the upsert to end
+func (m *BotDB) applyOverrides(tx *sql.Tx) ([]Conflict, error) {
+	var conflicts []Conflict
+
+	nums := make([]string, len(m.records))
+	for i := range m.records {
+		nums[i] = m.records[i].RegistryNumber
+	}
+
+	rows, err := tx.Query(overrideSelectStatement, pq.Array(nums))
+	if err != nil {
+		return nil, newBotDbError("BotDB: applyOverrides", overrideSelectStatement, err)
+	}
+
+	defer rows.Close()
+
+	overrides := make(map[string][]Conflict)
+	for rows.Next() {
+		var o Conflict
+		if err = rows.Scan(&o.RegistryNumber, &o.Field, &o.Kept); err != nil {
+			return nil, newBotDbError("BotDB: applyOverrides Scan", overrideSelectStatement, err)
+		}
+		overrides[o.RegistryNumber] = append(overrides[o.RegistryNumber], o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// the statement on the same transaction can't run while rows are open
+	rows.Close()
+
+	for i := range m.records {
+		for _, o := range overrides[m.records[i].RegistryNumber] {
+			ef, ok := editableByTracked(o.Field)
+			if !ok {
+				continue
+			}
+
+			o.Incoming = trackedValue(o.Field, &m.records[i])
+			if o.Incoming == o.Kept {
+				// the sheet is up to date with the bot
+				_, err = tx.Exec(overrideDeleteStatement, o.RegistryNumber, o.Field)
+				if err != nil {
+					return nil, newBotDbError("BotDB: applyOverrides", overrideDeleteStatement,
+						err, o.RegistryNumber, o.Field)
+				}
+				continue
+			}
+
+			ef.set(&m.records[i], o.Kept)
+			conflicts = append(conflicts, o)
+		}
+		// kept status refers to the other status row
+		if len(overrides[m.records[i].RegistryNumber]) > 0 {
+			if err = m.setForeignKeys(&m.records[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return conflicts, nil
+}
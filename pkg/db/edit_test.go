@@ -0,0 +1,57 @@
+package botDB
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func Test_editableField_normalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Field
+		value   string
+		arg     any
+		norm    string
+		wantErr bool
+	}{
+		{"text", ParticipantField, " ООО Ромашка ", sql.NullString{String: "ООО Ромашка", Valid: true}, "ООО Ромашка", false},
+		{"text_cleared", WinnerField, "", sql.NullString{}, "", false},
+		{"number", WinnerPriceField, "1 234,5", sql.NullFloat64{Float64: 1234.5, Valid: true}, "1234.50", false},
+		{"number_cleared", EstimationField, "", sql.NullFloat64{}, "", false},
+		{"not_a_number", EstimationField, "много", nil, "", true},
+		{"negative", WinnerPriceField, "-1", nil, "", true},
+		{"zero", EstimationField, "0,00", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ef := editableFields[tt.f]
+			arg, norm, err := ef.normalize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("editableField.normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if arg != tt.arg || norm != tt.norm {
+				t.Errorf("editableField.normalize() = %v, %q, want %v, %q", arg, norm, tt.arg, tt.norm)
+			}
+		})
+	}
+}
+
+func Test_editableFields(t *testing.T) {
+	for _, f := range Fields {
+		ef, ok := editableFields[f]
+		if !ok {
+			t.Fatalf("field %s is not editable", f)
+		}
+		// edited value is set to the incoming record the same
+		// way it is compared with the incoming one
+		var p PurchaseRecord
+		_, norm, _ := ef.normalize("12")
+		ef.set(&p, norm)
+		if got := trackedValue(ef.tracked, &p); got != norm {
+			t.Errorf("field %s: trackedValue() = %q, want %q", f, got, norm)
+		}
+		if f.Label() == ef.tracked {
+			t.Errorf("field %s has no label", f)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package botDB
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maximum number of the records in the calendar feed
+const maxFeedRecords = 1000
+
+// FeedFilter is the criteria of the calendar feed.
+// Empty text fields are not taken into account
+type FeedFilter struct {
+	Participant string // our participant
+	Region      string
+	Since       time.Time // purchases with all events before are left out
+}
+
+// whereClause builds where clause of the feed
+// and returns it with the arguments for placeholders
+func (f *FeedFilter) whereClause(t table) (string, []interface{}) {
+	args := []interface{}{f.Since}
+	conds := []string{fmt.Sprintf("(%s.%s >= $1 or %s.%s >= $1)",
+		t.name(), biddingColumn, t.name(), collectingColumn)}
+
+	filters := []struct{ col, value string }{
+		{regionName, f.Region},
+		{ourParticipants, f.Participant},
+	}
+	for _, c := range filters {
+		if c.value != "" {
+			args = append(args, escapeLike(c.value))
+			conds = append(conds, fmt.Sprintf("%s ilike '%%' || $%d || '%%'", c.col, len(args)))
+		}
+	}
+
+	return "where " + strings.Join(conds, " and "), args
+}
+
+// feedOrder orders the feed by the latest event of the
+// purchase, so the limit leaves out the oldest purchases
+// and the upcoming ones are always in the feed
+func feedOrder(t table) string {
+	return fmt.Sprintf("coalesce(%s.%s, %s.%s) desc", t.name(), biddingColumn, t.name(), collectingColumn)
+}
+
+// Feed returns the purchases with auction or
+// applications deadline since the filter time.
+// The latest purchases come first
+func (m *BotDB) Feed(f FeedFilter) ([]PurchaseRecord, error) {
+	var recs []PurchaseRecord
+	var r PurchaseRecord
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	where, args := f.whereClause(t)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: where,
+		orderBy:     []string{feedOrder(t), t.name() + "." + t.primaryKeyCol(secondaryKey)},
+		limit:       maxFeedRecords,
+		cols:        t.columns(query),
+	})
+
+	rows, err := m.db.Query(stmt, args...)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Feed", stmt, err, args...)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return nil, newBotDbError("BotDB: Feed Scan", stmt, err, args...)
+		}
+		r.inLocation(m.loc)
+		r.QueryType = General
+		recs = append(recs, r)
+	}
+
+	return recs, rows.Err()
+}
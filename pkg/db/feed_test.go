@@ -0,0 +1,33 @@
+package botDB
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedFilter_whereClause(t *testing.T) {
+	since := time.Date(2022, time.July, 5, 0, 0, 0, 0, time.UTC)
+	f := FeedFilter{Region: "Тверская", Since: since}
+
+	where, args := f.whereClause(purchaseTable{})
+
+	if len(args) != 2 || args[0] != since || args[1] != "Тверская" {
+		t.Errorf("FeedFilter.whereClause() args = %v", args)
+	}
+	for _, want := range []string{"purchase_registry.bidding >= $1", "region_name ilike '%' || $2 || '%'"} {
+		if !strings.Contains(where, want) {
+			t.Errorf("FeedFilter.whereClause() = %s, want it to contain %s", where, want)
+		}
+	}
+	if strings.Contains(where, ourParticipants) {
+		t.Errorf("FeedFilter.whereClause() = %s, empty criteria must be skipped", where)
+	}
+}
+
+func Test_feedOrder(t *testing.T) {
+	want := "coalesce(purchase_registry.bidding, purchase_registry.collecting) desc"
+	if got := feedOrder(purchaseTable{}); got != want {
+		t.Errorf("feedOrder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,289 @@
+package botDB
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Change is the change of the single
+// purchase field made by the upsert
+type Change struct {
+	RegistryNumber string
+	PurchaseId     int64
+	Field          string // column name
+	Old            string
+	New            string
+	ChangedAt      time.Time
+}
+
+// ChangeKind is the kind of the purchase change
+// which can be announced to the users
+type ChangeKind string
+
+// change kind
+const (
+	StatusChange      ChangeKind = "status"
+	BiddingChange     ChangeKind = "bidding"
+	CollectingChange  ChangeKind = "collecting"
+	WinnerChange      ChangeKind = "winner"
+	ParticipantChange ChangeKind = "participant"
+	OtherChange       ChangeKind = "other"
+)
+
+// ChangeKinds is the list of all change kinds
+var ChangeKinds = []ChangeKind{StatusChange, BiddingChange,
+	CollectingChange, WinnerChange, ParticipantChange, OtherChange}
+
+// Kind returns the kind of the change
+func (c *Change) Kind() ChangeKind {
+	switch c.Field {
+	case statusName:
+		return StatusChange
+	case biddingColumn:
+		return BiddingChange
+	case collectingColumn:
+		return CollectingChange
+	case winnerColumn, winnerPrice:
+		return WinnerChange
+	case ourParticipants:
+		return ParticipantChange
+	default:
+		return OtherChange
+	}
+}
+
+// IsPrice reports if the changed field is the winner price
+func (c *Change) IsPrice() bool { return c.Field == winnerPrice }
+
+// Label returns human readable name of the changed field
+func (c *Change) Label() string {
+	for i := range trackedFields {
+		if trackedFields[i].col == c.Field {
+			return trackedFields[i].label
+		}
+	}
+	return c.Field
+}
+
+// When returns the time of change in its time zone
+func (c *Change) When() string {
+	return c.ChangedAt.Format("02.01.2006 15:04")
+}
+
+// trackedField is the record field
+// which changes are kept in history
+type trackedField struct {
+	col   string
+	label string
+	value func(p *PurchaseRecord) string
+}
+
+// trackedFields are the record fields
+// which changes are kept in history
+var trackedFields = []trackedField{
+	{purchaseSubject, "Предмет", func(p *PurchaseRecord) string { return p.PurchaseSubject }},
+	{purchaseStringCodeName, "Код", func(p *PurchaseRecord) string { return p.PurchaseSubjectAbbr }},
+	{purchaseTypeName, "Тип закупки", func(p *PurchaseRecord) string { return p.PurchaseType }},
+	{collectingColumn, "Подача", func(p *PurchaseRecord) string { return timeValue(p.CollectingDateTime) }},
+	{approvalColumn, "Рассмотрение", func(p *PurchaseRecord) string { return dateValue(p.ApprovalDateTime) }},
+	{biddingColumn, "Аукцион", func(p *PurchaseRecord) string { return timeValue(p.BiddingDateTime) }},
+	{regionName, "Регион", func(p *PurchaseRecord) string { return p.Region }},
+	{customerTypeName, "Заказчик", func(p *PurchaseRecord) string { return p.CustomerType }},
+	{maxPrice, "НМЦК", func(p *PurchaseRecord) string { return moneyValue(p.MaxPrice) }},
+	{applicationGuarantee, "Обеспечение заявки", func(p *PurchaseRecord) string { return moneyValue(p.ApplicationGuarantee) }},
+	{contractGuarantee, "Обеспечение контракта", func(p *PurchaseRecord) string { return moneyValue(p.ContractGuarantee) }},
+	{statusName, "Статус", func(p *PurchaseRecord) string { return p.Status }},
+	{ourParticipants, "Участник", func(p *PurchaseRecord) string { return p.OurParticipants }},
+	{estimationColumn, "Расчёт", func(p *PurchaseRecord) string { return moneyValue(p.Estimation) }},
+	{etpName, "Площадка", func(p *PurchaseRecord) string { return p.ETP }},
+	{winnerColumn, "Победитель", func(p *PurchaseRecord) string { return p.Winner }},
+	{winnerPrice, "Цена победителя", func(p *PurchaseRecord) string { return moneyValue(p.WinnerPrice) }},
+	{participantsColumn, "Участники", func(p *PurchaseRecord) string { return p.Participants }},
+}
+
+func timeValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// dateValue is for the date columns. Time of the day and
+// the time zone are not stored, so the date is taken as is
+func dateValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("02.01.2006")
+}
+
+func moneyValue(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// existing returns current state of the
+// BotDB records which are already in database
+func (m *BotDB) existing(tx *sql.Tx) (map[string]PurchaseRecord, error) {
+	var r PurchaseRecord
+
+	nums := make([]string, len(m.records))
+	for i := range m.records {
+		nums[i] = m.records[i].RegistryNumber
+	}
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: fmt.Sprintf("where %s.%s = any($1)", t.name(), registryNumber),
+		cols:        t.columns(query),
+	})
+
+	rows, err := tx.Query(stmt, pq.Array(nums))
+	if err != nil {
+		return nil, newBotDbError("BotDB: existing", stmt, err)
+	}
+
+	defer rows.Close()
+
+	old := make(map[string]PurchaseRecord, len(m.records))
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return nil, newBotDbError("BotDB: existing Scan", stmt, err)
+		}
+		r.fillFromNullable()
+		old[r.RegistryNumber] = r
+	}
+
+	return old, rows.Err()
+}
+
+// diff compares BotDB records with their previous
+// state and returns changes of the tracked fields.
+// New records are not considered as changed
+func (m *BotDB) diff(old map[string]PurchaseRecord) []Change {
+	var changes []Change
+
+	now := m.clock.Now()
+
+	for i := range m.records {
+		o, ok := old[m.records[i].RegistryNumber]
+		if !ok {
+			continue
+		}
+		changes = append(changes, Diff(&o, &m.records[i], now)...)
+	}
+
+	return changes
+}
+
+// Diff compares the tracked fields of the record with its
+// previous state and returns the changes made at the time.
+// Times are compared in the time zone of the change
+func Diff(old, p *PurchaseRecord, at time.Time) []Change {
+	var changes []Change
+
+	o, n := *old, *p
+	o.inLocation(at.Location())
+	n.inLocation(at.Location())
+
+	for _, f := range trackedFields {
+		ov, nv := f.value(&o), f.value(&n)
+		if ov == nv {
+			continue
+		}
+		changes = append(changes, Change{
+			RegistryNumber: old.RegistryNumber,
+			PurchaseId:     old.PurchaseId,
+			Field:          f.col,
+			Old:            ov,
+			New:            nv,
+			ChangedAt:      at,
+		})
+	}
+
+	return changes
+}
+
+// writeHistory inserts changes to the history table
+func (m *BotDB) writeHistory(tx *sql.Tx, changes []Change) error {
+	t := m.tk.table(historyTableName)
+	cols := t.columns(upsert)
+	size := chunkSize(len(cols))
+
+	for _, b := range chunkBounds(len(changes), size) {
+		i, end := b[0], b[1]
+
+		stmt := insertStatement(stmtOpts{
+			tableName:  t.name(),
+			multiplier: end - i,
+			cols:       cols,
+		})
+
+		args := make([]interface{}, 0, (end-i)*len(cols))
+		for j := i; j < end; j++ {
+			args = append(args, changes[j].args()...)
+		}
+
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return newBotDbError("BotDB: writeHistory", stmt, err)
+		}
+	}
+
+	return nil
+}
+
+// args returns Change fields that taking
+// part in insert operation
+func (c *Change) args() []interface{} {
+	return []interface{}{
+		c.RegistryNumber, c.Field, sql.NullString{String: c.Old, Valid: c.Old != ""},
+		sql.NullString{String: c.New, Valid: c.New != ""}, c.ChangedAt,
+	}
+}
+
+// History returns changes of the purchase
+// with provided id in chronological order
+func (m *BotDB) History(id int64) ([]Change, error) {
+	var changes []Change
+
+	t := m.tk.table(historyTableName)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, inner),
+		whereClause: fmt.Sprintf("where %s = $1", purchaseID),
+		orderBy:     []string{changedAt, historyID},
+		cols:        t.columns(query),
+	})
+
+	rows, err := m.db.Query(stmt, id)
+	if err != nil {
+		return nil, newBotDbError("BotDB: History", stmt, err, id)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Change
+		var ov, nv sql.NullString
+		err = rows.Scan(&c.RegistryNumber, &c.PurchaseId, &c.Field, &ov, &nv, &c.ChangedAt)
+		if err != nil {
+			return nil, newBotDbError("BotDB: History Scan", stmt, err, id)
+		}
+		c.Old, c.New = ov.String, nv.String
+		c.ChangedAt = c.ChangedAt.In(m.loc)
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
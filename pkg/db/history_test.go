@@ -0,0 +1,75 @@
+package botDB
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_trackedFields(t *testing.T) {
+	seen := make(map[string]bool, len(trackedFields))
+	for _, f := range trackedFields {
+		if seen[f.col] {
+			t.Errorf("field %s is tracked twice", f.col)
+		}
+		seen[f.col] = true
+		if f.label == "" || f.label == f.col {
+			t.Errorf("field %s has no label", f.col)
+		}
+		// empty record has no values to compare
+		if v := f.value(&PurchaseRecord{}); v != "" {
+			t.Errorf("field %s: value of empty record = %q, want empty", f.col, v)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := time.Date(2022, time.July, 5, 10, 0, 0, 0, msk)
+
+	incoming := PurchaseRecord{
+		RegistryNumber:     "0000000000000000001",
+		PurchaseSubject:    "subject",
+		CollectingDateTime: time.Date(2022, time.July, 10, 9, 0, 0, 0, msk),
+		ApprovalDateTime:   time.Date(2022, time.July, 11, 9, 0, 0, 0, msk),
+		BiddingDateTime:    time.Date(2022, time.July, 12, 9, 0, 0, 0, msk),
+		MaxPrice:           100000,
+		Status:             statusGo,
+	}
+
+	// state as it's read back from the database: approval is the
+	// date column, so it comes as midnight UTC, the rest is in UTC
+	stored := incoming
+	stored.PurchaseId = 1
+	stored.CollectingDateTime = incoming.CollectingDateTime.UTC()
+	stored.ApprovalDateTime = time.Date(2022, time.July, 11, 0, 0, 0, 0, time.UTC)
+	stored.BiddingDateTime = incoming.BiddingDateTime.UTC()
+
+	t.Run("unchanged", func(t *testing.T) {
+		if changes := Diff(&stored, &incoming, at); len(changes) != 0 {
+			t.Errorf("Diff() = %+v, want no changes", changes)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		p := incoming
+		p.ApprovalDateTime = p.ApprovalDateTime.AddDate(0, 0, 1)
+		p.Status = statusAuction
+
+		changes := Diff(&stored, &p, at)
+		if len(changes) != 2 {
+			t.Fatalf("Diff() = %+v, want 2 changes", changes)
+		}
+
+		want := []Change{
+			{RegistryNumber: p.RegistryNumber, PurchaseId: 1, Field: approvalColumn,
+				Old: "11.07.2022", New: "12.07.2022", ChangedAt: at},
+			{RegistryNumber: p.RegistryNumber, PurchaseId: 1, Field: statusName,
+				Old: statusGo, New: statusAuction, ChangedAt: at},
+		}
+		for i := range want {
+			if changes[i] != want[i] {
+				t.Errorf("Diff() change %d = %+v, want %+v", i, changes[i], want[i])
+			}
+		}
+	})
+}
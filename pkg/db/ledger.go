@@ -0,0 +1,57 @@
+package botDB
+
+import "time"
+
+// SentNotification is the ledger entry of the
+// reminder stage that was sent to the chat
+type SentNotification struct {
+	PurchaseId int64
+	Event      string        // kind of the purchase event
+	Lead       time.Duration // how long before the event reminder was sent
+	EventTime  time.Time
+}
+
+// SentNotifications returns ledger entries of
+// the events that happen not earlier than since
+func (m *BotDB) SentNotifications(since time.Time) ([]SentNotification, error) {
+	var res []SentNotification
+
+	rows, err := m.db.Query(sentSelectStatement, since)
+	if err != nil {
+		return nil, newBotDbError("BotDB: SentNotifications", sentSelectStatement, err, since)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var sn SentNotification
+		var lead int64
+		if err = rows.Scan(&sn.PurchaseId, &sn.Event, &lead, &sn.EventTime); err != nil {
+			return nil, newBotDbError("BotDB: SentNotifications Scan", sentSelectStatement, err, since)
+		}
+		sn.Lead = time.Duration(lead) * time.Second
+		res = append(res, sn)
+	}
+
+	return res, rows.Err()
+}
+
+// PruneSentNotifications removes ledger entries of the events
+// that happened before. It returns the number of removed entries
+func (m *BotDB) PruneSentNotifications(before time.Time) (int64, error) {
+	res, err := m.db.Exec(sentDeleteStatement, before)
+	if err != nil {
+		return 0, newBotDbError("BotDB: PruneSentNotifications", sentDeleteStatement, err, before)
+	}
+	return res.RowsAffected()
+}
+
+// MarkSent puts the entry to the ledger
+func (m *BotDB) MarkSent(sn SentNotification) error {
+	args := []any{sn.PurchaseId, sn.Event, int64(sn.Lead / time.Second), sn.EventTime}
+
+	if _, err := m.db.Exec(sentInsertStatement, args...); err != nil {
+		return newBotDbError("BotDB: MarkSent", sentInsertStatement, err, args...)
+	}
+	return nil
+}
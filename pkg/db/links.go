@@ -0,0 +1,78 @@
+package botDB
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EIS purchase page. 44-FZ notice pages differ by the procedure
+// type, so the search by the registry number is used for them
+const (
+	eis44URL  = "https://zakupki.gov.ru/epz/order/extendedsearch/results.html?fz44=on&searchString="
+	eis223URL = "https://zakupki.gov.ru/epz/order/notice/notice223/common-info.html?regNumber="
+)
+
+// length of the 223-FZ registry number, 44-FZ ones are 19 digits long
+const registryNumber223Len = 11
+
+// ETPNumberPlaceholder is replaced with the registry
+// number in the ETP procedure URL template
+const ETPNumberPlaceholder = "{number}"
+
+// ETPLinks are the ETP procedure URL templates by ETP name
+// i.e. {"РТС-тендер": "https://www.rts-tender.ru/poisk/search?keywords={number}"}
+type ETPLinks map[string]string
+
+// NewETPLinks returns the procedure URL templates
+// by ETP name with the names trimmed of spaces
+func NewETPLinks(templates map[string]string) ETPLinks {
+	l := make(ETPLinks, len(templates))
+	for name, t := range templates {
+		l[strings.TrimSpace(name)] = t
+	}
+	return l
+}
+
+// EISURL returns the purchase page in EIS.
+// 223-FZ purchases have their own page form
+func (p *PurchaseRecord) EISURL() string {
+	if p.RegistryNumber == "" {
+		return ""
+	}
+	if p.is223() {
+		return eis223URL + p.RegistryNumber
+	}
+	return eis44URL + p.RegistryNumber
+}
+
+// is223 reports if the purchase is held under 223-FZ. The law is
+// taken from the purchase type if it's mentioned there, otherwise
+// it's told by the length of the registry number
+func (p *PurchaseRecord) is223() bool {
+	switch {
+	case strings.Contains(p.PurchaseType, "223"):
+		return true
+	case strings.Contains(p.PurchaseType, "44"):
+		return false
+	}
+	return len(p.RegistryNumber) == registryNumber223Len
+}
+
+// ETPURL returns the procedure page on the ETP
+// or empty string if the ETP has no template in links
+func (p *PurchaseRecord) ETPURL(links ETPLinks) string {
+	t, ok := links[p.EtpSql.String]
+	if !ok || p.RegistryNumber == "" {
+		return ""
+	}
+	return strings.ReplaceAll(t, ETPNumberPlaceholder, p.RegistryNumber)
+}
+
+// link returns markdown link of the form [text](url).
+// Text is returned as is if there is no url
+func link(text, url string) string {
+	if url == "" {
+		return text
+	}
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
@@ -0,0 +1,53 @@
+package botDB
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestPurchaseRecord_links(t *testing.T) {
+	links := NewETPLinks(map[string]string{" РТС-тендер ": "https://www.rts-tender.ru/poisk/search?keywords={number}"})
+
+	tests := []struct {
+		name    string
+		p       PurchaseRecord
+		wantEIS string
+		wantETP string
+	}{
+		{
+			name:    "44",
+			p:       PurchaseRecord{RegistryNumber: "0859200001122007104", PurchaseType: "44-ФЗ", EtpSql: sql.NullString{String: "РТС-тендер", Valid: true}},
+			wantEIS: eis44URL + "0859200001122007104",
+			wantETP: "https://www.rts-tender.ru/poisk/search?keywords=0859200001122007104",
+		},
+		{
+			name:    "223",
+			p:       PurchaseRecord{RegistryNumber: "32211480564", PurchaseType: "223-ФЗ", EtpSql: sql.NullString{String: "unknown", Valid: true}},
+			wantEIS: eis223URL + "32211480564",
+		},
+		{
+			name:    "44_by_number",
+			p:       PurchaseRecord{RegistryNumber: "0859200001122007104", PurchaseType: "ЭА"},
+			wantEIS: eis44URL + "0859200001122007104",
+		},
+		{
+			name:    "223_by_number",
+			p:       PurchaseRecord{RegistryNumber: "32211480564", PurchaseType: "ЗК"},
+			wantEIS: eis223URL + "32211480564",
+		},
+		{
+			name: "no_number",
+			p:    PurchaseRecord{PurchaseType: "44-ФЗ"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.EISURL(); got != tt.wantEIS {
+				t.Errorf("PurchaseRecord.EISURL() = %v, want %v", got, tt.wantEIS)
+			}
+			if got := tt.p.ETPURL(links); got != tt.wantETP {
+				t.Errorf("PurchaseRecord.ETPURL() = %v, want %v", got, tt.wantETP)
+			}
+		})
+	}
+}
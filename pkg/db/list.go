@@ -0,0 +1,191 @@
+package botDB
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// page size of the purchase listing
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// ListFilter is the criteria of the purchase listing.
+// Empty fields are not taken into account, so empty
+// filter lists all of the purchases page by page
+type ListFilter struct {
+	SearchFilter
+	Status string    // exact status name, case is ignored
+	From   time.Time // applications deadline lower bound, inclusive
+	To     time.Time // applications deadline upper bound, exclusive
+	Limit  int       // page size, default is used if not positive
+	Offset int
+}
+
+// pageSize returns the page size within the limits
+func (f *ListFilter) pageSize() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultPageSize
+	case f.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return f.Limit
+}
+
+// whereClause builds where clause of the listing
+// and returns it with the arguments for placeholders
+func (f *ListFilter) whereClause(t table) (string, []interface{}) {
+	conds, args := f.SearchFilter.conditions(t)
+
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conds = append(conds, fmt.Sprintf("lower(%s) = lower($%d)", statusName, len(args)))
+	}
+
+	col := fmt.Sprintf("%s.%s", t.name(), collectingColumn)
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conds = append(conds, fmt.Sprintf("%s >= $%d", col, len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conds = append(conds, fmt.Sprintf("%s < $%d", col, len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "where " + strings.Join(conds, " and "), args
+}
+
+// List returns the page of the purchases matching the filter
+// and the total number of them. Latest purchases come first
+func (m *BotDB) List(f ListFilter) ([]PurchaseRecord, int, error) {
+	var recs []PurchaseRecord
+	var r PurchaseRecord
+	var total int
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	where, args := f.whereClause(t)
+
+	countStmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: where,
+		cols:        []string{"count(*)"},
+	})
+
+	if err := m.db.QueryRow(countStmt, args...).Scan(&total); err != nil {
+		return nil, 0, newBotDbError("BotDB: List Count", countStmt, err, args...)
+	}
+
+	if total <= f.Offset {
+		return nil, total, nil
+	}
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: where,
+		// purchase id keeps the order of the pages stable
+		orderBy: []string{collectingColumn + " desc", t.name() + "." + t.primaryKeyCol(secondaryKey)},
+		limit:   f.pageSize(),
+		offset:  f.Offset,
+		cols:    t.columns(query),
+	})
+
+	rows, err := m.db.Query(stmt, args...)
+	if err != nil {
+		return nil, 0, newBotDbError("BotDB: List", stmt, err, args...)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return nil, 0, newBotDbError("BotDB: List Scan", stmt, err, args...)
+		}
+		r.inLocation(m.loc)
+		r.QueryType = General
+		recs = append(recs, r)
+	}
+
+	return recs, total, rows.Err()
+}
+
+// PurchaseView is the purchase representation for the
+// outer consumers. Unknown values are null
+type PurchaseView struct {
+	PurchaseId           int64      `json:"purchase_id"`
+	RegistryNumber       string     `json:"registry_number"`
+	PurchaseSubject      string     `json:"purchase_subject"`
+	PurchaseSubjectAbbr  string     `json:"purchase_abbr"`
+	PurchaseType         string     `json:"purchase_type"`
+	CollectingDateTime   time.Time  `json:"collecting_datetime"`
+	ApprovalDateTime     *time.Time `json:"approval_datetime"`
+	BiddingDateTime      *time.Time `json:"bidding_datetime"`
+	Region               string     `json:"region"`
+	CustomerType         string     `json:"customer_type"`
+	MaxPrice             float64    `json:"max_price"`
+	ApplicationGuarantee *float64   `json:"application_guarantee"`
+	ContractGuarantee    *float64   `json:"contract_guarantee"`
+	Status               *string    `json:"status"`
+	OurParticipants      *string    `json:"our_participants"`
+	Estimation           *float64   `json:"estimation"`
+	ETP                  *string    `json:"etp"`
+	Winner               *string    `json:"winner"`
+	WinnerPrice          *float64   `json:"winner_price"`
+	Participants         *string    `json:"participants"`
+	EISURL               string     `json:"eis_url,omitempty"`
+}
+
+// View returns the representation of the queried record
+func (p *PurchaseRecord) View() PurchaseView {
+	nullTime := func(v time.Time, ok bool) *time.Time {
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	nullFloat := func(v float64, ok bool) *float64 {
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	nullString := func(v string, ok bool) *string {
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+
+	return PurchaseView{
+		PurchaseId:           p.PurchaseId,
+		RegistryNumber:       p.RegistryNumber,
+		PurchaseSubject:      p.PurchaseSubject,
+		PurchaseSubjectAbbr:  p.PurchaseSubjectAbbr,
+		PurchaseType:         p.PurchaseType,
+		CollectingDateTime:   p.CollectingDateTime,
+		ApprovalDateTime:     nullTime(p.ApprovalDateTimeSql.Time, p.ApprovalDateTimeSql.Valid),
+		BiddingDateTime:      nullTime(p.BiddingDateTimeSql.Time, p.BiddingDateTimeSql.Valid),
+		Region:               p.Region,
+		CustomerType:         p.CustomerType,
+		MaxPrice:             p.MaxPrice,
+		ApplicationGuarantee: nullFloat(p.ApplicationGuaranteeSql.Float64, p.ApplicationGuaranteeSql.Valid),
+		ContractGuarantee:    nullFloat(p.ContractGuaranteeSql.Float64, p.ContractGuaranteeSql.Valid),
+		Status:               nullString(p.StatusSql.String, p.StatusSql.Valid),
+		OurParticipants:      nullString(p.OurParticipantsSql.String, p.OurParticipantsSql.Valid),
+		Estimation:           nullFloat(p.EstimationSql.Float64, p.EstimationSql.Valid),
+		ETP:                  nullString(p.EtpSql.String, p.EtpSql.Valid),
+		Winner:               nullString(p.WinnerSql.String, p.WinnerSql.Valid),
+		WinnerPrice:          nullFloat(p.WinnerPriceSql.Float64, p.WinnerPriceSql.Valid),
+		Participants:         nullString(p.ParticipantsSql.String, p.ParticipantsSql.Valid),
+		EISURL:               p.EISURL(),
+	}
+}
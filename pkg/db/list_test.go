@@ -0,0 +1,83 @@
+package botDB
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListFilter_whereClause(t *testing.T) {
+	from := time.Date(2022, time.July, 1, 0, 0, 0, 0, time.UTC)
+	f := ListFilter{SearchFilter: SearchFilter{Region: "Тверская"}, Status: "Победа", From: from}
+
+	where, args := f.whereClause(purchaseTable{})
+
+	if len(args) != 3 || args[0] != "Тверская" || args[1] != "Победа" || args[2] != from {
+		t.Errorf("ListFilter.whereClause() args = %v", args)
+	}
+	for _, want := range []string{"region_name ilike '%' || $1 || '%'",
+		"lower(status_name) = lower($2)", "purchase_registry.collecting >= $3"} {
+		if !strings.Contains(where, want) {
+			t.Errorf("ListFilter.whereClause() = %s, want it to contain %s", where, want)
+		}
+	}
+	if strings.Contains(where, "collecting <") {
+		t.Errorf("ListFilter.whereClause() = %s, empty criteria must be skipped", where)
+	}
+
+	empty := ListFilter{}
+	if where, args = empty.whereClause(purchaseTable{}); where != "" || len(args) != 0 {
+		t.Errorf("ListFilter.whereClause() = %s %v, want no clause for empty filter", where, args)
+	}
+}
+
+func TestListFilter_pageSize(t *testing.T) {
+	tests := []struct{ limit, want int }{
+		{0, DefaultPageSize},
+		{-1, DefaultPageSize},
+		{10, 10},
+		{MaxPageSize + 1, MaxPageSize},
+	}
+	for _, tt := range tests {
+		f := ListFilter{Limit: tt.limit}
+		if got := f.pageSize(); got != tt.want {
+			t.Errorf("ListFilter.pageSize() limit = %d got = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestPurchaseRecord_View(t *testing.T) {
+	p := PurchaseRecord{
+		PurchaseId:     1,
+		RegistryNumber: "0859200001122007104",
+		// plain fields are stale, nullable ones are queried
+		Status:         "stale",
+		StatusSql:      sql.NullString{String: "Победа", Valid: true},
+		WinnerPriceSql: sql.NullFloat64{Float64: 0, Valid: true},
+		Winner:         "stale",
+	}
+
+	b, err := json.Marshal(p.View())
+	if err != nil {
+		t.Fatalf("PurchaseRecord.View() error = %v", err)
+	}
+
+	var got map[string]any
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("PurchaseRecord.View() error = %v", err)
+	}
+
+	if got["status"] != "Победа" {
+		t.Errorf("PurchaseRecord.View() status = %v, want Победа", got["status"])
+	}
+	if got["winner_price"] != 0.0 {
+		t.Errorf("PurchaseRecord.View() winner_price = %v, want 0", got["winner_price"])
+	}
+	for _, k := range []string{"winner", "bidding_datetime", "etp", "estimation"} {
+		if v, ok := got[k]; !ok || v != nil {
+			t.Errorf("PurchaseRecord.View() %s = %v, want null", k, v)
+		}
+	}
+}
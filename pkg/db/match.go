@@ -0,0 +1,111 @@
+package botDB
+
+import (
+	"sort"
+	"time"
+)
+
+// Matches reports if the queried record is selected by the query
+// option the same way as its where clause does. Days are
+// counted from the day of now in its time zone, the next
+// workday is told by the calendar
+func (q QueryOpt) Matches(p *PurchaseRecord, daysLimit int, now time.Time, cal Calendar) bool {
+	status := p.StatusSql.String
+	auction := p.StatusSql.Valid && (status == statusAuction || status == statusAuction2)
+	goes := p.StatusSql.Valid && (status == statusGo || status == statusEstim)
+	held := p.StatusSql.Valid && (status == statusWin || status == statusLost)
+
+	bidding, hasBidding := p.BiddingDateTimeSql.Time, p.BiddingDateTimeSql.Valid
+	collecting := p.CollectingDateTime
+
+	// day returns midnight n days after today
+	day := func(n int) time.Time { return dayStart(now, n) }
+	// on is t within the day n days after today
+	on := func(t time.Time, n int) bool { return !t.Before(day(n)) && t.Before(day(n+1)) }
+	// between is t within days [from, to] including the midnight of to
+	between := func(t time.Time, from, to int) bool { return !t.Before(day(from)) && !t.After(day(to)) }
+	// upcoming is t within today and the next days of the limit
+	upcoming := func(t time.Time) bool { return !t.Before(day(0)) && t.Before(day(daysLimit+1)) }
+	// future is the next days within the limit if any
+	future := func(t time.Time) bool {
+		if daysLimit > 0 {
+			return between(t, 1, daysLimit)
+		}
+		return !t.Before(day(1))
+	}
+
+	switch q {
+	case Today:
+		return auction && hasBidding && on(bidding, 0) || goes && on(collecting, cal.plusDays(now))
+	case Future:
+		return auction && hasBidding && future(bidding) || goes && future(collecting)
+	case Past:
+		if !held || !hasBidding {
+			return false
+		}
+		if daysLimit > 0 {
+			return between(bidding, -daysLimit, 0)
+		}
+		return bidding.Before(day(0))
+	case TodayAuction:
+		return auction && hasBidding && on(bidding, 0)
+	case TodayGo:
+		return goes && on(collecting, cal.plusDays(now))
+	case FutureAuction:
+		return auction && hasBidding && future(bidding)
+	case FutureGo, FutureMoney:
+		return goes && future(collecting)
+	case UpcomingGo:
+		return goes && upcoming(collecting)
+	case UpcomingAuction:
+		return auction && hasBidding && upcoming(bidding)
+	default:
+		// no where clause
+		return true
+	}
+}
+
+// MoneyTotals groups the queried records by our participant and
+// status and sums the application guarantees the same way
+// as the FutureMoney query does. Groups are sorted
+func MoneyTotals(recs []PurchaseRecord) []PurchaseRecord {
+	type group struct{ participant, status string }
+
+	totals := make(map[group]*PurchaseRecord)
+	var groups []group
+
+	for i := range recs {
+		p := &recs[i]
+		g := group{p.OurParticipantsSql.String, p.StatusSql.String}
+		if !p.OurParticipantsSql.Valid {
+			g.participant = "--не установлен--"
+		}
+
+		t, ok := totals[g]
+		if !ok {
+			t = &PurchaseRecord{}
+			t.OurParticipantsSql.String, t.OurParticipantsSql.Valid = g.participant, true
+			t.StatusSql = p.StatusSql
+			totals[g] = t
+			groups = append(groups, g)
+		}
+		// sum of nulls is null
+		if p.ApplicationGuaranteeSql.Valid {
+			t.ApplicationGuaranteeSql.Float64 += p.ApplicationGuaranteeSql.Float64
+			t.ApplicationGuaranteeSql.Valid = true
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].participant != groups[j].participant {
+			return groups[i].participant < groups[j].participant
+		}
+		return groups[i].status < groups[j].status
+	})
+
+	res := make([]PurchaseRecord, 0, len(groups))
+	for _, g := range groups {
+		res = append(res, *totals[g])
+	}
+	return res
+}
@@ -0,0 +1,150 @@
+package botDB
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryOpt_Matches(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// tuesday, the next workday is tomorrow
+	now := time.Date(2022, time.July, 5, 10, 0, 0, 0, msk)
+	at := func(days, hour int) time.Time { return time.Date(2022, time.July, 5+days, hour, 0, 0, 0, msk) }
+
+	rec := func(status string, collecting, bidding time.Time) *PurchaseRecord {
+		return &PurchaseRecord{
+			CollectingDateTime: collecting,
+			BiddingDateTimeSql: sql.NullTime{Time: bidding, Valid: !bidding.IsZero()},
+			StatusSql:          sql.NullString{String: status, Valid: status != ""},
+		}
+	}
+
+	auctionToday := rec(statusAuction, at(-3, 9), at(0, 15))
+	// 01:00 in Moscow is yesterday in UTC
+	auctionTomorrowNight := rec(statusAuction2, at(-3, 9), at(1, 1))
+	goTomorrow := rec(statusGo, at(1, 9), time.Time{})
+	goNextWeek := rec(statusEstim, at(7, 9), time.Time{})
+	wonYesterday := rec(statusWin, at(-3, 9), at(-1, 12))
+	lostLastMonth := rec(statusLost, at(-40, 9), at(-30, 12))
+	noStatus := rec("", at(0, 9), at(0, 15))
+
+	tests := []struct {
+		name      string
+		q         QueryOpt
+		daysLimit int
+		p         *PurchaseRecord
+		want      bool
+	}{
+		{"today_auction", Today, 0, auctionToday, true},
+		{"today_auction_tomorrow", Today, 0, auctionTomorrowNight, false},
+		{"today_go_next_workday", Today, 0, goTomorrow, true},
+		{"today_no_status", Today, 0, noStatus, false},
+		{"today_auction_opt", TodayAuction, 0, auctionToday, true},
+		{"today_go_opt_auction", TodayGo, 0, auctionToday, false},
+		{"future_auction_today", Future, 0, auctionToday, false},
+		{"future_auction_tomorrow", Future, 0, auctionTomorrowNight, true},
+		{"future_go_limited", Future, 3, goNextWeek, false},
+		{"future_go_unlimited", FutureGo, 0, goNextWeek, true},
+		{"future_money", FutureMoney, 0, goTomorrow, true},
+		{"future_auction_opt_go", FutureAuction, 0, goTomorrow, false},
+		{"past_won", Past, 0, wonYesterday, true},
+		{"past_won_limited", Past, 7, wonYesterday, true},
+		{"past_lost_out_of_limit", Past, 7, lostLastMonth, false},
+		{"past_auction", Past, 0, auctionToday, false},
+		{"upcoming_go_today", UpcomingGo, 0, goTomorrow, false},
+		{"upcoming_go_tomorrow", UpcomingGo, 1, goTomorrow, true},
+		{"upcoming_auction_today", UpcomingAuction, 0, auctionToday, true},
+		{"general", General, 0, noStatus, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.Matches(tt.p, tt.daysLimit, now, Calendar{}); got != tt.want {
+				t.Errorf("QueryOpt.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// tomorrow is the holiday, so the next workday is the day after
+	cal := NewCalendar([]time.Time{at(1, 0)})
+	if Today.Matches(goTomorrow, 0, now, cal) {
+		t.Errorf("QueryOpt.Matches() = true, want applications due on the holiday to be skipped")
+	}
+	if !TodayGo.Matches(rec(statusGo, at(2, 9), time.Time{}), 0, now, cal) {
+		t.Errorf("QueryOpt.Matches() = false, want applications due after the holiday")
+	}
+}
+
+func TestMoneyTotals(t *testing.T) {
+	rec := func(participant, status string, guarantee float64) PurchaseRecord {
+		return PurchaseRecord{
+			OurParticipantsSql:      sql.NullString{String: participant, Valid: participant != ""},
+			StatusSql:               sql.NullString{String: status, Valid: true},
+			ApplicationGuaranteeSql: sql.NullFloat64{Float64: guarantee, Valid: guarantee != 0},
+		}
+	}
+
+	got := MoneyTotals([]PurchaseRecord{
+		rec("ООО Ромашка", statusGo, 1000),
+		rec("", statusGo, 0),
+		rec("ООО Ромашка", statusGo, 500),
+		rec("ООО Ромашка", statusEstim, 200),
+	})
+
+	if len(got) != 3 {
+		t.Fatalf("MoneyTotals() = %v, want 3 groups", got)
+	}
+	if got[0].OurParticipantsSql.String != "--не установлен--" || got[0].ApplicationGuaranteeSql.Valid {
+		t.Errorf("MoneyTotals() = %v, want null sum of the unknown participant", got[0])
+	}
+	if got[1].StatusSql.String != statusGo || got[1].ApplicationGuaranteeSql.Float64 != 1500 {
+		t.Errorf("MoneyTotals() = %v, want 1500", got[1])
+	}
+	if got[2].StatusSql.String != statusEstim || got[2].ApplicationGuaranteeSql.Float64 != 200 {
+		t.Errorf("MoneyTotals() = %v, want 200", got[2])
+	}
+}
+
+func TestQueryOpt_whereClause(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// 01:30 on tuesday in Moscow is still monday in UTC
+	now := time.Date(2022, time.July, 5, 1, 30, 0, 0, msk)
+	day := func(n int) time.Time { return time.Date(2022, time.July, 5+n, 0, 0, 0, 0, msk) }
+
+	tests := []struct {
+		name      string
+		q         QueryOpt
+		daysLimit int
+		cal       Calendar
+		wantArgs  []time.Time
+	}{
+		{"today", Today, 0, Calendar{}, []time.Time{day(0), day(1), day(1), day(2)}},
+		{"today_before_holiday", TodayGo, 0, NewCalendar([]time.Time{day(1)}), []time.Time{day(2), day(3)}},
+		{"future", Future, 0, Calendar{}, []time.Time{day(1), day(1)}},
+		{"future_limited", FutureGo, 3, Calendar{}, []time.Time{day(1), day(3)}},
+		{"past_limited", Past, 7, Calendar{}, []time.Time{day(-7), day(0)}},
+		{"upcoming", UpcomingAuction, 1, Calendar{}, []time.Time{day(0), day(2)}},
+		{"general", General, 0, Calendar{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.q.whereClause(tt.daysLimit, now, tt.cal)
+			if strings.Contains(where, "current_date") {
+				t.Errorf("QueryOpt.whereClause() = %s, want dates as parameters", where)
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("QueryOpt.whereClause() args = %v, want %v", args, tt.wantArgs)
+			}
+			for i := range args {
+				if got, ok := args[i].(time.Time); !ok || !got.Equal(tt.wantArgs[i]) {
+					t.Errorf("QueryOpt.whereClause() arg %d = %v, want %v", i+1, args[i], tt.wantArgs[i])
+				}
+				if !strings.Contains(where, fmt.Sprintf("$%d", i+1)) {
+					t.Errorf("QueryOpt.whereClause() = %s, placeholder $%d is missing", where, i+1)
+				}
+			}
+		})
+	}
+}
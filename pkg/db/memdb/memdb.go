@@ -2,8 +2,12 @@ package memdb
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 	botDB "tbot/pkg/db"
 	"time"
 )
@@ -51,28 +55,639 @@ var MockPurchase = botDB.PurchaseRecord{
 	QueryType:               botDB.General,
 }
 
-// MemDB is for testing purposes
-type MemDB struct{ needErr bool }
+// limits of the listings as in BotDB
+const (
+	searchLimit = 30
+	feedLimit   = 1000
+)
+
+// MemDB is the in-memory database for testing and demonstration
+// purposes. It keeps the upserted purchases and selects them the
+// same way BotDB does, dates are compared in the time zone of the
+// clock. Zero values of the records are kept as nulls. Members
+// and subscriptions are kept too, performance reports are
+// aggregated over the kept records
+type MemDB struct {
+	mu        sync.Mutex
+	needErr   bool
+	clock     botDB.Clock
+	cal       botDB.Calendar
+	recs      map[string]botDB.PurchaseRecord // by registry number
+	nextId    int64
+	history   []botDB.Change
+	overrides map[string]map[botDB.Field]string // edited in the bot by registry number
+	members   map[int64]botDB.Member            // by chat
+	subs      map[int64]botDB.Subscription      // by id
+	nextSubId int64
+}
 
+// New returns the database holding the MockPurchase.
+// Every method fails if needErr is set
 func New(needErr bool) *MemDB {
+	d := NewWithClock(botDB.SystemClock{}, botDB.Calendar{})
+	d.needErr = needErr
+	d.recs[MockPurchase.RegistryNumber] = MockPurchase
+	d.nextId = MockPurchase.PurchaseId + 1
+	return d
+}
+
+// NewWithClock returns the empty database which takes
+// the current time from the clock and the workdays from the calendar
+func NewWithClock(clock botDB.Clock, cal botDB.Calendar) *MemDB {
 	return &MemDB{
-		needErr: needErr,
+		clock:     clock,
+		cal:       cal,
+		recs:      make(map[string]botDB.PurchaseRecord),
+		nextId:    1,
+		overrides: make(map[string]map[botDB.Field]string),
+		members:   make(map[int64]botDB.Member),
+		subs:      make(map[int64]botDB.Subscription),
+		nextSubId: 1,
+	}
+}
+
+// stored returns the record as it is queried from the database
+func stored(p botDB.PurchaseRecord) botDB.PurchaseRecord {
+	p.ApprovalDateTimeSql = sql.NullTime{Time: p.ApprovalDateTime, Valid: !p.ApprovalDateTime.IsZero()}
+	p.BiddingDateTimeSql = sql.NullTime{Time: p.BiddingDateTime, Valid: !p.BiddingDateTime.IsZero()}
+	p.ApplicationGuaranteeSql = sql.NullFloat64{Float64: p.ApplicationGuarantee, Valid: p.ApplicationGuarantee != 0}
+	p.ContractGuaranteeSql = sql.NullFloat64{Float64: p.ContractGuarantee, Valid: p.ContractGuarantee != 0}
+	p.StatusSql = sql.NullString{String: p.Status, Valid: p.Status != ""}
+	p.OurParticipantsSql = sql.NullString{String: p.OurParticipants, Valid: p.OurParticipants != ""}
+	p.EstimationSql = sql.NullFloat64{Float64: p.Estimation, Valid: p.Estimation != 0}
+	p.EtpSql = sql.NullString{String: p.ETP, Valid: p.ETP != ""}
+	p.WinnerSql = sql.NullString{String: p.Winner, Valid: p.Winner != ""}
+	p.WinnerPriceSql = sql.NullFloat64{Float64: p.WinnerPrice, Valid: p.WinnerPrice != 0}
+	p.ParticipantsSql = sql.NullString{String: p.Participants, Valid: p.Participants != ""}
+	return p
+}
+
+// day returns midnight n days after today
+func (d *MemDB) day(n int) time.Time {
+	now := d.clock.Now()
+	y, m, dd := now.Date()
+	return time.Date(y, m, dd+n, 0, 0, 0, 0, now.Location())
+}
+
+// selected returns the records passing the filter
+func (d *MemDB) selected(match func(p *botDB.PurchaseRecord) bool) []botDB.PurchaseRecord {
+	var recs []botDB.PurchaseRecord
+	for _, p := range d.recs {
+		if match(&p) {
+			recs = append(recs, p)
+		}
+	}
+	return recs
+}
+
+// upsert validates, inserts or updates the records
+// keeping the values edited in the bot
+func (d *MemDB) upsert(recs []botDB.PurchaseRecord, mode botDB.UpsertMode) (botDB.UpdateResult, error) {
+	var res botDB.UpdateResult
+
+	n := len(recs)
+	recs, res.Rejected = botDB.ValidateAll(recs)
+	if len(res.Rejected) > 0 && (mode == botDB.Strict || (n > 0 && len(recs) == 0)) {
+		return res, botDB.ErrInvalidRecords
+	}
+
+	now := d.clock.Now()
+
+	for i := range recs {
+		p := &recs[i]
+
+		// values edited in the bot take precedence
+		for f, kept := range d.overrides[p.RegistryNumber] {
+			c, err := f.Apply(p, kept)
+			if err != nil {
+				return res, err
+			}
+			if c.Old == c.New {
+				// the sheet is up to date with the bot
+				delete(d.overrides[p.RegistryNumber], f)
+				continue
+			}
+			res.Conflicts = append(res.Conflicts, botDB.Conflict{RegistryNumber: p.RegistryNumber,
+				Field: c.Field, Incoming: c.Old, Kept: c.New})
+		}
+
+		if old, ok := d.recs[p.RegistryNumber]; ok {
+			p.PurchaseId = old.PurchaseId
+			res.Changes = append(res.Changes, botDB.Diff(&old, p, now)...)
+		} else {
+			p.PurchaseId = d.nextId
+			d.nextId++
+		}
+		p.QueryType = 0
+		d.recs[p.RegistryNumber] = stored(*p)
+	}
+
+	d.history = append(d.history, res.Changes...)
+
+	return res, nil
+}
+
+func (d *MemDB) Upsert(rc io.ReadCloser, mode botDB.UpsertMode) (botDB.UpdateResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return botDB.UpdateResult{}, mockErr
+	}
+
+	var recs []botDB.PurchaseRecord
+	if err := json.NewDecoder(rc).Decode(&recs); err != nil {
+		return botDB.UpdateResult{}, err
+	}
+	if len(recs) == 0 {
+		return botDB.UpdateResult{}, fmt.Errorf("the length of incoming records is zero")
+	}
+
+	return d.upsert(recs, mode)
+}
+
+func (d *MemDB) Stale(rc io.ReadCloser) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	var digests []botDB.RecordDigest
+	if err := json.NewDecoder(rc).Decode(&digests); err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, dg := range digests {
+		if p, ok := d.recs[dg.RegistryNumber]; !ok || p.Hash != dg.Hash {
+			stale = append(stale, dg.RegistryNumber)
+		}
+	}
+	return stale, nil
+}
+
+func (d *MemDB) ApplyDelta(rc io.ReadCloser, mode botDB.UpsertMode) (botDB.UpdateResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return botDB.UpdateResult{}, mockErr
+	}
+
+	var delta botDB.Delta
+	if err := json.NewDecoder(rc).Decode(&delta); err != nil {
+		return botDB.UpdateResult{}, err
+	}
+
+	res, err := d.upsert(delta.Records, mode)
+	if err != nil {
+		return res, err
+	}
+
+	for _, num := range delta.Tombstones {
+		delete(d.recs, num)
+		delete(d.overrides, num)
+	}
+
+	return res, nil
+}
+
+// Delete removes the records which bidding date (or collecting
+// date if there is no bidding) is older than the retention window.
+// Records are not archived
+func (d *MemDB) Delete(rp botDB.RetentionPolicy) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return 0, mockErr
 	}
+	if rp.Days <= 0 {
+		return 0, fmt.Errorf("invalid retention window %d days", rp.Days)
+	}
+
+	expired := d.day(-rp.Days)
+
+	var n int64
+	for num, p := range d.recs {
+		t := p.CollectingDateTime
+		if p.BiddingDateTimeSql.Valid {
+			t = p.BiddingDateTimeSql.Time
+		}
+		if t.Before(expired) {
+			delete(d.recs, num)
+			delete(d.overrides, num)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// byBidding sorts the records by bidding date, nulls are the last
+func byBidding(recs []botDB.PurchaseRecord) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		a, b := recs[i].BiddingDateTimeSql, recs[j].BiddingDateTimeSql
+		if !a.Valid || !b.Valid {
+			return a.Valid && !b.Valid
+		}
+		return a.Time.Before(b.Time)
+	})
 }
 
-func (d MemDB) Upsert(_ io.ReadCloser) error {
+// byCollecting sorts the records by collecting date
+func byCollecting(recs []botDB.PurchaseRecord, desc bool) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		if recs[i].CollectingDateTime.Equal(recs[j].CollectingDateTime) {
+			return recs[i].PurchaseId < recs[j].PurchaseId
+		}
+		return recs[i].CollectingDateTime.Before(recs[j].CollectingDateTime) != desc
+	})
+}
+
+func (d *MemDB) Query(daysLimit int, qopts ...botDB.QueryOpt) ([]botDB.PurchaseRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	now := d.clock.Now()
+
+	var res []botDB.PurchaseRecord
+	for _, q := range qopts {
+		recs := d.selected(func(p *botDB.PurchaseRecord) bool { return q.Matches(p, daysLimit, now, d.cal) })
+
+		if q == botDB.FutureMoney {
+			recs = botDB.MoneyTotals(recs)
+		} else {
+			byCollecting(recs, false) // the order of the records with the same bidding
+			byBidding(recs)
+		}
+
+		for i := range recs {
+			recs[i].QueryType = q
+		}
+		res = append(res, recs...)
+	}
+	return res, nil
+}
+
+func (d *MemDB) QueryRow(id int64) (botDB.PurchaseRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.queryRow(id)
+}
+
+// queryRow returns the record by id,
+// the caller holds the lock
+func (d *MemDB) queryRow(id int64) (botDB.PurchaseRecord, error) {
+	if d.needErr {
+		return botDB.PurchaseRecord{}, mockErr
+	}
+	if id == 0 {
+		return botDB.PurchaseRecord{}, fmt.Errorf("invalid identifier %d", id)
+	}
+
+	for _, p := range d.recs {
+		if p.PurchaseId == id {
+			p.QueryType = botDB.General
+			return p, nil
+		}
+	}
+	return botDB.PurchaseRecord{}, botDB.ErrNoRows
+}
+
+func (d *MemDB) History(id int64) ([]botDB.Change, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	var res []botDB.Change
+	for _, c := range d.history {
+		if c.PurchaseId == id {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (d *MemDB) Report(from, to time.Time) (botDB.Report, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return botDB.Report{}, mockErr
+	}
+
+	recs := d.selected(func(*botDB.PurchaseRecord) bool { return true })
+	return botDB.ReportOf(recs, from, to), nil
+}
+
+// containsFold is the 'ilike' substring match
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// matchSearch reports if the record passes the search filter.
+// Text matches all of the words of the subject
+// or the suffix of the registry number
+func matchSearch(f *botDB.SearchFilter, p *botDB.PurchaseRecord) bool {
+	if f.Text != "" && !strings.HasSuffix(p.RegistryNumber, f.Text) {
+		for _, w := range strings.Fields(f.Text) {
+			if !containsFold(p.PurchaseSubject, w) {
+				return false
+			}
+		}
+	}
+	return containsFold(p.Region, f.Region) &&
+		containsFold(p.EtpSql.String, f.ETP) &&
+		containsFold(p.CustomerType, f.CustomerType) &&
+		containsFold(p.OurParticipantsSql.String, f.Participant) &&
+		// null doesn't match any pattern
+		(f.ETP == "" || p.EtpSql.Valid) && (f.Participant == "" || p.OurParticipantsSql.Valid)
+}
+
+func (d *MemDB) Search(f botDB.SearchFilter) ([]botDB.PurchaseRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+	if f.IsEmpty() {
+		return nil, botDB.ErrEmptySearch
+	}
+
+	recs := d.selected(func(p *botDB.PurchaseRecord) bool { return matchSearch(&f, p) })
+	byCollecting(recs, true)
+	if len(recs) > searchLimit {
+		recs = recs[:searchLimit]
+	}
+	for i := range recs {
+		recs[i].QueryType = botDB.Found
+	}
+	return recs, nil
+}
+
+func (d *MemDB) QueryNumber(num string) ([]botDB.PurchaseRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+	if num == "" {
+		return nil, botDB.ErrEmptySearch
+	}
+
+	recs := d.selected(func(p *botDB.PurchaseRecord) bool { return strings.HasSuffix(p.RegistryNumber, num) })
+	byCollecting(recs, true)
+	// exact match comes first
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].RegistryNumber == num && recs[j].RegistryNumber != num
+	})
+	if len(recs) > searchLimit {
+		recs = recs[:searchLimit]
+	}
+	for i := range recs {
+		recs[i].QueryType = botDB.General
+	}
+	return recs, nil
+}
+
+func (d *MemDB) Subscribe(s botDB.Subscription) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return 0, mockErr
+	}
+
+	s.Id = d.nextSubId
+	s.CreatedAt = d.clock.Now()
+	d.subs[s.Id] = s
+	d.nextSubId++
+	return s.Id, nil
+}
+
+func (d *MemDB) Unsubscribe(chat, id int64) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return 0, mockErr
+	}
+	return d.unsubscribe(chat, id), nil
+}
+
+// unsubscribe removes the subscription of the chat or all
+// of them if id is zero and returns how many were removed
+func (d *MemDB) unsubscribe(chat, id int64) int64 {
+	var n int64
+	for sid, s := range d.subs {
+		if s.Chat == chat && (id == 0 || sid == id) {
+			delete(d.subs, sid)
+			n++
+		}
+	}
+	return n
+}
+
+func (d *MemDB) Subscriptions(chat int64) ([]botDB.Subscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	var res []botDB.Subscription
+	for _, s := range d.subs {
+		if chat == 0 || s.Chat == chat {
+			res = append(res, s)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res, nil
+}
+
+func (d *MemDB) Role(chat int64) (botDB.Role, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return botDB.NoRole, mockErr
+	}
+	return d.members[chat].Role, nil
+}
+
+func (d *MemDB) Grant(chat int64, r botDB.Role, by int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if d.needErr {
 		return mockErr
 	}
+
+	d.members[chat] = botDB.Member{Chat: chat, Role: r, GrantedBy: by, GrantedAt: d.clock.Now()}
 	return nil
 }
 
-func (d MemDB) Delete(_ io.ReadCloser) error { return nil }
+func (d *MemDB) Enroll(chat int64, r botDB.Role) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-func (d MemDB) Query(_ int, _ ...botDB.QueryOpt) ([]botDB.PurchaseRecord, error) {
-	return nil, nil
+	if d.needErr {
+		return mockErr
+	}
+
+	// enrolled member keeps the role it already has
+	if _, ok := d.members[chat]; !ok {
+		d.members[chat] = botDB.Member{Chat: chat, Role: r, GrantedAt: d.clock.Now()}
+	}
+	return nil
+}
+
+func (d *MemDB) Revoke(chat int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return false, mockErr
+	}
+
+	_, ok := d.members[chat]
+	delete(d.members, chat)
+	d.unsubscribe(chat, 0)
+	return ok, nil
+}
+
+func (d *MemDB) Members(r botDB.Role) ([]botDB.Member, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	var res []botDB.Member
+	for _, m := range d.members {
+		if r == botDB.NoRole || m.Role == r {
+			res = append(res, m)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Chat < res[j].Chat })
+	return res, nil
+}
+
+func (d *MemDB) Edit(id int64, f botDB.Field, value string, _ int64) (botDB.Change, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, err := d.queryRow(id)
+	if err != nil {
+		return botDB.Change{}, err
+	}
+
+	c, err := f.Apply(&p, value)
+	if err != nil {
+		return c, err
+	}
+	c.ChangedAt = d.clock.Now()
+
+	p.QueryType = 0
+	d.recs[p.RegistryNumber] = stored(p)
+	if d.overrides[p.RegistryNumber] == nil {
+		d.overrides[p.RegistryNumber] = make(map[botDB.Field]string)
+	}
+	d.overrides[p.RegistryNumber][f] = c.New
+	if c.Old != c.New {
+		d.history = append(d.history, c)
+	}
+
+	return c, nil
 }
 
-func (d MemDB) QueryRow(_ int64) (botDB.PurchaseRecord, error) {
-	return botDB.PurchaseRecord{}, nil
+func (d *MemDB) Release(id int64, f botDB.Field) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, err := d.queryRow(id)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := d.overrides[p.RegistryNumber][f]
+	delete(d.overrides[p.RegistryNumber], f)
+	return ok, nil
+}
+
+func (d *MemDB) Feed(f botDB.FeedFilter) ([]botDB.PurchaseRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, mockErr
+	}
+
+	recs := d.selected(func(p *botDB.PurchaseRecord) bool {
+		return (p.BiddingDateTimeSql.Valid && !p.BiddingDateTimeSql.Time.Before(f.Since) ||
+			!p.CollectingDateTime.Before(f.Since)) &&
+			containsFold(p.Region, f.Region) &&
+			containsFold(p.OurParticipantsSql.String, f.Participant) &&
+			(f.Participant == "" || p.OurParticipantsSql.Valid)
+	})
+	byCollecting(recs, false)
+	if len(recs) > feedLimit {
+		recs = recs[:feedLimit]
+	}
+	for i := range recs {
+		recs[i].QueryType = botDB.General
+	}
+	return recs, nil
+}
+
+func (d *MemDB) List(f botDB.ListFilter) ([]botDB.PurchaseRecord, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.needErr {
+		return nil, 0, mockErr
+	}
+
+	recs := d.selected(func(p *botDB.PurchaseRecord) bool {
+		return matchSearch(&f.SearchFilter, p) &&
+			(f.Status == "" || p.StatusSql.Valid && strings.EqualFold(p.StatusSql.String, f.Status)) &&
+			(f.From.IsZero() || !p.CollectingDateTime.Before(f.From)) &&
+			(f.To.IsZero() || p.CollectingDateTime.Before(f.To))
+	})
+	byCollecting(recs, true)
+
+	total := len(recs)
+	if f.Offset >= total {
+		return nil, total, nil
+	}
+	recs = recs[f.Offset:]
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = botDB.DefaultPageSize
+	}
+	if limit > botDB.MaxPageSize {
+		limit = botDB.MaxPageSize
+	}
+	if len(recs) > limit {
+		recs = recs[:limit]
+	}
+	for i := range recs {
+		recs[i].QueryType = botDB.General
+	}
+	return recs, total, nil
 }
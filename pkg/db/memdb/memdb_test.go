@@ -0,0 +1,176 @@
+package memdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	botDB "tbot/pkg/db"
+	"testing"
+	"time"
+)
+
+// tuesday in Moscow
+var now = time.Date(2022, time.July, 5, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+// at returns the time of the hour days after now
+func at(days, hour int) time.Time {
+	return time.Date(2022, time.July, 5+days, hour, 0, 0, 0, now.Location())
+}
+
+// record returns the valid incoming record
+func record(num, status string, collecting, bidding time.Time) botDB.PurchaseRecord {
+	return botDB.PurchaseRecord{
+		RegistryNumber:      num,
+		PurchaseSubject:     "поставка бумаги",
+		PurchaseSubjectAbbr: "ПБ",
+		PurchaseType:        "ЭА",
+		Region:              "Тверская",
+		CustomerType:        "ГУ",
+		Status:              status,
+		CollectingDateTime:  collecting,
+		BiddingDateTime:     bidding,
+		MaxPrice:            100000,
+	}
+}
+
+// newDB returns the database holding the records
+func newDB(t *testing.T, recs ...botDB.PurchaseRecord) *MemDB {
+	d := NewWithClock(botDB.ClockFunc(func() time.Time { return now }), botDB.Calendar{})
+
+	b, err := json.Marshal(recs)
+	if err != nil {
+		t.Fatalf("json.Marshal() error=%v", err)
+	}
+	if _, err = d.Upsert(io.NopCloser(bytes.NewReader(b)), botDB.Strict); err != nil {
+		t.Fatalf("MemDB.Upsert() error=%v", err)
+	}
+	return d
+}
+
+func TestMemDB_Query(t *testing.T) {
+	d := newDB(t,
+		record("1", "допущены", at(-3, 9), at(2, 11)),
+		record("2", "заявлены", at(-3, 9), at(1, 15)),
+		record("3", "идем", at(3, 9), time.Time{}),
+		record("4", "идем", at(1, 9), time.Time{}),
+		record("5", "выиграли", at(-3, 9), at(-1, 12)),
+	)
+
+	recs, err := d.Query(0, botDB.Future)
+	if err != nil {
+		t.Fatalf("MemDB.Query() error=%v", err)
+	}
+
+	// ordered by bidding with nulls last as in BotDB
+	want := []string{"2", "1", "4", "3"}
+	if len(recs) != len(want) {
+		t.Fatalf("MemDB.Query() got %d records, want %d", len(recs), len(want))
+	}
+	for i := range want {
+		if recs[i].RegistryNumber != want[i] {
+			t.Errorf("MemDB.Query() record %d = %s, want %s", i, recs[i].RegistryNumber, want[i])
+		}
+		if recs[i].QueryType != botDB.Future {
+			t.Errorf("MemDB.Query() query type = %v, want %v", recs[i].QueryType, botDB.Future)
+		}
+	}
+}
+
+func TestMemDB_Query_money(t *testing.T) {
+	rec := func(num, status, participant string, guarantee float64, collecting time.Time) botDB.PurchaseRecord {
+		p := record(num, status, collecting, time.Time{})
+		p.OurParticipants = participant
+		p.ApplicationGuarantee = guarantee
+		return p
+	}
+	d := newDB(t,
+		rec("1", "идем", "ООО Ромашка", 1000, at(1, 9)),
+		rec("2", "идем", "ООО Ромашка", 500, at(2, 9)),
+		rec("3", "расчет", "", 0, at(2, 9)),
+		rec("4", "идем", "ООО Ромашка", 700, at(-1, 9)), // past
+	)
+
+	recs, err := d.Query(0, botDB.FutureMoney)
+	if err != nil {
+		t.Fatalf("MemDB.Query() error=%v", err)
+	}
+
+	// grouped by our participant and status as in BotDB
+	if len(recs) != 2 {
+		t.Fatalf("MemDB.Query() = %v, want 2 groups", recs)
+	}
+	if recs[0].OurParticipantsSql.String != "--не установлен--" || recs[0].ApplicationGuaranteeSql.Valid {
+		t.Errorf("MemDB.Query() = %v, want null sum of the unknown participant", recs[0])
+	}
+	if recs[1].OurParticipantsSql.String != "ООО Ромашка" || recs[1].StatusSql.String != "идем" ||
+		recs[1].ApplicationGuaranteeSql.Float64 != 1500 {
+		t.Errorf("MemDB.Query() = %v, want 1500 of ООО Ромашка", recs[1])
+	}
+	if recs[1].QueryType != botDB.FutureMoney {
+		t.Errorf("MemDB.Query() query type = %v, want %v", recs[1].QueryType, botDB.FutureMoney)
+	}
+}
+
+func TestMemDB_Report(t *testing.T) {
+	won := record("1", "выиграли", at(-5, 9), at(-1, 12))
+	won.WinnerPrice = 80000
+	applied := record("2", "допущены", at(-2, 9), at(1, 10))
+	applied.ApplicationGuarantee = 1000
+	d := newDB(t, won, applied, record("3", "не выиграли", at(-40, 9), at(-30, 12)))
+
+	r, err := d.Report(at(-10, 0), at(10, 0))
+	if err != nil {
+		t.Fatalf("MemDB.Report() error=%v", err)
+	}
+
+	want := botDB.ReportRow{Applications: 2, Auctions: 1, Wins: 1,
+		MaxPrice: 100000, WinnerPrice: 80000, Guarantee: 1000}
+	if r.Total != want {
+		t.Errorf("MemDB.Report() total = %+v, want %+v", r.Total, want)
+	}
+	if len(r.ByRegion) != 1 || r.ByRegion[0].Name != "Тверская" {
+		t.Errorf("MemDB.Report() by region = %+v, want Тверская", r.ByRegion)
+	}
+	if len(r.ByParticipant) != 1 || r.ByParticipant[0].Name != "--не установлен--" {
+		t.Errorf("MemDB.Report() by participant = %+v, want the unknown participant", r.ByParticipant)
+	}
+}
+
+func TestMemDB_members(t *testing.T) {
+	d := New(false)
+
+	if r, _ := d.Role(2); r != botDB.NoRole {
+		t.Errorf("MemDB.Role() = %v, want no role of the unknown chat", r)
+	}
+
+	_ = d.Enroll(2, botDB.Viewer)
+	_ = d.Grant(3, botDB.Admin, 2)
+	// enrolled member keeps the granted role
+	_ = d.Enroll(3, botDB.Viewer)
+
+	if r, _ := d.Role(3); r != botDB.Admin {
+		t.Errorf("MemDB.Role() = %v, want %v", r, botDB.Admin)
+	}
+	if ms, _ := d.Members(botDB.NoRole); len(ms) != 2 || ms[0].Chat != 2 || ms[1].GrantedBy != 2 {
+		t.Errorf("MemDB.Members() = %+v, want chats 2 and 3", ms)
+	}
+	if ms, _ := d.Members(botDB.Viewer); len(ms) != 1 || ms[0].Chat != 2 {
+		t.Errorf("MemDB.Members() = %+v, want viewer 2", ms)
+	}
+
+	// revoked chat loses its subscriptions too
+	_, _ = d.Subscribe(botDB.Subscription{Chat: 2, Region: "Тверская"})
+	id, _ := d.Subscribe(botDB.Subscription{Chat: 3})
+	if ok, _ := d.Revoke(2); !ok {
+		t.Errorf("MemDB.Revoke() = false, want the member to be revoked")
+	}
+	if ok, _ := d.Revoke(2); ok {
+		t.Errorf("MemDB.Revoke() = true, want false for the unknown chat")
+	}
+	if subs, _ := d.Subscriptions(0); len(subs) != 1 || subs[0].Id != id {
+		t.Errorf("MemDB.Subscriptions() = %+v, want subscription %d of chat 3", subs, id)
+	}
+	if n, _ := d.Unsubscribe(3, 0); n != 1 {
+		t.Errorf("MemDB.Unsubscribe() = %d, want 1", n)
+	}
+}
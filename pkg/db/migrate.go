@@ -0,0 +1,276 @@
+package botDB
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations are the versioned schema changes.
+// Files are named as 0001_name.up.sql and 0001_name.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrations directory in the embedded files
+const migrationsDir = "migrations"
+
+// migration errors
+var (
+	// ErrUnknownMigration is returned when the applied migration
+	// is unknown to the application, so it can't be rolled back
+	ErrUnknownMigration = errors.New("applied migration is unknown")
+	// ErrIrreversibleMigration is returned when
+	// the migration has no down statement
+	ErrIrreversibleMigration = errors.New("migration can't be rolled back")
+)
+
+// Migration is the versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // empty if the migration can't be rolled back
+}
+
+// MigrationStatus is the migration and whether it is applied
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// loadMigrations reads the migrations from the directory
+// of the file system sorted by the version
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		// 0001_name.up.sql
+		base := strings.TrimSuffix(name, ".sql")
+		ext := path.Ext(base)
+		base = strings.TrimSuffix(base, ext)
+		num, title, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 || (ext != ".up" && ext != ".down") {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %d has different names %q and %q", version, m.Name, title)
+		}
+		if ext == ".up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	ms := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return ms, nil
+}
+
+// Migrator applies and rolls back the schema migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator is the constructor of the migrator
+// of the embedded migrations. Expects established
+// database connection
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	ms, err := loadMigrations(migrationFiles, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: ms}, nil
+}
+
+// locked runs f on the dedicated connection holding the
+// advisory lock. Migrations table is created if it's absent
+func (mg *Migrator) locked(f func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := mg.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// session lock is held until unlocked or the connection is closed
+	if _, err = conn.ExecContext(ctx, migrationsLockStatement, migrationsLockKey); err != nil {
+		return newBotDbError("Migrator: Lock", migrationsLockStatement, err, migrationsLockKey)
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, migrationsUnlockStatement, migrationsLockKey) }()
+
+	if _, err = conn.ExecContext(ctx, migrationsTableStatement); err != nil {
+		return newBotDbError("Migrator: Create Table", migrationsTableStatement, err)
+	}
+
+	return f(conn)
+}
+
+// applied returns the versions of the applied migrations
+func (mg *Migrator) applied(conn *sql.Conn) (map[int]bool, []int, error) {
+	rows, err := conn.QueryContext(context.Background(), migrationsVersionsStatement)
+	if err != nil {
+		return nil, nil, newBotDbError("Migrator: Versions", migrationsVersionsStatement, err)
+	}
+	defer rows.Close()
+
+	set := make(map[int]bool)
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err = rows.Scan(&v); err != nil {
+			return nil, nil, newBotDbError("Migrator: Versions Scan", migrationsVersionsStatement, err)
+		}
+		set[v] = true
+		versions = append(versions, v)
+	}
+
+	return set, versions, rows.Err()
+}
+
+// run executes the migration statement and records
+// the version in the same transaction
+func (mg *Migrator) run(conn *sql.Conn, m Migration, up bool) error {
+	ctx := context.Background()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, record, args := m.Up, migrationsInsertStatement, []any{m.Version, m.Name}
+	if !up {
+		stmt, record, args = m.Down, migrationsDeleteStatement, []any{m.Version}
+	}
+
+	if _, err = tx.ExecContext(ctx, stmt); err != nil {
+		return newBotDbError(fmt.Sprintf("Migrator: %d_%s", m.Version, m.Name), "", err)
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return newBotDbError("Migrator: Record", record, err, args...)
+	}
+
+	return tx.Commit()
+}
+
+// Up applies all of the pending migrations in the version
+// order and returns the applied ones. Each migration is
+// applied in its own transaction, so the failed one
+// doesn't roll back the preceding ones
+func (mg *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+
+	err := mg.locked(func(conn *sql.Conn) error {
+		applied, _, err := mg.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range mg.migrations {
+			if applied[m.Version] {
+				continue
+			}
+			if err = mg.run(conn, m, true); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the steps of the latest applied
+// migrations and returns the rolled back ones
+func (mg *Migrator) Down(steps int) ([]Migration, error) {
+	var done []Migration
+
+	known := make(map[int]Migration, len(mg.migrations))
+	for _, m := range mg.migrations {
+		known[m.Version] = m
+	}
+
+	err := mg.locked(func(conn *sql.Conn) error {
+		_, versions, err := mg.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(versions) - 1; i >= 0 && len(done) < steps; i-- {
+			m, ok := known[versions[i]]
+			if !ok {
+				return fmt.Errorf("%w: version %d", ErrUnknownMigration, versions[i])
+			}
+			if m.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrIrreversibleMigration, m.Version, m.Name)
+			}
+			if err = mg.run(conn, m, false); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status returns the known migrations in the version
+// order and whether each of them is applied
+func (mg *Migrator) Status() ([]MigrationStatus, error) {
+	var st []MigrationStatus
+
+	err := mg.locked(func(conn *sql.Conn) error {
+		applied, _, err := mg.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range mg.migrations {
+			st = append(st, MigrationStatus{Migration: m, Applied: applied[m.Version]})
+		}
+		return nil
+	})
+
+	return st, err
+}
@@ -0,0 +1,73 @@
+package botDB
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+
+	t.Run("embedded", func(t *testing.T) {
+		ms, err := loadMigrations(migrationFiles, migrationsDir)
+		if err != nil {
+			t.Fatalf("loadMigrations() error = %v", err)
+		}
+		if len(ms) == 0 {
+			t.Fatalf("loadMigrations() no migrations")
+		}
+		for i, m := range ms {
+			if m.Version != i+1 {
+				t.Errorf("loadMigrations() version = %d, want %d", m.Version, i+1)
+			}
+			if m.Down == "" {
+				t.Errorf("loadMigrations() %d_%s has no down statement", m.Version, m.Name)
+			}
+		}
+		// every table of the application is created by migrations
+		var up strings.Builder
+		for _, m := range ms {
+			up.WriteString(m.Up)
+		}
+		for _, name := range []string{purchTableName, custTableName, purchTypeTableName, regionTableName,
+			etpTableName, statusTableName, purchaseStringCodeTableName, historyTableName, sentTableName,
+			subTableName, memberTableName, overrideTableName, watcherTableName} {
+			if !strings.Contains(up.String(), "CREATE TABLE IF NOT EXISTS "+name+" (") {
+				t.Errorf("loadMigrations() table %s is not created", name)
+			}
+		}
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0010_second.up.sql":  {Data: []byte("select 2;")},
+			"m/0002_first.up.sql":   {Data: []byte("select 1;")},
+			"m/0002_first.down.sql": {Data: []byte("select -1;")},
+			"m/README":              {Data: []byte("not a migration")},
+		}
+		ms, err := loadMigrations(fsys, "m")
+		if err != nil {
+			t.Fatalf("loadMigrations() error = %v", err)
+		}
+		if len(ms) != 2 || ms[0].Version != 2 || ms[1].Version != 10 {
+			t.Fatalf("loadMigrations() = %v, want versions 2 and 10", ms)
+		}
+		if ms[0].Name != "first" || ms[0].Down != "select -1;" || ms[1].Down != "" {
+			t.Errorf("loadMigrations() = %v", ms)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []fstest.MapFS{
+			{"m/first.up.sql": {}},
+			{"m/0001_first.sideways.sql": {}},
+			{"m/0001_first.down.sql": {}},
+			{"m/0001_first.up.sql": {}, "m/0001_other.down.sql": {}},
+		}
+		for _, fsys := range tests {
+			if _, err := loadMigrations(fsys, "m"); err == nil {
+				t.Errorf("loadMigrations() %v expected error", fsys)
+			}
+		}
+	})
+}
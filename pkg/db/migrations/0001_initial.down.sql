@@ -0,0 +1 @@
+DROP TABLE IF EXISTS purchase_registry, customer_types, purchase_types, regions, etp, statuses, purchase_string_codes;
@@ -1,6 +1,3 @@
-
-DROP TABLE IF NOT EXISTS customer_types, purchase_types, regions, etp, statuses, purchase_string_codes, purchase_registry;
-
 CREATE TABLE IF NOT EXISTS customer_types (
 	customer_type_id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
 	customer_type_name varchar(10) UNIQUE NOT NULL
@@ -58,4 +55,4 @@ CREATE TABLE IF NOT EXISTS purchase_registry (
 	FOREIGN KEY (etp_id) REFERENCES etp (etp_id),
 	FOREIGN KEY (status_id) REFERENCES statuses (status_id),
 	FOREIGN KEY (purchase_string_code) REFERENCES purchase_string_codes (purchase_string_code)
-);
\ No newline at end of file
+);
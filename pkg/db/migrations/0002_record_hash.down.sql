@@ -0,0 +1 @@
+ALTER TABLE purchase_registry DROP COLUMN IF EXISTS record_hash;
@@ -0,0 +1,2 @@
+-- client side hash of the record state used by the delta sync
+ALTER TABLE purchase_registry ADD COLUMN IF NOT EXISTS record_hash varchar(64);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS purchase_history (
+	history_id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	registry_number varchar (20) NOT NULL,
+	column_name varchar(30) NOT NULL,
+	old_value text,
+	new_value text,
+	changed_at timestamptz NOT NULL DEFAULT now(),
+	FOREIGN KEY (registry_number) REFERENCES purchase_registry (registry_number) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS purchase_history_registry_number_idx ON purchase_history (registry_number, changed_at);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS sent_notifications (
+	purchase_id bigint NOT NULL,
+	event varchar(20) NOT NULL,
+	lead_seconds bigint NOT NULL,
+	event_time timestamptz NOT NULL,
+	sent_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (purchase_id, event, lead_seconds, event_time)
+);
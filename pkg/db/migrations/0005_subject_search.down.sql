@@ -0,0 +1 @@
+DROP INDEX IF EXISTS purchase_registry_subject_fts_idx;
@@ -0,0 +1,2 @@
+-- full-text search over the purchase subject, the search query must use the same expression
+CREATE INDEX IF NOT EXISTS purchase_registry_subject_fts_idx ON purchase_registry USING gin (to_tsvector('russian', purchase_subject));
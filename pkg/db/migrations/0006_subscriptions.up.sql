@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+	subscription_id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	chat_id bigint NOT NULL,
+	region varchar(100) NOT NULL DEFAULT '',
+	participant varchar(100) NOT NULL DEFAULT '',
+	etp varchar(100) NOT NULL DEFAULT '',
+	status varchar(20) NOT NULL DEFAULT '',
+	price_from numeric(16, 2) NOT NULL DEFAULT 0,
+	price_to numeric(16, 2) NOT NULL DEFAULT 0,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_chat_id_idx ON subscriptions (chat_id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS members (
+	chat_id bigint PRIMARY KEY,
+	role varchar(10) NOT NULL CHECK (role IN ('viewer', 'manager', 'admin')),
+	granted_by bigint NOT NULL DEFAULT 0,
+	granted_at timestamptz NOT NULL DEFAULT now()
+);
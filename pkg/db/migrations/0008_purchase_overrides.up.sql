@@ -0,0 +1,11 @@
+-- purchase fields edited in the bot, they are kept on the
+-- upserts until the incoming value is the same
+CREATE TABLE IF NOT EXISTS purchase_overrides (
+	registry_number varchar (20) NOT NULL,
+	column_name varchar(30) NOT NULL,
+	value text NOT NULL DEFAULT '',
+	set_by bigint NOT NULL,
+	set_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (registry_number, column_name),
+	FOREIGN KEY (registry_number) REFERENCES purchase_registry (registry_number) ON DELETE CASCADE
+);
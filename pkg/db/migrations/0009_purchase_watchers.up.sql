@@ -0,0 +1,11 @@
+-- private chats reminded about the purchase events,
+-- they are kept until the events are past
+CREATE TABLE IF NOT EXISTS purchase_watchers (
+	registry_number varchar (20) NOT NULL,
+	chat_id bigint NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (registry_number, chat_id),
+	FOREIGN KEY (registry_number) REFERENCES purchase_registry (registry_number) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS purchase_watchers_chat_id_idx ON purchase_watchers (chat_id);
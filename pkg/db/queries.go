@@ -15,11 +15,24 @@ type stmtOpts struct {
 	groupBy     []string // group by columns
 	orderBy     []string // order by columns
 	limit       int
+	offset      int
 	cols        []string
 	multiplier  int
 	withUpdate  bool
+	lockTable   string // rows of the table are locked until the end of transaction
 }
 
+// maxBindParams is the PostgreSQL limit
+// of parameters in a single statement
+const maxBindParams = 65535
+
+// savepoint statements used by the chunked upsert
+const (
+	upsertSavepoint           = "savepoint upsert_chunk;"
+	upsertRollbackToSavepoint = "rollback to savepoint upsert_chunk;"
+	upsertReleaseSavepoint    = "release savepoint upsert_chunk;"
+)
+
 // joinOpt is the parameter
 // needed to alter FROM clause building process
 type joinOpt int
@@ -46,17 +59,31 @@ func upsertStatement(opts stmtOpts) string {
 		opts.tableName, columns(opts.cols...), placeholders(len(opts.cols), opts.multiplier), opts.conflictKey)
 }
 
+func insertStatement(opts stmtOpts) string {
+	if len(opts.cols) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("insert into %s (%s) values %s;",
+		opts.tableName, columns(opts.cols...), placeholders(len(opts.cols), opts.multiplier))
+}
+
 func selectWhereStmt(opts stmtOpts) string {
 	var order, limit, group string
 	if opts.limit > 0 {
 		limit = fmt.Sprintf("limit %d", opts.limit)
 	}
+	if opts.offset > 0 {
+		limit += fmt.Sprintf(" offset %d", opts.offset)
+	}
 	if len(opts.orderBy) != 0 {
 		order = fmt.Sprintf("order by %s", columns(opts.orderBy...))
 	}
 	if len(opts.groupBy) != 0 {
 		group = fmt.Sprintf("group by %s", columns(opts.groupBy...))
 	}
+	if opts.lockTable != "" {
+		limit += fmt.Sprintf(" for update of %s", opts.lockTable)
+	}
 
 	if opts.fromClause != "" {
 		return fmt.Sprintf("select %s from %s %s %s %s %s;",
@@ -92,6 +119,32 @@ func buildFromClause(t table, join joinOpt) string {
 	return b.String()
 }
 
+// chunkSize returns how many rows with provided count
+// of columns fit in a single statement
+func chunkSize(count int) int {
+	if count <= 0 {
+		return 1
+	}
+	return maxBindParams / count
+}
+
+// chunkBounds splits total rows into chunks of the size
+// and returns [start, end) bounds of every chunk
+func chunkBounds(total, size int) [][2]int {
+	if size <= 0 {
+		size = 1
+	}
+	bounds := make([][2]int, 0, (total+size-1)/size)
+	for i := 0; i < total; i += size {
+		end := i + size
+		if end > total {
+			end = total
+		}
+		bounds = append(bounds, [2]int{i, end})
+	}
+	return bounds
+}
+
 func placeholders(count, multiplier int) string {
 	var b strings.Builder
 	n := 1
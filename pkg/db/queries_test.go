@@ -0,0 +1,63 @@
+package botDB
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_chunkSize(t *testing.T) {
+	for _, tbl := range []table{purchaseTable{}, historyTable{}} {
+		cols := len(tbl.columns(upsert))
+		size := chunkSize(cols)
+		if size < 1 || size*cols > maxBindParams {
+			t.Errorf("chunkSize(%d) = %d, %d parameters exceed the limit %d",
+				cols, size, size*cols, maxBindParams)
+		}
+		if (size+1)*cols <= maxBindParams {
+			t.Errorf("chunkSize(%d) = %d, one more row still fits", cols, size)
+		}
+	}
+}
+
+func Test_chunkBounds(t *testing.T) {
+	const size = 3
+
+	tests := []struct {
+		name  string
+		total int
+		want  int // number of chunks
+	}{
+		{"empty", 0, 0},
+		{"less_than_size", size - 1, 1},
+		{"exactly_size", size, 1},
+		{"size_plus_one", size + 1, 2},
+		{"multiple_of_size", size * 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounds := chunkBounds(tt.total, size)
+			if len(bounds) != tt.want {
+				t.Fatalf("chunkBounds(%d, %d) = %v, want %d chunks", tt.total, size, bounds, tt.want)
+			}
+			// chunks are adjacent and cover every record
+			next := 0
+			for _, b := range bounds {
+				if b[0] != next || b[1] <= b[0] || b[1]-b[0] > size {
+					t.Fatalf("chunkBounds(%d, %d) = %v, invalid chunk %v", tt.total, size, bounds, b)
+				}
+				next = b[1]
+			}
+			if next != tt.total {
+				t.Errorf("chunkBounds(%d, %d) = %v, covers %d records", tt.total, size, bounds, next)
+			}
+		})
+	}
+}
+
+func Test_selectWhereStmt_lock(t *testing.T) {
+	stmt := selectWhereStmt(stmtOpts{tableName: "purchase_registry", fromClause: "purchase_registry left join etp",
+		whereClause: "where purchase_id = 1", cols: []string{"registry_number"}, lockTable: "purchase_registry"})
+	if !strings.HasSuffix(stmt, "for update of purchase_registry;") {
+		t.Errorf("selectWhereStmt() = %s, want the row of purchase_registry locked", stmt)
+	}
+}
@@ -58,25 +58,36 @@ type PurchaseRecord struct {
 	WinnerPriceSql          sql.NullFloat64 `json:"-"`
 	Participants            string          `json:"participants,omitempty"`
 	ParticipantsSql         sql.NullString  `json:"-"`
-	QueryType               QueryOpt        `json:"-"` // how this record was queried
+	Hash                    string          `json:"hash,omitempty"` // client side hash of the record state
+	QueryType               QueryOpt        `json:"-"`              // how this record was queried
 }
 
-// Info returns string representation of record
-func (p *PurchaseRecord) Info() (string, QueryOpt) {
+// inLocation sets the times of the record to the time zone,
+// so they are shown in it. Approval is the date column
+// without time zone, so it's kept as is
+func (p *PurchaseRecord) inLocation(loc *time.Location) {
+	p.CollectingDateTime = p.CollectingDateTime.In(loc)
+	p.BiddingDateTime = p.BiddingDateTime.In(loc)
+	p.BiddingDateTimeSql.Time = p.BiddingDateTimeSql.Time.In(loc)
+}
+
+// Info returns string representation of record.
+// ETPs are linked to the procedures by the links
+func (p *PurchaseRecord) Info(links ETPLinks) (string, QueryOpt) {
 
 	switch p.QueryType {
 
-	case TodayAuction:
-		return p.auctionString(), p.QueryType
+	case TodayAuction, UpcomingAuction:
+		return p.auctionString(links), p.QueryType
 
-	case Future, FutureAuction, TodayGo, FutureGo:
+	case Future, FutureAuction, TodayGo, FutureGo, UpcomingGo:
 		return p.participateString(), p.QueryType
 
 	case Today:
 		if p.StatusSql.String == statusGo || p.StatusSql.String == statusEstim {
 			return p.participateString(), TodayGo
 		}
-		return p.auctionString(), TodayAuction
+		return p.auctionString(links), TodayAuction
 
 	case FutureMoney:
 		return p.moneyString(), p.QueryType
@@ -85,7 +96,7 @@ func (p *PurchaseRecord) Info() (string, QueryOpt) {
 		return p.pastString(), p.QueryType
 
 	default:
-		return p.generalString(), p.QueryType
+		return p.generalString(links), p.QueryType
 
 	}
 
@@ -98,7 +109,7 @@ func (p *PurchaseRecord) truncNum() string {
 	return p.RegistryNumber[len(p.RegistryNumber)-3:]
 }
 
-func (p *PurchaseRecord) generalString() string {
+func (p *PurchaseRecord) generalString(links ETPLinks) string {
 
 	tc := p.CollectingDateTime.Format("02.01.2006 15:04")
 	tb := noTime
@@ -107,11 +118,11 @@ func (p *PurchaseRecord) generalString() string {
 	}
 
 	return fmt.Sprintf("*[%d]* _%s_\n%s *_%s_*\nНМЦК: *%.2f ₽* 🔝\nПодача: *%v* ⏳\nАукцион: *%v* ⏰\nОбеспечение: *%.2f* 💸\nСтатус: *%s*\nПлощадка: *%s*\n\n",
-		p.PurchaseId, p.RegistryNumber, p.Region, p.PurchaseSubjectAbbr,
-		p.MaxPrice, tc, tb, p.ApplicationGuaranteeSql.Float64, p.StatusSql.String, p.EtpSql.String)
+		p.PurchaseId, link(p.RegistryNumber, p.EISURL()), p.Region, p.PurchaseSubjectAbbr, p.MaxPrice,
+		tc, tb, p.ApplicationGuaranteeSql.Float64, p.StatusSql.String, link(p.EtpSql.String, p.ETPURL(links)))
 }
 
-func (p *PurchaseRecord) auctionString() string {
+func (p *PurchaseRecord) auctionString(links ETPLinks) string {
 	tb := noTime
 	if p.BiddingDateTimeSql.Valid {
 		tb = p.BiddingDateTimeSql.Time.Format("15:04")
@@ -122,8 +133,8 @@ func (p *PurchaseRecord) auctionString() string {
 	}
 
 	return fmt.Sprintf("*[%d]* %s *_%s %s_*\nВремя: *%v* ⏰\nРасчёт: *%.2f* ⬇️\nПлощадка: *%s*\nУчастник: *%s*\n\n",
-		p.PurchaseId, p.Region, p.truncNum(), p.PurchaseSubjectAbbr, tb,
-		p.EstimationSql.Float64, p.EtpSql.String, ptc)
+		p.PurchaseId, p.Region, link(p.truncNum(), p.EISURL()), p.PurchaseSubjectAbbr, tb,
+		p.EstimationSql.Float64, link(p.EtpSql.String, p.ETPURL(links)), ptc)
 }
 
 func (p *PurchaseRecord) participateString() string {
@@ -135,7 +146,7 @@ func (p *PurchaseRecord) participateString() string {
 	}
 
 	return fmt.Sprintf("*[%d]* %s *_%s %s_*\n%s\nСтатус: *%s*\n\n",
-		p.PurchaseId, p.Region, p.truncNum(), p.PurchaseSubjectAbbr, t, p.StatusSql.String)
+		p.PurchaseId, p.Region, link(p.truncNum(), p.EISURL()), p.PurchaseSubjectAbbr, t, p.StatusSql.String)
 }
 
 func (p *PurchaseRecord) pastString() string {
@@ -149,7 +160,7 @@ func (p *PurchaseRecord) pastString() string {
 	}
 
 	return fmt.Sprintf("*[%d]* *_%s %s %s_*\nДата проведения *_%v_*\n*Результат ->* %c \n\n",
-		p.PurchaseId, p.Region, p.truncNum(), p.PurchaseSubjectAbbr, tb, res)
+		p.PurchaseId, p.Region, link(p.truncNum(), p.EISURL()), p.PurchaseSubjectAbbr, tb, res)
 }
 
 func (p *PurchaseRecord) moneyString() string {
@@ -160,6 +171,22 @@ func (p *PurchaseRecord) moneyString() string {
 		p.OurParticipantsSql.String, p.StatusSql.String, p.ApplicationGuaranteeSql.Float64)
 }
 
+// fillFromNullable copies values of the queried
+// nullable fields to their plain counterparts
+func (p *PurchaseRecord) fillFromNullable() {
+	p.ApprovalDateTime = p.ApprovalDateTimeSql.Time
+	p.BiddingDateTime = p.BiddingDateTimeSql.Time
+	p.ApplicationGuarantee = p.ApplicationGuaranteeSql.Float64
+	p.ContractGuarantee = p.ContractGuaranteeSql.Float64
+	p.Status = p.StatusSql.String
+	p.OurParticipants = p.OurParticipantsSql.String
+	p.Estimation = p.EstimationSql.Float64
+	p.ETP = p.EtpSql.String
+	p.Winner = p.WinnerSql.String
+	p.WinnerPrice = p.WinnerPriceSql.Float64
+	p.Participants = p.ParticipantsSql.String
+}
+
 // setForeignKeys take reference map and check self id
 // fields if they exist in map. If so field is sets to that
 // id. Otherwise update map with new data is formed. In the end
@@ -217,7 +244,7 @@ func (p *PurchaseRecord) args(to tableOpt) []interface{} {
 			&p.BiddingDateTime, &p.RegionId, &p.CustomerTypeId,
 			&p.MaxPrice, &p.ApplicationGuarantee, &p.ContractGuarantee,
 			&p.StatusId, &p.OurParticipants, &p.Estimation,
-			&p.ETPId, &p.Winner, &p.WinnerPrice, &p.Participants,
+			&p.ETPId, &p.Winner, &p.WinnerPrice, &p.Participants, &p.Hash,
 		}
 	}
 }
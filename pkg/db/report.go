@@ -0,0 +1,224 @@
+package botDB
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Report is the performance over the period
+type Report struct {
+	From          time.Time   `json:"from"`
+	To            time.Time   `json:"to"`
+	Total         ReportRow   `json:"total"`
+	ByRegion      []ReportRow `json:"by_region"`
+	ByParticipant []ReportRow `json:"by_participant"`
+}
+
+// ReportRow is the performance of the
+// group of purchases over the period
+type ReportRow struct {
+	Name         string  `json:"name"`         // group name i.e. region
+	Applications int     `json:"applications"` // applications we filed
+	Auctions     int     `json:"auctions"`     // auctions that took place
+	Wins         int     `json:"wins"`         // won auctions
+	Losses       int     `json:"losses"`       // lost auctions
+	MaxPrice     float64 `json:"max_price"`    // total max price of the auctions with known winner price
+	WinnerPrice  float64 `json:"winner_price"` // total winner price of the same auctions
+	Guarantee    float64 `json:"guarantee"`    // application guarantee money tied up
+}
+
+// WinRate returns the percentage of won auctions
+func (r *ReportRow) WinRate() float64 {
+	if r.Wins+r.Losses == 0 {
+		return 0
+	}
+	return float64(r.Wins) * 100 / float64(r.Wins+r.Losses)
+}
+
+// PriceDrop returns the percentage the winner
+// price is lower than the max price
+func (r *ReportRow) PriceDrop() float64 {
+	if r.MaxPrice == 0 {
+		return 0
+	}
+	return (r.MaxPrice - r.WinnerPrice) * 100 / r.MaxPrice
+}
+
+// reportStatement builds aggregate statement over
+// the period grouped by provided expression or in
+// total if it is empty. Period bounds are the parameters $1 and $2
+func reportStatement(t table, group string) string {
+	inPeriod := func(col string) string {
+		return fmt.Sprintf("%s >= $1 and %s < $2", col, col)
+	}
+	applied := fmt.Sprintf("%s in ('%s', '%s', '%s', '%s')",
+		statusName, statusAuction, statusAuction2, statusWin, statusLost)
+	held := fmt.Sprintf("%s in ('%s', '%s')", statusName, statusWin, statusLost)
+
+	opts := stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: fmt.Sprintf("where (%s) or (%s)", inPeriod(collectingColumn), inPeriod(biddingColumn)),
+	}
+
+	name := "''"
+	if group != "" {
+		name = group
+		opts.groupBy = []string{group}
+		opts.orderBy = []string{group}
+	}
+
+	opts.cols = []string{
+		name,
+		fmt.Sprintf("count(*) filter (where %s and %s)", applied, inPeriod(collectingColumn)),
+		fmt.Sprintf("count(*) filter (where %s and %s)", held, inPeriod(biddingColumn)),
+		fmt.Sprintf("count(*) filter (where %s = '%s' and %s)", statusName, statusWin, inPeriod(biddingColumn)),
+		fmt.Sprintf("count(*) filter (where %s = '%s' and %s)", statusName, statusLost, inPeriod(biddingColumn)),
+		fmt.Sprintf("coalesce(sum(%s) filter (where %s is not null and %s), 0)",
+			maxPrice, winnerPrice, inPeriod(biddingColumn)),
+		fmt.Sprintf("coalesce(sum(%s) filter (where %s is not null and %s), 0)",
+			winnerPrice, winnerPrice, inPeriod(biddingColumn)),
+		fmt.Sprintf("coalesce(sum(%s) filter (where %s in ('%s', '%s') and %s), 0)",
+			applicationGuarantee, statusName, statusAuction, statusAuction2, inPeriod(collectingColumn)),
+	}
+
+	return selectWhereStmt(opts)
+}
+
+// Report aggregates purchases over the period [from, to)
+// in total, by regions and by our participants
+func (m *BotDB) Report(from, to time.Time) (Report, error) {
+	r := Report{From: from, To: to}
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	total, err := m.reportRows(reportStatement(t, ""), from, to)
+	if err != nil {
+		return r, err
+	}
+	if len(total) > 0 {
+		r.Total = total[0]
+	}
+
+	r.ByRegion, err = m.reportRows(reportStatement(t, regionName), from, to)
+	if err != nil {
+		return r, err
+	}
+
+	r.ByParticipant, err = m.reportRows(reportStatement(t,
+		fmt.Sprintf("coalesce(%s, '--не установлен--')", ourParticipants)), from, to)
+	if err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// reportRows executes aggregate statement
+func (m *BotDB) reportRows(stmt string, from, to time.Time) ([]ReportRow, error) {
+	var res []ReportRow
+
+	rows, err := m.db.Query(stmt, from, to)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Report", stmt, err, from, to)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r ReportRow
+		err = rows.Scan(&r.Name, &r.Applications, &r.Auctions, &r.Wins,
+			&r.Losses, &r.MaxPrice, &r.WinnerPrice, &r.Guarantee)
+		if err != nil {
+			return nil, newBotDbError("BotDB: Report Scan", stmt, err, from, to)
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+// ReportOf aggregates the records over the period [from, to)
+// the same way as the Report does. Groups are sorted by name
+func ReportOf(recs []PurchaseRecord, from, to time.Time) Report {
+	r := Report{From: from, To: to}
+
+	inPeriod := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+
+	regions := make(map[string]*ReportRow)
+	participants := make(map[string]*ReportRow)
+
+	// group returns the row of the group adding it if there is none
+	group := func(groups map[string]*ReportRow, name string) *ReportRow {
+		if _, ok := groups[name]; !ok {
+			groups[name] = &ReportRow{Name: name}
+		}
+		return groups[name]
+	}
+
+	for i := range recs {
+		p := &recs[i]
+		collecting := inPeriod(p.CollectingDateTime)
+		bidding := p.BiddingDateTimeSql.Valid && inPeriod(p.BiddingDateTimeSql.Time)
+		if !collecting && !bidding {
+			continue
+		}
+
+		participant := p.OurParticipantsSql.String
+		if !p.OurParticipantsSql.Valid {
+			participant = "--не установлен--"
+		}
+
+		r.Total.add(p, collecting, bidding)
+		group(regions, p.Region).add(p, collecting, bidding)
+		group(participants, participant).add(p, collecting, bidding)
+	}
+
+	r.ByRegion = sortedRows(regions)
+	r.ByParticipant = sortedRows(participants)
+
+	return r
+}
+
+// add counts the record in the row. Collecting and bidding
+// tell if the dates of the record are within the period
+func (r *ReportRow) add(p *PurchaseRecord, collecting, bidding bool) {
+	status := p.StatusSql.String
+	auction := p.StatusSql.Valid && (status == statusAuction || status == statusAuction2)
+	held := p.StatusSql.Valid && (status == statusWin || status == statusLost)
+
+	if collecting && (auction || held) {
+		r.Applications++
+	}
+	if collecting && auction {
+		r.Guarantee += p.ApplicationGuaranteeSql.Float64
+	}
+	if !bidding {
+		return
+	}
+	if held {
+		r.Auctions++
+	}
+	if held && status == statusWin {
+		r.Wins++
+	}
+	if held && status == statusLost {
+		r.Losses++
+	}
+	if p.WinnerPriceSql.Valid {
+		r.MaxPrice += p.MaxPrice
+		r.WinnerPrice += p.WinnerPriceSql.Float64
+	}
+}
+
+// sortedRows returns the rows of the groups sorted by name
+func sortedRows(groups map[string]*ReportRow) []ReportRow {
+	var res []ReportRow
+	for _, r := range groups {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
@@ -0,0 +1,161 @@
+package botDB
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maximum number of the records returned by the search
+const maxSearchResults = 30
+
+// ErrEmptySearch is returned when search has no criteria
+var ErrEmptySearch = errors.New("search criteria are empty")
+
+// SearchFilter is the criteria of the purchase search.
+// Empty fields are not taken into account, non-empty
+// ones are combined
+type SearchFilter struct {
+	Text         string // words of the subject or registry number (full or suffix)
+	Region       string
+	ETP          string
+	CustomerType string
+	Participant  string // our participant
+}
+
+// IsEmpty reports if filter has no criteria
+func (f *SearchFilter) IsEmpty() bool {
+	return f.Text == "" && f.Region == "" && f.ETP == "" &&
+		f.CustomerType == "" && f.Participant == ""
+}
+
+// whereClause builds where clause of the search
+// and returns it with the arguments for placeholders
+func (f *SearchFilter) whereClause(t table) (string, []interface{}) {
+	conds, args := f.conditions(t)
+	return "where " + strings.Join(conds, " and "), args
+}
+
+// conditions returns the conditions of the non-empty
+// criteria with the arguments for placeholders
+func (f *SearchFilter) conditions(t table) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	// placeholder adds the argument and returns its placeholder
+	placeholder := func(arg string) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Text != "" {
+		// the expression matches the full-text index on the purchase subject
+		conds = append(conds, fmt.Sprintf(
+			"(to_tsvector('russian', %s) @@ plainto_tsquery('russian', %s) or %s.%s like '%%' || %s)",
+			purchaseSubject, placeholder(f.Text), t.name(), registryNumber, placeholder(escapeLike(f.Text))))
+	}
+
+	filters := []struct{ col, value string }{
+		{regionName, f.Region},
+		{etpName, f.ETP},
+		{customerTypeName, f.CustomerType},
+		{ourParticipants, f.Participant},
+	}
+	for _, c := range filters {
+		if c.value != "" {
+			conds = append(conds, fmt.Sprintf("%s ilike '%%' || %s || '%%'",
+				c.col, placeholder(escapeLike(c.value))))
+		}
+	}
+
+	return conds, args
+}
+
+// escapeLike escapes the wildcards of the like pattern
+var escapeLike = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace
+
+// Search looks for the purchases matching the filter.
+// Latest purchases come first
+func (m *BotDB) Search(f SearchFilter) ([]PurchaseRecord, error) {
+	if f.IsEmpty() {
+		return nil, ErrEmptySearch
+	}
+
+	var recs []PurchaseRecord
+	var r PurchaseRecord
+
+	// get main table
+	t := m.tk.table(purchTableName)
+
+	where, args := f.whereClause(t)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: where,
+		orderBy:     []string{collectingColumn + " desc"},
+		limit:       maxSearchResults,
+		cols:        t.columns(query),
+	})
+
+	rows, err := m.db.Query(stmt, args...)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Search", stmt, err, args...)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return nil, newBotDbError("BotDB: Search Scan", stmt, err, args...)
+		}
+		r.inLocation(m.loc)
+		r.QueryType = Found
+		recs = append(recs, r)
+	}
+
+	return recs, rows.Err()
+}
+
+// QueryNumber looks for the purchases which registry
+// number is equal to num or ends with it.
+// Exact match comes first, then the latest purchases
+func (m *BotDB) QueryNumber(num string) ([]PurchaseRecord, error) {
+	if num == "" {
+		return nil, ErrEmptySearch
+	}
+
+	var recs []PurchaseRecord
+	var r PurchaseRecord
+
+	// get main table
+	t := m.tk.table(purchTableName)
+	col := fmt.Sprintf("%s.%s", t.name(), registryNumber)
+
+	stmt := selectWhereStmt(stmtOpts{
+		tableName:   t.name(),
+		fromClause:  buildFromClause(t, left),
+		whereClause: fmt.Sprintf("where %s like '%%' || $2", col),
+		orderBy:     []string{col + " = $1 desc", collectingColumn + " desc"},
+		limit:       maxSearchResults,
+		cols:        t.columns(query),
+	})
+
+	rows, err := m.db.Query(stmt, num, escapeLike(num))
+	if err != nil {
+		return nil, newBotDbError("BotDB: QueryNumber", stmt, err, num)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(r.args(query)...); err != nil {
+			return nil, newBotDbError("BotDB: QueryNumber Scan", stmt, err, num)
+		}
+		r.inLocation(m.loc)
+		r.QueryType = General
+		recs = append(recs, r)
+	}
+
+	return recs, rows.Err()
+}
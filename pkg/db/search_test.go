@@ -0,0 +1,28 @@
+package botDB
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchFilter_whereClause(t *testing.T) {
+	f := SearchFilter{Text: "104", Region: "50%", Participant: "ООО"}
+
+	where, args := f.whereClause(purchaseTable{})
+
+	wantArgs := []interface{}{"104", "104", `50\%`, "ООО"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("SearchFilter.whereClause() args = %v, want %v", args, wantArgs)
+	}
+
+	for _, want := range []string{"plainto_tsquery('russian', $1)", "like '%' || $2",
+		"region_name ilike '%' || $3 || '%'", "our_participants ilike '%' || $4 || '%'"} {
+		if !strings.Contains(where, want) {
+			t.Errorf("SearchFilter.whereClause() = %s, want it to contain %s", where, want)
+		}
+	}
+	if strings.Contains(where, etpName) {
+		t.Errorf("SearchFilter.whereClause() = %s, empty criteria must be skipped", where)
+	}
+}
@@ -0,0 +1,109 @@
+package botDB
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Subscription is the personal filter of the purchases
+// the chat wants to be notified about. Empty fields
+// are not taken into account, non-empty ones are combined
+type Subscription struct {
+	Id          int64
+	Chat        int64
+	Region      string
+	Participant string // our participant
+	ETP         string
+	Status      string
+	PriceFrom   float64 // the lowest max price
+	PriceTo     float64 // the highest max price
+	CreatedAt   time.Time
+}
+
+// Match reports if the purchase passes the filter.
+// Text fields are matched case-insensitively by substring
+func (s *Subscription) Match(p *PurchaseRecord) bool {
+	return containsFold(p.Region, s.Region) &&
+		containsFold(p.OurParticipantsSql.String, s.Participant) &&
+		containsFold(p.EtpSql.String, s.ETP) &&
+		containsFold(p.StatusSql.String, s.Status) &&
+		(s.PriceFrom == 0 || p.MaxPrice >= s.PriceFrom) &&
+		(s.PriceTo == 0 || p.MaxPrice <= s.PriceTo)
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// String returns human readable filter
+func (s *Subscription) String() string {
+	var parts []string
+	add := func(label, v string) {
+		if v != "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", label, v))
+		}
+	}
+	add("Регион", s.Region)
+	add("Участник", s.Participant)
+	add("Площадка", s.ETP)
+	add("Статус", s.Status)
+	if s.PriceFrom != 0 {
+		add("НМЦК от", fmt.Sprintf("%.2f ₽", s.PriceFrom))
+	}
+	if s.PriceTo != 0 {
+		add("НМЦК до", fmt.Sprintf("%.2f ₽", s.PriceTo))
+	}
+	if len(parts) == 0 {
+		return "все закупки"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Subscribe saves the subscription and returns its id
+func (m *BotDB) Subscribe(s Subscription) (int64, error) {
+	var id int64
+
+	args := []any{s.Chat, s.Region, s.Participant, s.ETP, s.Status, s.PriceFrom, s.PriceTo}
+
+	if err := m.db.QueryRow(subInsertStatement, args...).Scan(&id); err != nil {
+		return 0, newBotDbError("BotDB: Subscribe", subInsertStatement, err, args...)
+	}
+	return id, nil
+}
+
+// Unsubscribe removes the subscription of the chat
+// or all of them if id is zero. It returns how many
+// subscriptions were removed
+func (m *BotDB) Unsubscribe(chat, id int64) (int64, error) {
+	res, err := m.db.Exec(subDeleteStatement, chat, id)
+	if err != nil {
+		return 0, newBotDbError("BotDB: Unsubscribe", subDeleteStatement, err, chat, id)
+	}
+	return res.RowsAffected()
+}
+
+// Subscriptions returns subscriptions of the
+// chat or of all chats if chat is zero
+func (m *BotDB) Subscriptions(chat int64) ([]Subscription, error) {
+	var res []Subscription
+
+	rows, err := m.db.Query(subSelectStatement, chat)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Subscriptions", subSelectStatement, err, chat)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Subscription
+		err = rows.Scan(&s.Id, &s.Chat, &s.Region, &s.Participant,
+			&s.ETP, &s.Status, &s.PriceFrom, &s.PriceTo, &s.CreatedAt)
+		if err != nil {
+			return nil, newBotDbError("BotDB: Subscriptions Scan", subSelectStatement, err, chat)
+		}
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,37 @@
+package botDB
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSubscription_Match(t *testing.T) {
+	p := PurchaseRecord{
+		Region:             "Московская область",
+		MaxPrice:           1000000,
+		OurParticipantsSql: sql.NullString{String: "ООО Ромашка", Valid: true},
+		EtpSql:             sql.NullString{String: "РТС-тендер", Valid: true},
+		StatusSql:          sql.NullString{String: statusGo, Valid: true},
+	}
+
+	tests := []struct {
+		name string
+		s    Subscription
+		want bool
+	}{
+		{"empty", Subscription{}, true},
+		{"region", Subscription{Region: "московская"}, true},
+		{"other_region", Subscription{Region: "Тверская"}, false},
+		{"combined", Subscription{Participant: "ромашка", ETP: "ртс", Status: statusGo}, true},
+		{"price_in_range", Subscription{PriceFrom: 500000, PriceTo: 1000000}, true},
+		{"price_too_low", Subscription{PriceFrom: 2000000}, false},
+		{"price_too_high", Subscription{PriceTo: 999999}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Match(&p); got != tt.want {
+				t.Errorf("Subscription.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import "fmt"
 
 // Purchase Table column
 const (
-	purchTableColsCount  = 20
+	purchTableColsCount  = 21
 	purchTableName       = "purchase_registry"
 	registryNumber       = "registry_number"
 	purchaseID           = "purchase_id"
@@ -26,6 +26,7 @@ const (
 	winnerColumn         = "winner"
 	winnerPrice          = "winner_price"
 	participantsColumn   = "participants"
+	recordHash           = "record_hash"
 )
 
 // Customer Types Table column
@@ -76,10 +77,172 @@ const (
 	purchaseStringCodeName           = "purchase_string_code_name"
 )
 
-// Delete statement for cleaning up space in DB
+// Purchase History Table column
 const (
-	purchDeleteStatement = `delete from ` + purchTableName +
-		` where ` + biddingColumn + ` < current_date - interval '2 months'; vacuum full;`
+	historyTableColsCount = 6
+	historyTableName      = "purchase_history"
+	historyID             = "history_id"
+	columnName            = "column_name"
+	oldValue              = "old_value"
+	newValue              = "new_value"
+	changedAt             = "changed_at"
+)
+
+// Sent Notifications Table column
+const (
+	sentTableName = "sent_notifications"
+	eventColumn   = "event"
+	leadColumn    = "lead_seconds"
+	eventTime     = "event_time"
+	sentAt        = "sent_at"
+)
+
+// Sent notifications statements
+const (
+	sentSelectStatement = `select ` + purchaseID + `, ` + eventColumn + `, ` + leadColumn + `, ` + eventTime +
+		` from ` + sentTableName + ` where ` + eventTime + ` >= $1;`
+	sentInsertStatement = `insert into ` + sentTableName + ` (` + purchaseID + `, ` + eventColumn + `, ` +
+		leadColumn + `, ` + eventTime + `) values ($1, $2, $3, $4) on conflict do nothing;`
+	sentDeleteStatement = `delete from ` + sentTableName + ` where ` + eventTime + ` < $1;`
+)
+
+// Subscriptions Table column
+const (
+	subTableName   = "subscriptions"
+	subscriptionID = "subscription_id"
+	chatID         = "chat_id"
+	subRegion      = "region"
+	subParticipant = "participant"
+	subETP         = "etp"
+	subStatus      = "status"
+	priceFrom      = "price_from"
+	priceTo        = "price_to"
+	createdAt      = "created_at"
+)
+
+// Subscriptions statements
+const (
+	subColumns = subscriptionID + `, ` + chatID + `, ` + subRegion + `, ` + subParticipant + `, ` +
+		subETP + `, ` + subStatus + `, ` + priceFrom + `, ` + priceTo + `, ` + createdAt
+	// zero chat selects subscriptions of all chats
+	subSelectStatement = `select ` + subColumns + ` from ` + subTableName +
+		` where $1 = 0 or ` + chatID + ` = $1 order by ` + subscriptionID + `;`
+	subInsertStatement = `insert into ` + subTableName + ` (` + chatID + `, ` + subRegion + `, ` +
+		subParticipant + `, ` + subETP + `, ` + subStatus + `, ` + priceFrom + `, ` + priceTo +
+		`) values ($1, $2, $3, $4, $5, $6, $7) returning ` + subscriptionID + `;`
+	// zero id deletes all subscriptions of the chat
+	subDeleteStatement = `delete from ` + subTableName + ` where ` + chatID + ` = $1 and ($2 = 0 or ` +
+		subscriptionID + ` = $2);`
+)
+
+// Members Table column
+const (
+	memberTableName = "members"
+	memberRole      = "role"
+	grantedBy       = "granted_by"
+	grantedAt       = "granted_at"
+)
+
+// Members statements
+const (
+	memberColumns = chatID + `, ` + memberRole + `, ` + grantedBy + `, ` + grantedAt
+	// empty role selects members of all roles
+	memberSelectStatement = `select ` + memberColumns + ` from ` + memberTableName +
+		` where $1 = '' or ` + memberRole + ` = $1 order by ` + chatID + `;`
+	memberRoleStatement = `select ` + memberRole + ` from ` + memberTableName +
+		` where ` + chatID + ` = $1;`
+	memberGrantStatement = `insert into ` + memberTableName + ` (` + chatID + `, ` + memberRole + `, ` +
+		grantedBy + `) values ($1, $2, $3) on conflict (` + chatID + `) do update set ` +
+		memberRole + ` = excluded.` + memberRole + `, ` + grantedBy + ` = excluded.` + grantedBy +
+		`, ` + grantedAt + ` = now();`
+	// enrolled member keeps the role it already has
+	memberEnrollStatement = `insert into ` + memberTableName + ` (` + chatID + `, ` + memberRole + `, ` +
+		grantedBy + `) values ($1, $2, 0) on conflict do nothing;`
+	memberDeleteStatement = `delete from ` + memberTableName + ` where ` + chatID + ` = $1;`
+)
+
+// Overrides Table column
+const (
+	overrideTableName = "purchase_overrides"
+	overrideValue     = "value"
+	setBy             = "set_by"
+	setAt             = "set_at"
+)
+
+// Overrides statements. Column name is the
+// tracked field name as in the history table
+const (
+	overrideSelectStatement = `select ` + registryNumber + `, ` + columnName + `, ` + overrideValue +
+		` from ` + overrideTableName + ` where ` + registryNumber + ` = any($1) for update;`
+	overrideUpsertStatement = `insert into ` + overrideTableName + ` (` + registryNumber + `, ` +
+		columnName + `, ` + overrideValue + `, ` + setBy + `) values ($1, $2, $3, $4) on conflict (` +
+		registryNumber + `, ` + columnName + `) do update set ` + overrideValue + ` = excluded.` +
+		overrideValue + `, ` + setBy + ` = excluded.` + setBy + `, ` + setAt + ` = now();`
+	overrideDeleteStatement = `delete from ` + overrideTableName + ` where ` + registryNumber +
+		` = $1 and ` + columnName + ` = $2;`
+	statusEnsureStatement = `insert into ` + statusTableName + ` (` + statusName +
+		`) values ($1) on conflict do nothing;`
+)
+
+// Watchers Table column
+const (
+	watcherTableName = "purchase_watchers"
+)
+
+// Watchers statements. Watchers are kept by the registry number,
+// so they are gone along with the purchase
+const (
+	watcherInsertStatement = `insert into ` + watcherTableName + ` (` + registryNumber + `, ` + chatID +
+		`) select ` + registryNumber + `, $2 from ` + purchTableName + ` where ` + purchaseID +
+		` = $1 on conflict do nothing;`
+	watcherSelectStatement = `select p.` + purchaseID + `, w.` + chatID + ` from ` + watcherTableName +
+		` w join ` + purchTableName + ` p on p.` + registryNumber + ` = w.` + registryNumber +
+		` order by p.` + purchaseID + `, w.` + chatID + `;`
+	// watchers of the purchases which events are past
+	watcherPruneStatement = `delete from ` + watcherTableName + ` w using ` + purchTableName +
+		` p where p.` + registryNumber + ` = w.` + registryNumber + ` and coalesce(p.` + biddingColumn +
+		`, p.` + collectingColumn + `) < $1;`
+	watcherDeleteStatement = `delete from ` + watcherTableName + ` where ` + chatID + ` = $1;`
+)
+
+// Migrations Table column
+const (
+	migrationsTableName = "schema_migrations"
+	migrationVersion    = "version"
+	migrationName       = "name"
+	appliedAt           = "applied_at"
+)
+
+// Migrations statements. Advisory lock key is the
+// parameter of the lock statements, so concurrently
+// started instances don't race
+const (
+	migrationsLockKey        = 7466726
+	migrationsTableStatement = `create table if not exists ` + migrationsTableName + ` (` +
+		migrationVersion + ` bigint primary key, ` + migrationName + ` varchar(100) not null, ` +
+		appliedAt + ` timestamptz not null default now());`
+	migrationsVersionsStatement = `select ` + migrationVersion + ` from ` + migrationsTableName +
+		` order by ` + migrationVersion + `;`
+	migrationsInsertStatement = `insert into ` + migrationsTableName + ` (` + migrationVersion + `, ` +
+		migrationName + `) values ($1, $2);`
+	migrationsDeleteStatement = `delete from ` + migrationsTableName + ` where ` + migrationVersion + ` = $1;`
+	migrationsLockStatement   = `select pg_advisory_lock($1);`
+	migrationsUnlockStatement = `select pg_advisory_unlock($1);`
+)
+
+// Delete statement for cleaning up space in DB.
+// The start of the retention window is the only parameter
+const (
+	purchExpiredClause   = `where coalesce(` + biddingColumn + `, ` + collectingColumn + `) < $1`
+	purchDeleteStatement = `delete from ` + purchTableName + ` ` + purchExpiredClause + `;`
+	purchVacuumStatement = `vacuum full ` + purchTableName + `;`
+)
+
+// Sync statements. The array of registry numbers is the only parameter
+const (
+	purchHashesStatement = `select ` + registryNumber + `, coalesce(` + recordHash + `, '') from ` +
+		purchTableName + ` where ` + registryNumber + ` = any($1);`
+	purchBuryStatement = `delete from ` + purchTableName + ` where ` + registryNumber + ` = any($1);`
 )
 
 // tableOpt is the parameter
@@ -118,6 +281,8 @@ func (t tables) table(name string) table {
 		return statusTable{}
 	case purchTableName:
 		return purchaseTable{}
+	case historyTableName:
+		return historyTable{}
 	}
 
 	return nil
@@ -133,6 +298,7 @@ func (t tables) tables() []table {
 		regionTable{},
 		purchTypeTable{},
 		statusTable{},
+		historyTable{},
 	}
 }
 
@@ -145,6 +311,7 @@ type (
 	purchStrCodeTable struct{}
 	purchTypeTable    struct{}
 	custTypeTable     struct{}
+	historyTable      struct{}
 )
 
 func (t etpTable) name() string { return etpTableName }
@@ -266,7 +433,7 @@ func (t purchaseTable) columns(to tableOpt) []string {
 			collectingColumn, approvalColumn, biddingColumn, regionColumn,
 			customerTypeColumn, maxPrice, applicationGuarantee, contractGuarantee,
 			statusColumn, ourParticipants, estimationColumn, etpColumn,
-			winnerColumn, winnerPrice, participantsColumn,
+			winnerColumn, winnerPrice, participantsColumn, recordHash,
 		}
 	}
 }
@@ -289,3 +456,25 @@ func (t purchaseTable) joinOn(other table) string {
 		return t.primaryKeyCol(primaryKey)
 	}
 }
+
+func (t historyTable) name() string { return historyTableName }
+
+func (t historyTable) primaryKeyCol(_ tableOpt) string { return historyID }
+
+func (t historyTable) nameKeyCol() string { return columnName }
+
+func (t historyTable) refTables() []table { return []table{purchaseTable{}} }
+
+func (t historyTable) columns(to tableOpt) []string {
+	switch to {
+	case query:
+		return []string{
+			historyTableName + "." + registryNumber, purchaseID,
+			columnName, oldValue, newValue, changedAt,
+		}
+	default:
+		return []string{registryNumber, columnName, oldValue, newValue, changedAt}
+	}
+}
+
+func (t historyTable) joinOn(_ table) string { return registryNumber }
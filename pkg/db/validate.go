@@ -0,0 +1,133 @@
+package botDB
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRecords is returned when incoming
+// update is rejected due to invalid records
+var ErrInvalidRecords = errors.New("update contains invalid records")
+
+// UpsertMode defines how invalid
+// incoming records are treated
+type UpsertMode int
+
+// upsert mode
+const (
+	Strict  UpsertMode = iota // whole update is rejected if any record is invalid
+	Partial                   // valid records are upserted, invalid are rejected
+)
+
+// max length of the registry number column
+const registryNumberLen = 20
+
+// knownStatuses is the set of statuses the bot understands
+var knownStatuses = map[string]bool{
+	statusGo: true, statusEstim: true, statusAuction: true,
+	statusAuction2: true, statusWin: true, statusLost: true,
+}
+
+// ValidationError describes the reason
+// why the incoming record was rejected
+type ValidationError struct {
+	RegistryNumber string `json:"registry_number"`
+	Field          string `json:"field"`
+	Reason         string `json:"reason"`
+}
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("registry_number=%s field=%s: %s", ve.RegistryNumber, ve.Field, ve.Reason)
+}
+
+// Validate checks the record fields before it goes to
+// the database and returns all found violations
+func (p *PurchaseRecord) Validate() []ValidationError {
+	var errs []ValidationError
+
+	add := func(field, reason string) {
+		errs = append(errs, ValidationError{RegistryNumber: p.RegistryNumber, Field: field, Reason: reason})
+	}
+
+	if p.RegistryNumber == "" {
+		add("registry_number", "must not be empty")
+	} else if len(p.RegistryNumber) > registryNumberLen {
+		add("registry_number", fmt.Sprintf("must not be longer than %d characters", registryNumberLen))
+	}
+	if p.PurchaseSubject == "" {
+		add("purchase_subject", "must not be empty")
+	}
+	if p.PurchaseSubjectAbbr == "" {
+		add("purchase_abbr", "must not be empty")
+	}
+	if p.PurchaseType == "" {
+		add("purchase_type", "must not be empty")
+	}
+	if p.Region == "" {
+		add("region", "must not be empty")
+	}
+	if p.CustomerType == "" {
+		add("customer_type", "must not be empty")
+	}
+	if p.Status != "" && !knownStatuses[p.Status] {
+		add("status", fmt.Sprintf("unknown status '%s'", p.Status))
+	}
+	if p.CollectingDateTime.IsZero() {
+		add("collecting_datetime", "must be set")
+	}
+	if !p.BiddingDateTime.IsZero() && p.CollectingDateTime.After(p.BiddingDateTime) {
+		add("collecting_datetime", "must not be after bidding_datetime")
+	}
+	if p.MaxPrice < 0 {
+		add("max_price", "must not be negative")
+	}
+	if p.ApplicationGuarantee < 0 {
+		add("application_guarantee", "must not be negative")
+	}
+	if p.ContractGuarantee < 0 {
+		add("contract_guarantee", "must not be negative")
+	}
+	if p.WinnerPrice < 0 {
+		add("winner_price", "must not be negative")
+	}
+
+	return errs
+}
+
+// validate checks every record of the BotDB, removes
+// invalid ones and returns the reasons of rejection
+func (m *BotDB) validate() []ValidationError {
+	var rejected []ValidationError
+	m.records, rejected = ValidateAll(m.records)
+	return rejected
+}
+
+// ValidateAll checks every record of the update and returns
+// the valid ones and the reasons of rejection of the others.
+// Valid records reuse the memory of the provided slice
+func ValidateAll(recs []PurchaseRecord) ([]PurchaseRecord, []ValidationError) {
+	var rejected []ValidationError
+
+	seen := make(map[string]bool, len(recs))
+	valid := recs[:0]
+
+	for i := range recs {
+		errs := recs[i].Validate()
+
+		// the same row can't be affected twice by one upsert statement
+		if num := recs[i].RegistryNumber; num != "" && seen[num] {
+			errs = append(errs, ValidationError{RegistryNumber: num,
+				Field: "registry_number", Reason: "duplicate in the update"})
+		}
+
+		if len(errs) > 0 {
+			rejected = append(rejected, errs...)
+			continue
+		}
+
+		seen[recs[i].RegistryNumber] = true
+		valid = append(valid, recs[i])
+	}
+
+	return valid, rejected
+}
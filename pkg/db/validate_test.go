@@ -0,0 +1,83 @@
+package botDB
+
+import (
+	"testing"
+	"time"
+)
+
+func validRecord(num string) PurchaseRecord {
+	return PurchaseRecord{
+		RegistryNumber:      num,
+		PurchaseSubject:     "test subject",
+		PurchaseSubjectAbbr: "ТС",
+		PurchaseType:        "test type",
+		Region:              "test region",
+		CustomerType:        "ГУ",
+		Status:              statusGo,
+		CollectingDateTime:  time.Unix(1657016101, 0),
+		BiddingDateTime:     time.Unix(1657016101, 0).Add(time.Hour),
+		MaxPrice:            100000,
+	}
+}
+
+func TestPurchaseRecord_Validate(t *testing.T) {
+
+	t.Run("valid", func(t *testing.T) {
+		p := validRecord("0859200001122007104")
+		if errs := p.Validate(); len(errs) != 0 {
+			t.Fatalf("PurchaseRecord.Validate() got = %v, want no errors", errs)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		modify func(p *PurchaseRecord)
+		field  string
+	}{
+		{"empty_registry_number", func(p *PurchaseRecord) { p.RegistryNumber = "" }, "registry_number"},
+		{"empty_purchase_abbr", func(p *PurchaseRecord) { p.PurchaseSubjectAbbr = "" }, "purchase_abbr"},
+		{"empty_customer_type", func(p *PurchaseRecord) { p.CustomerType = "" }, "customer_type"},
+		{"unknown_status", func(p *PurchaseRecord) { p.Status = "unknown" }, "status"},
+		{"negative_max_price", func(p *PurchaseRecord) { p.MaxPrice = -1 }, "max_price"},
+		{"collecting_after_bidding", func(p *PurchaseRecord) {
+			p.CollectingDateTime = p.BiddingDateTime.Add(time.Hour)
+		}, "collecting_datetime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := validRecord("0859200001122007104")
+			tt.modify(&p)
+			errs := p.Validate()
+			if len(errs) != 1 {
+				t.Fatalf("PurchaseRecord.Validate() got %d errors = %v, want 1", len(errs), errs)
+			}
+			if errs[0].Field != tt.field {
+				t.Fatalf("PurchaseRecord.Validate() got field = %s, want %s", errs[0].Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestBotDB_validate(t *testing.T) {
+	bad := validRecord("0859200001122007105")
+	bad.MaxPrice = -1
+
+	m := BotDB{records: []PurchaseRecord{
+		validRecord("0859200001122007104"),
+		bad,
+		validRecord("0859200001122007104"), // duplicate
+		validRecord("0859200001122007106"),
+	}}
+
+	rejected := m.validate()
+
+	if len(rejected) != 2 {
+		t.Fatalf("BotDB.validate() got %d rejected = %v, want 2", len(rejected), rejected)
+	}
+	if len(m.records) != 2 {
+		t.Fatalf("BotDB.validate() got %d valid records, want 2", len(m.records))
+	}
+	if rejected[0].RegistryNumber != bad.RegistryNumber {
+		t.Fatalf("BotDB.validate() got rejected = %s, want %s", rejected[0].RegistryNumber, bad.RegistryNumber)
+	}
+}
@@ -0,0 +1,51 @@
+package botDB
+
+import "time"
+
+// Watcher is the private chat which
+// is reminded about the purchase events
+type Watcher struct {
+	PurchaseId int64
+	Chat       int64
+}
+
+// Watch makes the chat to be reminded about the purchase
+// events. Watching the same purchase twice is not an error
+func (m *BotDB) Watch(id, chat int64) error {
+	if _, err := m.db.Exec(watcherInsertStatement, id, chat); err != nil {
+		return newBotDbError("BotDB: Watch", watcherInsertStatement, err, id, chat)
+	}
+	return nil
+}
+
+// Watchers returns the watchers of all purchases
+func (m *BotDB) Watchers() ([]Watcher, error) {
+	var res []Watcher
+
+	rows, err := m.db.Query(watcherSelectStatement)
+	if err != nil {
+		return nil, newBotDbError("BotDB: Watchers", watcherSelectStatement, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Watcher
+		if err = rows.Scan(&w.PurchaseId, &w.Chat); err != nil {
+			return nil, newBotDbError("BotDB: Watchers Scan", watcherSelectStatement, err)
+		}
+		res = append(res, w)
+	}
+
+	return res, rows.Err()
+}
+
+// PruneWatchers removes the watchers of the purchases which
+// events happened before. It returns the number of removed ones
+func (m *BotDB) PruneWatchers(before time.Time) (int64, error) {
+	res, err := m.db.Exec(watcherPruneStatement, before)
+	if err != nil {
+		return 0, newBotDbError("BotDB: PruneWatchers", watcherPruneStatement, err, before)
+	}
+	return res.RowsAffected()
+}